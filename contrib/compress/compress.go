@@ -0,0 +1,88 @@
+// Package compress registers the snappy and zstd compressors of tars, import it for the side effect
+// and configure the client with <compress>zstd,snappy,gzip</compress>.
+package compress
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/TarsCloud/TarsGo/tars/compress"
+)
+
+const (
+	// Snappy is the name of the snappy compressor, the payload is a snappy block.
+	Snappy = "snappy"
+	// Zstd is the name of the zstd compressor.
+	Zstd = "zstd"
+)
+
+func init() {
+	compress.Register(&snappyCompressor{})
+	compress.Register(newZstdCompressor())
+}
+
+type snappyCompressor struct{}
+
+func (c *snappyCompressor) Name() string {
+	return Snappy
+}
+
+func (c *snappyCompressor) Compress(data []byte) ([]byte, error) {
+	return s2.EncodeSnappy(nil, data), nil
+}
+
+func (c *snappyCompressor) Decompress(data []byte, limit int) ([]byte, error) {
+	n, err := s2.DecodedLen(data)
+	if err != nil {
+		return nil, err
+	}
+	if n > limit {
+		return nil, compress.ErrTooLarge
+	}
+	return s2.Decode(nil, data)
+}
+
+type zstdCompressor struct {
+	encoder  *zstd.Encoder
+	decoders sync.Pool
+}
+
+func newZstdCompressor() *zstdCompressor {
+	// the encoder is safe for concurrent EncodeAll
+	encoder, _ := zstd.NewWriter(nil)
+	return &zstdCompressor{encoder: encoder}
+}
+
+func (c *zstdCompressor) Name() string {
+	return Zstd
+}
+
+func (c *zstdCompressor) Compress(data []byte) ([]byte, error) {
+	return c.encoder.EncodeAll(data, nil), nil
+}
+
+func (c *zstdCompressor) Decompress(data []byte, limit int) ([]byte, error) {
+	var err error
+	d, ok := c.decoders.Get().(*zstd.Decoder)
+	if ok {
+		err = d.Reset(bytes.NewReader(data))
+	} else {
+		d, err = zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer c.decoders.Put(d)
+	out, err := io.ReadAll(io.LimitReader(d, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > limit {
+		return nil, compress.ErrTooLarge
+	}
+	return out, nil
+}
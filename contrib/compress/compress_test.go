@@ -0,0 +1,37 @@
+package compress
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/TarsCloud/TarsGo/tars/compress"
+)
+
+func TestCompressors(t *testing.T) {
+	data := bytes.Repeat([]byte("tars"), 1024)
+	for _, name := range []string{Snappy, Zstd} {
+		t.Run(name, func(t *testing.T) {
+			c := compress.Get(name)
+			if c == nil {
+				t.Fatalf("%s is not registered", name)
+			}
+			compressed, err := c.Compress(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(compressed) >= len(data) {
+				t.Errorf("compressed %d bytes to %d bytes", len(data), len(compressed))
+			}
+			out, err := c.Decompress(compressed, len(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, data) {
+				t.Error("decompressed data mismatch")
+			}
+			if _, err = c.Decompress(compressed, len(data)-1); err != compress.ErrTooLarge {
+				t.Errorf("Decompress over limit: %v", err)
+			}
+		})
+	}
+}
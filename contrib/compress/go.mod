@@ -0,0 +1,10 @@
+module github.com/TarsCloud/TarsGo/contrib/compress
+
+go 1.18
+
+require (
+	github.com/TarsCloud/TarsGo v1.4.4
+	github.com/klauspost/compress v1.17.9
+)
+
+replace github.com/TarsCloud/TarsGo => ../../
@@ -0,0 +1,20 @@
+module github.com/TarsCloud/TarsGo/contrib/transport/quic
+
+go 1.23
+
+require (
+	github.com/TarsCloud/TarsGo v1.4.4
+	github.com/quic-go/quic-go v0.54.1
+)
+
+require (
+	go.uber.org/mock v0.5.0 // indirect
+	golang.org/x/crypto v0.26.0 // indirect
+	golang.org/x/mod v0.18.0 // indirect
+	golang.org/x/net v0.28.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+	golang.org/x/sys v0.23.0 // indirect
+	golang.org/x/tools v0.22.0 // indirect
+)
+
+replace github.com/TarsCloud/TarsGo => ../../../
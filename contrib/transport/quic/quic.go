@@ -0,0 +1,358 @@
+// Package quic provides the quic transport for tars, import it for the side effect
+// and use the endpoints such as "quic -h 127.0.0.1 -p 10015".
+//
+// A quic connection is a connection of the tars server and client, so the cancel frames, the streams,
+// the authentication and the connection limits work as they do on tcp. Every package is carried by
+// its own unidirectional stream, so a lost packet only blocks the package it belongs to, and the packages
+// of a connection may arrive out of order. The tls config of the server and the client is required,
+// and the ALPN is "tars". It requires Go 1.23 as quic-go does.
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol"
+	"github.com/TarsCloud/TarsGo/tars/transport"
+)
+
+const (
+	// Proto is the protocol name of the endpoints.
+	Proto = "quic"
+	// NextProto is the ALPN of tars over quic.
+	NextProto = "tars"
+
+	// maxIncomingStreams is the max number of the packages being received on a connection.
+	maxIncomingStreams = 10000
+	// pkgQueueLen is the number of the packages received but not read yet.
+	pkgQueueLen = 64
+)
+
+var errTLSConfig = errors.New("quic: tls config is required")
+
+func init() {
+	Register(nil)
+}
+
+// Register registers the quic transport with the config, nil for the default config.
+func Register(conf *quic.Config) {
+	transport.RegisterTransport(Proto, &Transport{Config: conf})
+}
+
+// Transport is the quic transport.
+type Transport struct {
+	// Config is the quic config, nil for the default config.
+	Config *quic.Config
+}
+
+func (t *Transport) quicConfig() *quic.Config {
+	if t.Config != nil {
+		return t.Config
+	}
+	return &quic.Config{MaxIncomingUniStreams: maxIncomingStreams}
+}
+
+func tlsConfig(conf *tls.Config) *tls.Config {
+	c := conf.Clone()
+	if len(c.NextProtos) == 0 {
+		c.NextProtos = []string{NextProto}
+	}
+	return c
+}
+
+// Listen announces on the udp address, each quic connection accepted is a conn of the listener.
+func (t *Transport) Listen(address string, conf *transport.TarsServerConf) (net.Listener, error) {
+	if conf.TlsConfig == nil {
+		return nil, errTLSConfig
+	}
+	ln, err := quic.ListenAddr(address, tlsConfig(conf.TlsConfig), t.quicConfig())
+	if err != nil {
+		return nil, err
+	}
+	l := &listener{
+		ln:       ln,
+		conns:    make(chan net.Conn),
+		closed:   make(chan struct{}),
+		deadline: newDeadline(),
+	}
+	go l.serve()
+	return l, nil
+}
+
+// Dial connects to the udp address, each package written to the conn is sent on a new stream.
+func (t *Transport) Dial(address string, _ transport.ClientProtocol, conf *transport.TarsClientConf) (net.Conn, error) {
+	if conf.TlsConfig == nil {
+		return nil, errTLSConfig
+	}
+	ctx := context.Background()
+	if conf.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conf.DialTimeout)
+		defer cancel()
+	}
+	conn, err := quic.DialAddr(ctx, address, tlsConfig(conf.TlsConfig), t.quicConfig())
+	if err != nil {
+		return nil, err
+	}
+	return newConn(conn), nil
+}
+
+// timeoutError is returned when the deadline is exceeded, which is taken as no data by the transport.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// deadline is the deadline which can be changed while waiting.
+type deadline struct {
+	mu      sync.Mutex
+	t       time.Time
+	changed chan struct{}
+}
+
+func newDeadline() *deadline {
+	return &deadline{changed: make(chan struct{})}
+}
+
+func (d *deadline) set(t time.Time) {
+	d.mu.Lock()
+	d.t = t
+	close(d.changed)
+	d.changed = make(chan struct{})
+	d.mu.Unlock()
+}
+
+// wait returns a channel which is closed when the deadline is exceeded, and a channel closed when it is changed.
+func (d *deadline) wait() (<-chan time.Time, <-chan struct{}, func()) {
+	d.mu.Lock()
+	t, changed := d.t, d.changed
+	d.mu.Unlock()
+	if t.IsZero() {
+		return nil, changed, func() {}
+	}
+	timer := time.NewTimer(time.Until(t))
+	return timer.C, changed, func() { timer.Stop() }
+}
+
+// listener accepts the quic connections.
+type listener struct {
+	ln        *quic.Listener
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+	deadline  *deadline
+}
+
+func (l *listener) serve() {
+	for {
+		qc, err := l.ln.Accept(context.Background())
+		if err != nil {
+			transport.TLOG.Errorf("quic accept error: %v", err)
+			return
+		}
+		select {
+		case l.conns <- newConn(qc):
+		case <-l.closed:
+			qc.CloseWithError(0, "server closed")
+			return
+		}
+	}
+}
+
+// Accept returns the next quic connection.
+func (l *listener) Accept() (net.Conn, error) {
+	for {
+		timeout, changed, stop := l.deadline.wait()
+		select {
+		case c := <-l.conns:
+			stop()
+			return c, nil
+		case <-timeout:
+			return nil, timeoutError{}
+		case <-changed:
+			stop()
+		case <-l.closed:
+			stop()
+			return nil, net.ErrClosed
+		}
+	}
+}
+
+// SetDeadline sets the deadline of Accept.
+func (l *listener) SetDeadline(t time.Time) error {
+	l.deadline.set(t)
+	return nil
+}
+
+// Close closes the listener, the connections accepted are closed by the server.
+func (l *listener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		err = l.ln.Close()
+	})
+	return err
+}
+
+// Addr returns the udp address of the listener.
+func (l *listener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+// conn is the quic connection of the server or the client. Each package written is sent on
+// a new unidirectional stream, and the packages of the streams from the peer are read one by one.
+type conn struct {
+	qc *quic.Conn
+
+	pkgs      chan []byte
+	rest      []byte // the rest of the package being read
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	readDeadline  *deadline
+	writeDeadline *deadline
+}
+
+func newConn(qc *quic.Conn) *conn {
+	c := &conn{
+		qc:            qc,
+		pkgs:          make(chan []byte, pkgQueueLen),
+		closed:        make(chan struct{}),
+		readDeadline:  newDeadline(),
+		writeDeadline: newDeadline(),
+	}
+	go c.acceptStreams()
+	return c
+}
+
+func (c *conn) acceptStreams() {
+	for {
+		s, err := c.qc.AcceptUniStream(context.Background())
+		if err != nil {
+			transport.TLOG.Debugf("quic connection %v closed: %v", c.qc.RemoteAddr(), err)
+			return
+		}
+		go c.readPackage(s)
+	}
+}
+
+// readPackage reads the package of the stream, which is closed by the peer after the package.
+func (c *conn) readPackage(s *quic.ReceiveStream) {
+	maxLen := protocol.GetMaxPackageLength()
+	pkg, err := io.ReadAll(io.LimitReader(s, int64(maxLen)+1))
+	if err == nil && len(pkg) > maxLen {
+		err = errors.New("package too long")
+	}
+	if err != nil {
+		s.CancelRead(0)
+		transport.TLOG.Debugf("quic read stream from %v error: %v", c.qc.RemoteAddr(), err)
+		return
+	}
+	if len(pkg) == 0 {
+		return
+	}
+	select {
+	case c.pkgs <- pkg:
+	case <-c.closed:
+	}
+}
+
+// Write sends the package on a new stream.
+func (c *conn) Write(p []byte) (int, error) {
+	ctx := context.Background()
+	c.writeDeadline.mu.Lock()
+	t := c.writeDeadline.t
+	c.writeDeadline.mu.Unlock()
+	if !t.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, t)
+		defer cancel()
+	}
+	s, err := c.qc.OpenUniStreamSync(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err = s.SetWriteDeadline(t); err != nil {
+		return 0, err
+	}
+	if _, err = s.Write(p); err != nil {
+		s.CancelWrite(0)
+		return 0, err
+	}
+	// a stream carries only one package
+	if err = s.Close(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read reads the packages received, the package is not interleaved with others.
+func (c *conn) Read(b []byte) (int, error) {
+	for len(c.rest) == 0 {
+		timeout, changed, stop := c.readDeadline.wait()
+		select {
+		case pkg := <-c.pkgs:
+			c.rest = pkg
+		case <-timeout:
+			return 0, timeoutError{}
+		case <-changed:
+		case <-c.closed:
+			stop()
+			return 0, net.ErrClosed
+		case <-c.qc.Context().Done():
+			stop()
+			return 0, context.Cause(c.qc.Context())
+		}
+		stop()
+	}
+	n := copy(b, c.rest)
+	c.rest = c.rest[n:]
+	return n, nil
+}
+
+// Close closes the quic connection.
+func (c *conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		err = c.qc.CloseWithError(0, "")
+	})
+	return err
+}
+
+// LocalAddr returns the local address of the quic connection.
+func (c *conn) LocalAddr() net.Addr {
+	return c.qc.LocalAddr()
+}
+
+// RemoteAddr returns the remote address of the quic connection.
+func (c *conn) RemoteAddr() net.Addr {
+	return c.qc.RemoteAddr()
+}
+
+// SetDeadline sets the read and write deadlines.
+func (c *conn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// SetReadDeadline sets the deadline of Read.
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+// SetWriteDeadline sets the deadline of opening the stream and writing the package.
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
@@ -0,0 +1,174 @@
+package quic
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"math/big"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/transport"
+)
+
+func parsePackage(buff []byte) (int, int) {
+	if len(buff) < 4 {
+		return 0, transport.PackageLess
+	}
+	length := int(binary.BigEndian.Uint32(buff[:4]))
+	if length < 4 {
+		return 0, transport.PackageError
+	}
+	if len(buff) < length {
+		return 0, transport.PackageLess
+	}
+	return length, transport.PackageFull
+}
+
+func pack(payload string) []byte {
+	pkg := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(pkg[:4], uint32(len(pkg)))
+	copy(pkg[4:], payload)
+	return pkg
+}
+
+type echoServer struct{}
+
+func (s *echoServer) Invoke(ctx context.Context, req []byte) []byte {
+	return pack("hello " + string(req[4:]))
+}
+
+func (s *echoServer) ParsePackage(buff []byte) (int, int) { return parsePackage(buff) }
+func (s *echoServer) InvokeTimeout(pkg []byte) []byte     { return pack("timeout") }
+func (s *echoServer) GetCloseMsg() []byte                 { return pack("") }
+func (s *echoServer) DoClose(ctx context.Context)         {}
+
+type echoClient struct {
+	mu   sync.Mutex
+	recv map[string]bool
+	done chan struct{}
+	want int
+}
+
+func (c *echoClient) Recv(pkg []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.recv[string(pkg[4:])] = true
+	if len(c.recv) == c.want {
+		close(c.done)
+	}
+}
+
+func (c *echoClient) ParsePackage(buff []byte) (int, int) { return parsePackage(buff) }
+
+func selfSignedConfig(t *testing.T) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client := &tls.Config{RootCAs: pool}
+	return server, client
+}
+
+func freeUDPAddress(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().String()
+}
+
+func TestQuicTransport(t *testing.T) {
+	serverTLS, clientTLS := selfSignedConfig(t)
+	address := freeUDPAddress(t)
+
+	svr := transport.NewTarsServer(&echoServer{}, &transport.TarsServerConf{
+		Proto:         Proto,
+		Address:       address,
+		MaxInvoke:     10,
+		QueueCap:      100,
+		AcceptTimeout: 100 * time.Millisecond,
+		ReadTimeout:   100 * time.Millisecond,
+		IdleTimeout:   time.Minute,
+		TlsConfig:     serverTLS,
+	})
+	if err := svr.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	go svr.Serve()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		svr.Shutdown(ctx)
+	}()
+
+	const count = 100
+	cp := &echoClient{recv: make(map[string]bool), done: make(chan struct{}), want: count}
+	client := transport.NewTarsClient(address, cp, &transport.TarsClientConf{
+		Proto:        Proto,
+		QueueLen:     count,
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  100 * time.Millisecond,
+		WriteTimeout: time.Second,
+		DialTimeout:  time.Second,
+		TlsConfig:    clientTLS,
+	})
+	defer client.Close()
+
+	for i := 0; i < count; i++ {
+		if err := client.Send(pack(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-cp.done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("received %d of %d responses", len(cp.recv), count)
+	}
+	for i := 0; i < count; i++ {
+		if !cp.recv["hello "+strconv.Itoa(i)] {
+			t.Errorf("response of %d is missing", i)
+		}
+	}
+	// the streams of the quic connection share a connection of the server
+	if conns := svr.Connections(); len(conns) != 1 {
+		t.Errorf("server has %d connections, want 1", len(conns))
+	}
+}
+
+func TestQuicTransportRequiresTLS(t *testing.T) {
+	tr := &Transport{}
+	if _, err := tr.Listen(freeUDPAddress(t), &transport.TarsServerConf{}); err != errTLSConfig {
+		t.Errorf("Listen without tls config: %v", err)
+	}
+	if _, err := tr.Dial(freeUDPAddress(t), nil, &transport.TarsClientConf{}); err != errTLSConfig {
+		t.Errorf("Dial without tls config: %v", err)
+	}
+}
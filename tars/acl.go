@@ -0,0 +1,114 @@
+package tars
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/acl"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+	"github.com/TarsCloud/TarsGo/tars/util/conf"
+	"github.com/TarsCloud/TarsGo/tars/util/current"
+)
+
+// SetACL sets the ip access control list of the servant, the allowed and the denied ranges are the CIDRs
+// or the ips separated by commas. The empty ranges remove the list.
+func SetACL(servant, allow, deny string) error {
+	l, err := acl.ParseList(allow, deny)
+	if err != nil {
+		return err
+	}
+	defaultApp.acls.Set(servant, l)
+	return nil
+}
+
+// GetACL returns the ip access control list of the servant, nil if there is none.
+func GetACL(servant string) *acl.List {
+	return defaultApp.acls.Get(servant)
+}
+
+// parseACLs parses the access control lists of the servants from the "allow" and "deny" of the adapters.
+func parseACLs(c *conf.Conf) map[string]*acl.List {
+	lists := make(map[string]*acl.List)
+	for _, adapter := range c.GetDomain("/tars/application/server") {
+		path := "/tars/application/server/" + adapter
+		obj := c.GetString(path + "<servant>")
+		if obj == "" {
+			continue
+		}
+		l, err := acl.ParseList(c.GetString(path+"<allow>"), c.GetString(path+"<deny>"))
+		if err != nil {
+			TLOG.Errorf("parse acl of %s error: %v", obj, err)
+			continue
+		}
+		lists[obj] = l
+	}
+	return lists
+}
+
+// reloadACLs reloads the access control lists from the server config file.
+func (a *application) reloadACLs() error {
+	c, err := conf.NewConf(ServerConfigPath)
+	if err != nil {
+		return err
+	}
+	a.acls.Reset(parseACLs(c))
+	return nil
+}
+
+// setACL sets the list by the admin command arguments "servant [allow ranges] [deny ranges]".
+func (a *application) setACL(args []string) error {
+	var allow, deny []string
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return fmt.Errorf("missing ranges of %s", args[i])
+		}
+		switch args[i] {
+		case "allow":
+			allow = append(allow, args[i+1])
+		case "deny":
+			deny = append(deny, args[i+1])
+		default:
+			return fmt.Errorf("unknown list %s, want allow or deny", args[i])
+		}
+	}
+	l, err := acl.ParseList(strings.Join(allow, ","), strings.Join(deny, ","))
+	if err != nil {
+		return err
+	}
+	a.acls.Set(args[0], l)
+	return nil
+}
+
+// checkAccess checks the client ip against the list of the servant, and reports the rejected one.
+func (a *application) checkAccess(obj, ip, from string) bool {
+	if a.acls.Allowed(obj, ip) {
+		return true
+	}
+	TLOG.Debugf("access denied, obj: %s, %s from %s", obj, from, ip)
+	// the notifications are throttled, a denied client may keep retrying
+	if a.aclNotify.Allow(time.Now()) {
+		go ReportNotifyInfo(NotifyWarn, fmt.Sprintf("access denied, obj: %s, %s from %s", obj, from, ip))
+	}
+	return false
+}
+
+// connFilter checks the connections of the servant once accepted.
+func (a *application) connFilter(obj string) func(conn net.Conn) bool {
+	return func(conn net.Conn) bool {
+		ip, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+		if err != nil {
+			// the peer of the unix socket has no ip
+			ip = acl.Unix
+		}
+		return a.checkAccess(obj, ip, "connection")
+	}
+}
+
+// allowed checks the request before dispatching, so that the connections accepted before the list changes are checked.
+func (s *Protocol) allowed(ctx context.Context, req *requestf.RequestPacket) bool {
+	ip, _ := current.GetClientIPFromContext(ctx)
+	return s.app.checkAccess(s.servant(req), ip, "request "+req.SFuncName)
+}
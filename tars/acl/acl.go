@@ -0,0 +1,219 @@
+// Package acl implements the ip access control lists of the servants.
+package acl
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Unix is the client ip of the unix socket connections, which is matched by "unix" in the lists.
+const Unix = "unix"
+
+// List is the ip access control list of a servant. The denied ranges take precedence over the allowed ones,
+// and all the ips not denied are allowed if there is no allowed range.
+type List struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+	// AllowUnix and DenyUnix match the peers of the unix socket connections.
+	AllowUnix bool
+	DenyUnix  bool
+}
+
+// ParseList parses the allowed and the denied ranges, which are the CIDRs or the ips separated by commas or spaces,
+// and "unix" for the unix socket peers.
+func ParseList(allow, deny string) (*List, error) {
+	var (
+		l   = &List{}
+		err error
+	)
+	allow, l.AllowUnix = cutUnix(allow)
+	if l.Allow, err = ParseRanges(allow); err != nil {
+		return nil, err
+	}
+	deny, l.DenyUnix = cutUnix(deny)
+	if l.Deny, err = ParseRanges(deny); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// cutUnix removes "unix" from the ranges, and returns whether it is found.
+func cutUnix(s string) (string, bool) {
+	var (
+		fields []string
+		found  bool
+	)
+	for _, field := range splitRanges(s) {
+		if field == Unix {
+			found = true
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return strings.Join(fields, ","), found
+}
+
+func splitRanges(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+}
+
+// ParseRanges parses the CIDRs or the ips separated by commas or spaces, the ip is taken as a single address range.
+func ParseRanges(s string) ([]*net.IPNet, error) {
+	var ranges []*net.IPNet
+	for _, field := range splitRanges(s) {
+		if !strings.Contains(field, "/") {
+			ip := net.ParseIP(field)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip %q", field)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			ranges = append(ranges, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", field)
+		}
+		ranges = append(ranges, ipNet)
+	}
+	return ranges, nil
+}
+
+// Empty returns true if the list has no range.
+func (l *List) Empty() bool {
+	return l == nil || (len(l.Allow) == 0 && len(l.Deny) == 0 && !l.AllowUnix && !l.DenyUnix)
+}
+
+// Allowed checks the ip against the list, the invalid ip is only allowed if there is no allowed range.
+func (l *List) Allowed(ip net.IP) bool {
+	if l == nil {
+		return true
+	}
+	if ip == nil {
+		return l.noAllow()
+	}
+	if contains(l.Deny, ip) {
+		return false
+	}
+	return l.noAllow() || contains(l.Allow, ip)
+}
+
+// AllowedUnix checks the peer of the unix socket connection against the list.
+func (l *List) AllowedUnix() bool {
+	if l == nil {
+		return true
+	}
+	if l.DenyUnix {
+		return false
+	}
+	return l.noAllow() || l.AllowUnix
+}
+
+func (l *List) noAllow() bool {
+	return len(l.Allow) == 0 && !l.AllowUnix
+}
+
+// String returns the list in the form of "allow ranges deny ranges".
+func (l *List) String() string {
+	var sb strings.Builder
+	if len(l.Allow) > 0 || l.AllowUnix {
+		sb.WriteString("allow " + join(l.Allow, l.AllowUnix))
+	}
+	if len(l.Deny) > 0 || l.DenyUnix {
+		if sb.Len() > 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString("deny " + join(l.Deny, l.DenyUnix))
+	}
+	return sb.String()
+}
+
+func contains(ranges []*net.IPNet, ip net.IP) bool {
+	for _, r := range ranges {
+		if r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func join(ranges []*net.IPNet, unix bool) string {
+	s := make([]string, 0, len(ranges)+1)
+	for _, r := range ranges {
+		s = append(s, r.String())
+	}
+	if unix {
+		s = append(s, Unix)
+	}
+	return strings.Join(s, ",")
+}
+
+// Table is the access control lists of the servants, which can be changed at runtime.
+type Table struct {
+	mu    sync.RWMutex
+	lists map[string]*List
+}
+
+// NewTable returns an empty table.
+func NewTable() *Table {
+	return &Table{lists: make(map[string]*List)}
+}
+
+// Set sets the list of the servant, the empty list removes it.
+func (t *Table) Set(servant string, l *List) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if l.Empty() {
+		delete(t.lists, servant)
+		return
+	}
+	t.lists[servant] = l
+}
+
+// Reset replaces all the lists.
+func (t *Table) Reset(lists map[string]*List) {
+	t.mu.Lock()
+	t.lists = make(map[string]*List)
+	t.mu.Unlock()
+	for servant, l := range lists {
+		t.Set(servant, l)
+	}
+}
+
+// Get returns the list of the servant, nil if there is none.
+func (t *Table) Get(servant string) *List {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.lists[servant]
+}
+
+// Servants returns the servants with the lists in order.
+func (t *Table) Servants() []string {
+	t.mu.RLock()
+	servants := make([]string, 0, len(t.lists))
+	for servant := range t.lists {
+		servants = append(servants, servant)
+	}
+	t.mu.RUnlock()
+	sort.Strings(servants)
+	return servants
+}
+
+// Allowed checks the client ip against the list of the servant, all the ips are allowed if the servant has no list.
+// The ip is Unix for the unix socket connections.
+func (t *Table) Allowed(servant, ip string) bool {
+	l := t.Get(servant)
+	if l == nil {
+		return true
+	}
+	if ip == Unix {
+		return l.AllowedUnix()
+	}
+	return l.Allowed(net.ParseIP(ip))
+}
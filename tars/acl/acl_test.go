@@ -0,0 +1,71 @@
+package acl
+
+import (
+	"testing"
+)
+
+func TestTable(t *testing.T) {
+	l, err := ParseList("10.0.0.0/8, 192.168.1.1", "10.1.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := l.String(), "allow 10.0.0.0/8,192.168.1.1/32 deny 10.1.0.0/16"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	table := NewTable()
+	table.Set("App.Server.Obj", l)
+	cases := []struct {
+		servant string
+		ip      string
+		want    bool
+	}{
+		{"App.Server.Obj", "10.2.3.4", true},
+		{"App.Server.Obj", "192.168.1.1", true},
+		{"App.Server.Obj", "10.1.2.3", false},
+		{"App.Server.Obj", "192.168.1.2", false},
+		{"App.Server.Obj", "invalid", false},
+		{"App.Server.Other", "192.168.1.2", true},
+	}
+	for _, c := range cases {
+		if got := table.Allowed(c.servant, c.ip); got != c.want {
+			t.Errorf("Allowed(%s, %s) = %v, want %v", c.servant, c.ip, got, c.want)
+		}
+	}
+
+	// the deny list allows the others
+	l, _ = ParseList("", "::1")
+	table.Set("App.Server.Obj", l)
+	if table.Allowed("App.Server.Obj", "::1") || !table.Allowed("App.Server.Obj", "10.1.2.3") {
+		t.Error("deny list mismatch")
+	}
+
+	// the unix socket peers are matched by "unix" only
+	if !table.Allowed("App.Server.Obj", Unix) {
+		t.Error("unix peer is denied by the ip deny list")
+	}
+	l, _ = ParseList("10.0.0.0/8", "")
+	table.Set("App.Server.Obj", l)
+	if table.Allowed("App.Server.Obj", Unix) {
+		t.Error("unix peer is allowed without unix in the allow list")
+	}
+	l, _ = ParseList("10.0.0.0/8 unix", "")
+	table.Set("App.Server.Obj", l)
+	if got, want := l.String(), "allow 10.0.0.0/8,unix"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if !table.Allowed("App.Server.Obj", Unix) || table.Allowed("App.Server.Obj", "127.0.0.1") {
+		t.Error("unix allow list mismatch")
+	}
+	l, _ = ParseList("", "unix")
+	table.Set("App.Server.Obj", l)
+	if table.Allowed("App.Server.Obj", Unix) || !table.Allowed("App.Server.Obj", "127.0.0.1") {
+		t.Error("unix deny list mismatch")
+	}
+	table.Set("App.Server.Obj", &List{})
+	if len(table.Servants()) != 0 {
+		t.Error("empty list is not removed")
+	}
+	if _, err = ParseList("10.0.0.0/33", ""); err == nil {
+		t.Error("invalid CIDR is parsed")
+	}
+}
@@ -0,0 +1,121 @@
+package tars
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+	"github.com/TarsCloud/TarsGo/tars/util/conf"
+	"github.com/TarsCloud/TarsGo/tars/util/current"
+)
+
+func TestParseACLs(t *testing.T) {
+	c := conf.New()
+	err := c.InitFromString(`<tars><application><server>
+<App.Server.ObjAdapter>
+servant=App.Server.Obj
+allow=10.0.0.0/8,127.0.0.1
+deny=10.1.0.0/16
+</App.Server.ObjAdapter>
+<App.Server.BadAdapter>
+servant=App.Server.Bad
+allow=10.0.0.0/33
+</App.Server.BadAdapter>
+</server></application></tars>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lists := parseACLs(c)
+	if len(lists) != 1 || lists["App.Server.Obj"].String() != "allow 10.0.0.0/8,127.0.0.1/32 deny 10.1.0.0/16" {
+		t.Errorf("lists = %v", lists)
+	}
+}
+
+func TestProtocolACL(t *testing.T) {
+	app := newApp()
+	server := NewTarsProtocol(&echoDispatcher{}, nil, false)
+	server.app = app
+	server.obj = "App.Server.Obj"
+	proto := &protocol.TarsProtocol{}
+
+	invoke := func(ip string) int32 {
+		pkg, err := proto.RequestPack(&requestf.RequestPacket{
+			IVersion:     basef.TARSVERSION,
+			IRequestId:   1,
+			SServantName: "App.Server.Obj",
+			SFuncName:    "Echo",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := current.ContextWithTarsCurrent(context.Background())
+		current.SetClientIPWithContext(ctx, ip)
+		rsp, err := proto.ResponseUnpack(server.Invoke(ctx, pkg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rsp.IRet
+	}
+	if err := app.setACL([]string{"App.Server.Obj", "allow", "10.0.0.0/8", "deny", "10.1.0.0/16"}); err != nil {
+		t.Fatal(err)
+	}
+	if ret := invoke("10.2.0.1"); ret != 0 {
+		t.Errorf("allowed ip returns %d", ret)
+	}
+	if ret := invoke("10.1.0.1"); ret != basef.TARSSERVERACCESSDENIED {
+		t.Errorf("denied ip returns %d", ret)
+	}
+	// the list is removed without ranges
+	if err := app.setACL([]string{"App.Server.Obj"}); err != nil {
+		t.Fatal(err)
+	}
+	if ret := invoke("10.1.0.1"); ret != 0 {
+		t.Errorf("ip returns %d after the list is removed", ret)
+	}
+	if err := app.setACL([]string{"App.Server.Obj", "allow"}); err == nil {
+		t.Error("missing ranges are accepted")
+	}
+}
+
+func TestStreamACL(t *testing.T) {
+	app := newApp()
+	server := NewTarsProtocol(&echoDispatcher{}, nil, false)
+	server.app = app
+	server.obj = "App.Server.Obj"
+	proto := &protocol.TarsProtocol{}
+	if err := app.setACL([]string{"App.Server.Obj", "deny", "10.1.0.0/16"}); err != nil {
+		t.Fatal(err)
+	}
+	sconn, cconn := net.Pipe()
+	defer sconn.Close()
+	defer cconn.Close()
+
+	pkg, err := proto.RequestPack(&requestf.RequestPacket{
+		IVersion:     basef.TARSVERSION,
+		CPacketType:  basef.TARSONEWAY,
+		IRequestId:   1,
+		SServantName: "App.Server.Obj",
+		SFuncName:    "Chat",
+		Status:       (&streamFrame{kind: streamOpen, window: streamRecvWindow}).status(nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := current.ContextWithTarsCurrent(context.Background())
+	current.SetClientIPWithContext(ctx, "10.1.0.1")
+	current.SetRawConnWithContext(ctx, sconn, nil)
+	go server.Invoke(ctx, pkg)
+
+	cconn.SetReadDeadline(time.Now().Add(time.Second))
+	rsp, err := readResponse(cconn, proto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Status[streamFrameKey] != streamReset || rsp.IRet != basef.TARSSERVERACCESSDENIED {
+		t.Errorf("denied stream gets %v %d, want the reset frame of access denied", rsp.Status, rsp.IRet)
+	}
+}
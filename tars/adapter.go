@@ -3,10 +3,12 @@ package tars
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/TarsCloud/TarsGo/tars/circuitbreaker"
 	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
 	"github.com/TarsCloud/TarsGo/tars/protocol/res/endpointf"
 	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
@@ -19,22 +21,26 @@ import (
 // AdapterProxy : Adapter proxy
 type AdapterProxy struct {
 	resp              sync.Map
+	streams           sync.Map
 	point             *endpointf.EndpointF
 	tarsClient        *transport.TarsClient
 	conf              *transport.TarsClientConf
 	comm              *Communicator
 	servantProxy      *ServantProxy
+	objName           string
 	failCount         int32
-	lastFailCount     int32
 	sendCount         int32
 	successCount      int32
-	status            bool // true for good
+	inflight          int32
+	ewmaCost          int64 // moving average of response time in nanoseconds
+	breaker           circuitbreaker.CircuitBreaker
+	breakerState      circuitbreaker.State // the state observed by the last checkActive
 	lastSuccessTime   int64
-	lastBlockTime     int64
-	lastCheckTime     int64
 	lastKeepAliveTime int64
 	pushCallback      func([]byte)
 	onceKeepAlive     sync.Once
+	compressNames     atomic.Value // the compressors supported by the server
+	probing           bool         // the blocked endpoint is probed by the endpoint manager
 
 	closed bool
 }
@@ -44,11 +50,16 @@ func NewAdapterProxy(objName string, point *endpointf.EndpointF, comm *Communica
 	c := &AdapterProxy{}
 	c.comm = comm
 	c.point = point
+	c.objName = objName
 	proto := "tcp"
 	if point.Istcp == endpoint.UDP {
 		proto = "udp"
 	} else if point.Istcp == endpoint.SSL {
 		proto = "ssl"
+	} else if point.Istcp == endpoint.UNIX {
+		proto = "unix"
+	} else if point.Istcp == endpoint.QUIC {
+		proto = "quic"
 	}
 	conf := &transport.TarsClientConf{
 		Proto:        proto,
@@ -57,20 +68,37 @@ func NewAdapterProxy(objName string, point *endpointf.EndpointF, comm *Communica
 		ReadTimeout:  comm.Client.ClientReadTimeout,
 		WriteTimeout: comm.Client.ClientWriteTimeout,
 		DialTimeout:  comm.Client.ClientDialTimeout,
+		Connections:  comm.Client.Connections,
 	}
-	if point.Istcp == endpoint.SSL {
+	if n, ok := comm.app.clientObjConnections[objName]; ok {
+		conf.Connections = n
+	}
+	if point.Istcp == endpoint.SSL || point.Istcp == endpoint.QUIC {
 		if tlsConfig, ok := comm.app.clientObjTlsConfig[objName]; ok {
 			conf.TlsConfig = tlsConfig
 		} else {
 			conf.TlsConfig = comm.app.clientTlsConfig
 		}
 	}
+	// the local auth is enabled by the endpoint from the registry or the client config of the obj
+	info := comm.app.clientObjInfo[objName]
+	if endpoint.AuthType(point.AuthType) == endpoint.AuthTypeLocal || info["authtype"] == strconv.Itoa(int(endpoint.AuthTypeLocal)) {
+		conf.Handshake = c.authHandshake(info["accesskey"], info["secretkey"])
+	}
 	c.conf = conf
-	c.tarsClient = transport.NewTarsClient(fmt.Sprintf("%s:%d", point.Host, point.Port), c, conf)
-	c.status = true
+	c.tarsClient = transport.NewTarsClient(adapterAddress(point), c, conf)
+	c.breaker = newDefaultCircuitBreaker()
 	return c
 }
 
+// adapterAddress returns the address to dial, which is the socket path for the unix domain socket.
+func adapterAddress(point *endpointf.EndpointF) string {
+	if point.Istcp == endpoint.UNIX {
+		return point.Host
+	}
+	return fmt.Sprintf("%s:%d", point.Host, point.Port)
+}
+
 // ParsePackage : Parse packet from bytes
 func (c *AdapterProxy) ParsePackage(buff []byte) (int, int) {
 	return c.servantProxy.proto.ParsePackage(buff)
@@ -94,9 +122,14 @@ func (c *AdapterProxy) Recv(pkg []byte) {
 		c.onPush(packet)
 		return
 	}
+	if _, ok := packet.Status[streamFrameKey]; ok {
+		c.onStreamFrame(packet)
+		return
+	}
 	if packet.CPacketType == basef.TARSONEWAY {
 		return
 	}
+	c.decompressResponse(packet)
 	chIF, ok := c.resp.Load(packet.IRequestId)
 	if ok {
 		ch := chIF.(chan *requestf.ResponsePacket)
@@ -115,11 +148,37 @@ func (c *AdapterProxy) Recv(pkg []byte) {
 
 // Send : Send packet
 func (c *AdapterProxy) Send(req *requestf.RequestPacket) error {
+	_, err := c.send(req)
+	return err
+}
+
+// send sends the packet, and returns the id of the connection which it is sent on.
+func (c *AdapterProxy) send(req *requestf.RequestPacket) (int32, error) {
 	TLOG.Debug("send req:", req.IRequestId)
 	c.sendAdd()
 	sbuf, err := c.servantProxy.proto.RequestPack(req)
 	if err != nil {
 		TLOG.Debug("protocol wrong:", req.IRequestId)
+		return 0, err
+	}
+	conn := c.tarsClient.Pick()
+	return conn, c.tarsClient.SendOn(conn, sbuf)
+}
+
+// sendFrame sends the stream frame, which is not counted by the circuit breaker.
+func (c *AdapterProxy) sendFrame(req *requestf.RequestPacket) error {
+	sbuf, err := c.servantProxy.proto.RequestPack(req)
+	if err != nil {
+		return err
+	}
+	// all the frames of a stream must be sent on the same connection
+	return c.tarsClient.SendOn(req.IRequestId, sbuf)
+}
+
+// sendPing sends the heartbeat or the probe, which is not counted by the circuit breaker.
+func (c *AdapterProxy) sendPing(req *requestf.RequestPacket) error {
+	sbuf, err := c.servantProxy.proto.RequestPack(req)
+	if err != nil {
 		return err
 	}
 	return c.tarsClient.Send(sbuf)
@@ -132,24 +191,52 @@ func (c *AdapterProxy) GetPoint() *endpointf.EndpointF {
 
 // Close the client
 func (c *AdapterProxy) Close() {
+	c.closeStreams()
 	c.tarsClient.Close()
 	c.closed = true
 }
 
 func (c *AdapterProxy) sendAdd() {
 	atomic.AddInt32(&c.sendCount, 1)
+	c.breaker.OnSend()
 }
 
-func (c *AdapterProxy) successAdd() {
+func (c *AdapterProxy) successAdd(cost time.Duration) {
 	now := time.Now().Unix()
 	atomic.SwapInt64(&c.lastSuccessTime, now)
 	atomic.AddInt32(&c.successCount, 1)
-	atomic.SwapInt32(&c.lastFailCount, 0)
+	c.updateCost(cost)
+	c.breaker.OnSuccess(cost)
 }
 
-func (c *AdapterProxy) failAdd() {
-	atomic.AddInt32(&c.lastFailCount, 1)
+func (c *AdapterProxy) failAdd(cost time.Duration) {
 	atomic.AddInt32(&c.failCount, 1)
+	// failures are counted as slow responses, so that the endpoint gets less requests
+	if cost < loadFailPenalty {
+		c.updateCost(loadFailPenalty)
+	} else {
+		c.updateCost(cost)
+	}
+	c.breaker.OnFailure(cost)
+}
+
+// updateCost updates the moving average of response time.
+func (c *AdapterProxy) updateCost(cost time.Duration) {
+	for {
+		old := atomic.LoadInt64(&c.ewmaCost)
+		v := int64(cost)
+		if old > 0 {
+			v = old + int64(float64(int64(cost)-old)*loadEWMAAlpha)
+		}
+		if atomic.CompareAndSwapInt64(&c.ewmaCost, old, v) {
+			return
+		}
+	}
+}
+
+// load returns the number of in-flight requests and the moving average of response time.
+func (c *AdapterProxy) load() (int64, time.Duration) {
+	return int64(atomic.LoadInt32(&c.inflight)), time.Duration(atomic.LoadInt64(&c.ewmaCost))
 }
 
 func (c *AdapterProxy) reset() {
@@ -157,11 +244,13 @@ func (c *AdapterProxy) reset() {
 	atomic.SwapInt32(&c.sendCount, 0)
 	atomic.SwapInt32(&c.successCount, 0)
 	atomic.SwapInt32(&c.failCount, 0)
-	atomic.SwapInt32(&c.lastFailCount, 0)
-	atomic.SwapInt64(&c.lastBlockTime, now)
-	atomic.SwapInt64(&c.lastCheckTime, now)
 	atomic.SwapInt64(&c.lastKeepAliveTime, now)
-	c.status = true
+	c.breaker.Reset()
+}
+
+// isActive returns whether the adapter proxy can be selected, which means its circuit breaker is closed.
+func (c *AdapterProxy) isActive() bool {
+	return c.breaker.State() == circuitbreaker.StateClosed
 }
 
 func (c *AdapterProxy) checkActive() (firstTime bool, needCheck bool) {
@@ -169,45 +258,70 @@ func (c *AdapterProxy) checkActive() (firstTime bool, needCheck bool) {
 		return false, false
 	}
 
-	now := time.Now().Unix()
-	if c.status {
-		//check if healthy，fail 5 times in a row within 5s
-		if (now-c.lastSuccessTime) >= failInterval && c.lastFailCount >= fainN {
-			c.status = false
-			c.lastBlockTime = now
-			return true, false
-		}
-		// lgt 60s
-		if (now - c.lastCheckTime) >= checkTime {
-			c.lastBlockTime = now
-			// The number of failures is more than 2 and the failure rate is more than 50%
-			if c.failCount >= overN && (float32(c.failCount)/float32(c.sendCount)) >= failRatio {
-				c.status = false
-				return true, false
-			}
-			return false, false
-		}
-		return false, false
+	last := c.breakerState
+	var state circuitbreaker.State
+	if c.probing && last != circuitbreaker.StateClosed {
+		// the prober closes the breaker after the endpoint passes the probes
+		state = c.breaker.State()
+	} else {
+		state = c.breaker.Check(time.Now())
 	}
-
-	// lgt 30s
-	if (now - c.lastBlockTime) >= tryTimeInterval {
-		c.lastBlockTime = now
+	if state == circuitbreaker.StateHalfOpen && last != circuitbreaker.StateHalfOpen {
+		// reconnect before the endpoint is checked by a request
 		if err := c.tarsClient.ReConnect(); err != nil {
-			return false, false
+			c.breaker.OnFailure(0)
+			state = c.breaker.State()
+		} else {
+			needCheck = true
 		}
-
-		return false, true
 	}
+	if state != last {
+		c.breakerState = state
+		c.comm.app.onBreakerStateChange(c, last, state)
+	}
+	return last == circuitbreaker.StateClosed && state != circuitbreaker.StateClosed, needCheck
+}
 
-	return false, false
+// ping sends tars_ping and waits for the response, health asks for the serving status of the servant
+// and fails if it is not serving. The servers which do not report the status are taken as serving once they reply.
+func (c *AdapterProxy) ping(timeout time.Duration, health bool) error {
+	sp := c.servantProxy
+	req := requestf.RequestPacket{
+		IVersion:     sp.version,
+		CPacketType:  basef.TARSNORMAL,
+		IRequestId:   sp.genRequestID(),
+		SServantName: sp.name,
+		SFuncName:    "tars_ping",
+		ITimeout:     int32(timeout / time.Millisecond),
+	}
+	if health {
+		req.Status = map[string]string{StatusHealthKey: ""}
+	}
+	readCh := make(chan *requestf.ResponsePacket, 1)
+	c.resp.Store(req.IRequestId, readCh)
+	defer c.resp.Delete(req.IRequestId)
+	if err := c.sendPing(&req); err != nil {
+		return err
+	}
+	select {
+	case rsp := <-readCh:
+		if rsp.IRet != basef.TARSSERVERSUCCESS {
+			return fmt.Errorf("ping error %d: %s", rsp.IRet, rsp.SResultDesc)
+		}
+		if status, ok := rsp.Status[StatusHealthKey]; ok && ParseHealthStatus(status) != HealthServing {
+			return fmt.Errorf("%s: %s", status, rsp.SResultDesc)
+		}
+		return nil
+	case <-rtimer.After(timeout):
+		return fmt.Errorf("ping timeout in %v", timeout)
+	}
 }
 
 func (c *AdapterProxy) onPush(pkg *requestf.ResponsePacket) {
 	if pkg.SResultDesc == reconnectMsg {
 		TLOG.Infof("reconnect %s:%d", c.point.Host, c.point.Port)
 		oldClient := c.tarsClient
-		c.tarsClient = transport.NewTarsClient(fmt.Sprintf("%s:%d", c.point.Host, c.point.Port), c, c.conf)
+		c.tarsClient = transport.NewTarsClient(adapterAddress(c.point), c, c.conf)
 
 		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*ClientIdleTimeout)
 		defer cancel()
@@ -267,9 +381,7 @@ func (c *AdapterProxy) doKeepAlive() {
 		CheckPanic()
 		atomic.AddInt32(&c.servantProxy.queueLen, -1)
 	}()
-	if err := c.Send(msg.Req); err != nil {
-		c.failAdd()
-		return
+	if err := c.sendPing(msg.Req); err != nil {
+		TLOG.Debugf("keep alive %s:%d error: %v", c.point.Host, c.point.Port, err)
 	}
-	c.successAdd()
 }
@@ -0,0 +1,51 @@
+package tars
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/circuitbreaker"
+	"github.com/TarsCloud/TarsGo/tars/protocol"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/util/endpoint"
+)
+
+// countBreaker counts the calls, and never opens.
+type countBreaker struct {
+	sends, successes, failures int32
+}
+
+func (b *countBreaker) State() circuitbreaker.State          { return circuitbreaker.StateClosed }
+func (b *countBreaker) OnSend()                              { atomic.AddInt32(&b.sends, 1) }
+func (b *countBreaker) OnSuccess(time.Duration)              { atomic.AddInt32(&b.successes, 1) }
+func (b *countBreaker) OnFailure(time.Duration)              { atomic.AddInt32(&b.failures, 1) }
+func (b *countBreaker) Check(time.Time) circuitbreaker.State { return circuitbreaker.StateClosed }
+func (b *countBreaker) Reset()                               {}
+func (b *countBreaker) counts() (sends, successes, failures int32) {
+	return atomic.LoadInt32(&b.sends), atomic.LoadInt32(&b.successes), atomic.LoadInt32(&b.failures)
+}
+
+func TestKeepAliveBreaker(t *testing.T) {
+	app := newApp()
+	server := NewTarsProtocol(&echoDispatcher{}, nil, false)
+	server.app = app
+	address := startServer(t, server)
+
+	comm := newCommunicator(app, app.cltCfg)
+	point := endpoint.Endpoint2tars(endpoint.Parse("tcp -h 127.0.0.1 -p " + strconv.Itoa(address.Port)))
+	adp := NewAdapterProxy("App.Server.Obj", &point, comm)
+	defer adp.Close()
+	adp.servantProxy = &ServantProxy{name: "App.Server.Obj", comm: comm, version: basef.TARSVERSION, proto: &protocol.TarsProtocol{}}
+	b := &countBreaker{}
+	adp.breaker = b
+
+	adp.doKeepAlive()
+	if atomic.LoadInt64(&adp.lastKeepAliveTime) == 0 {
+		t.Fatal("keep alive is not sent")
+	}
+	if sends, successes, failures := b.counts(); sends != 0 || successes != 0 || failures != 0 {
+		t.Errorf("keep alive is counted by the breaker, sends: %d, successes: %d, failures: %d", sends, successes, failures)
+	}
+}
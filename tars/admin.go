@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/pprof"
+	"sort"
 	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
 
+	"github.com/TarsCloud/TarsGo/tars/ratelimit"
 	"github.com/TarsCloud/TarsGo/tars/util/debug"
 	"github.com/TarsCloud/TarsGo/tars/util/rogger"
 )
@@ -81,8 +83,46 @@ func (a *Admin) Notify(command string) (string, error) {
 			return fmt.Sprintf("Getconfig Error!: %s", cmd[1]), err
 		}
 		return fmt.Sprintf("Getconfig Success!: %s", cmd[1]), nil
+	case "tars.setquota":
+		// tars.setquota servant func caller rate [burst], the zero rate removes the quota, and no argument lists the quotas
+		if len(cmd) == 1 {
+			var sb strings.Builder
+			for _, q := range a.app.quotas.List() {
+				sb.WriteString(q.String())
+				sb.WriteString("\n")
+			}
+			return sb.String(), nil
+		}
+		q, err := ratelimit.ParseQuota(strings.Join(cmd[1:], " "))
+		if err != nil {
+			return fmt.Sprintf("%s failed: %v", command, err), nil
+		}
+		a.app.quotas.Set(q)
+		return fmt.Sprintf("%s succ", command), nil
+	case "tars.setacl":
+		// tars.setacl servant [allow ranges] [deny ranges], no ranges removes the list, reload reloads the lists
+		// from the config file, and no argument lists them
+		if len(cmd) == 1 {
+			var sb strings.Builder
+			for _, servant := range a.app.acls.Servants() {
+				sb.WriteString(servant + " " + a.app.acls.Get(servant).String())
+				sb.WriteString("\n")
+			}
+			return sb.String(), nil
+		}
+		var err error
+		if cmd[1] == "reload" {
+			err = a.app.reloadACLs()
+		} else {
+			err = a.app.setACL(cmd[1:])
+		}
+		if err != nil {
+			return fmt.Sprintf("%s failed: %v", command, err), nil
+		}
+		return fmt.Sprintf("%s succ", command), nil
 	case "tars.connection":
-		return fmt.Sprintf("%s not support now!", command), nil
+		// tars.connection [servant] lists the live connections of the servant, or of all the servants
+		return a.listConnections(cmd[1:]), nil
 	case "tars.gracerestart":
 		a.app.graceRestart()
 		return "restart gracefully!", nil
@@ -122,3 +162,26 @@ func (a *Admin) Notify(command string) (string, error) {
 		return fmt.Sprintf("%s not support now!", command), nil
 	}
 }
+
+func (a *Admin) listConnections(objs []string) string {
+	if len(objs) == 0 {
+		for obj := range a.app.goSvrs {
+			objs = append(objs, obj)
+		}
+		sort.Strings(objs)
+	}
+	var sb strings.Builder
+	for _, obj := range objs {
+		s, ok := a.app.goSvrs[obj]
+		if !ok {
+			fmt.Fprintf(&sb, "%s: servant not found\n", obj)
+			continue
+		}
+		conns := s.Connections()
+		fmt.Fprintf(&sb, "%s: connections %d, rejected %d\n", obj, len(conns), s.RejectedConns())
+		for _, c := range conns {
+			fmt.Fprintf(&sb, "  %s idle:%v invoke:%d\n", c.RemoteAddr, c.IdleTime, c.NumInvoke)
+		}
+	}
+	return sb.String()
+}
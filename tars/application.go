@@ -19,8 +19,12 @@ import (
 
 	"go.uber.org/automaxprocs/maxprocs"
 
+	"github.com/TarsCloud/TarsGo/tars/acl"
+	"github.com/TarsCloud/TarsGo/tars/auth"
+	"github.com/TarsCloud/TarsGo/tars/circuitbreaker"
 	"github.com/TarsCloud/TarsGo/tars/protocol"
 	"github.com/TarsCloud/TarsGo/tars/protocol/res/adminf"
+	"github.com/TarsCloud/TarsGo/tars/ratelimit"
 	"github.com/TarsCloud/TarsGo/tars/transport"
 	"github.com/TarsCloud/TarsGo/tars/util/conf"
 	"github.com/TarsCloud/TarsGo/tars/util/endpoint"
@@ -50,18 +54,40 @@ type application struct {
 	clientObjTlsConfig map[string]*tls.Config
 	clientTlsConfig    *tls.Config
 
+	clientObjRetryPolicy map[string]*RetryPolicy
+	clientObjHedgePolicy map[string]*HedgePolicy
+	clientObjBreaker     map[string]circuitbreaker.Factory
+	clientObjSelector    map[string]string
+	clientObjConnections map[string]int
+	clientObjCompress    map[string]*CompressPolicy
+	clientObjProbePolicy map[string]*ProbePolicy
+
 	rConf     *RConf
 	onceRConf sync.Once
 
-	appCache         AppCache
-	destroyableObjs  []destroyableImp
-	adminMethods     map[string]adminFn
-	allFilters       *filters
-	dispatchReporter DispatchReporter
+	appCache           AppCache
+	destroyableObjs    []destroyableImp
+	adminMethods       map[string]adminFn
+	allFilters         *filters
+	dispatchReporter   DispatchReporter
+	breakerHooks       []CircuitBreakerHook
+	quotas             *ratelimit.Quotas
+	acls               *acl.Table
+	aclNotify          *ratelimit.TokenBucket
+	authProvider       AuthProvider
+	localAuth          *auth.LocalProvider
+	priorityClassifier PriorityClassifier
+	queueReporter      queueReporter
+	health             *healthRegistry
+	healthSvr          *http.Server
 
 	shutdown          chan bool
 	isShutdownByAdmin int32
 	isShutdowning     int32
+	ready             int32
+	shutdownPhase     int32
+	shutdownHooksMu   sync.RWMutex
+	shutdownHooks     [shutdownPhaseNum][]ShutdownHook
 	shutdownOnce      sync.Once
 	initOnce          sync.Once
 }
@@ -82,17 +108,29 @@ func init() {
 
 func newApp() *application {
 	return &application{
-		opt:                &options{},
-		cltCfg:             newClientConfig(),
-		svrCfg:             newServerConfig(),
-		tarsConfig:         make(map[string]*transport.TarsServerConf),
-		goSvrs:             make(map[string]*transport.TarsServer),
-		httpSvrs:           make(map[string]*http.Server),
-		clientObjInfo:      make(map[string]map[string]string),
-		clientObjTlsConfig: make(map[string]*tls.Config),
-		adminMethods:       make(map[string]adminFn),
-		shutdown:           make(chan bool, 1),
-		allFilters:         &filters{},
+		opt:                  &options{hookTimeout: tools.ParseTimeOut(LifecycleHookTimeout)},
+		cltCfg:               newClientConfig(),
+		svrCfg:               newServerConfig(),
+		tarsConfig:           make(map[string]*transport.TarsServerConf),
+		goSvrs:               make(map[string]*transport.TarsServer),
+		httpSvrs:             make(map[string]*http.Server),
+		clientObjInfo:        make(map[string]map[string]string),
+		clientObjTlsConfig:   make(map[string]*tls.Config),
+		clientObjRetryPolicy: make(map[string]*RetryPolicy),
+		clientObjHedgePolicy: make(map[string]*HedgePolicy),
+		clientObjBreaker:     make(map[string]circuitbreaker.Factory),
+		clientObjSelector:    make(map[string]string),
+		clientObjConnections: make(map[string]int),
+		clientObjCompress:    make(map[string]*CompressPolicy),
+		clientObjProbePolicy: make(map[string]*ProbePolicy),
+		adminMethods:         make(map[string]adminFn),
+		shutdown:             make(chan bool, 1),
+		allFilters:           &filters{},
+		quotas:               ratelimit.NewQuotas(),
+		acls:                 acl.NewTable(),
+		aclNotify:            ratelimit.NewTokenBucket(aclNotifyRate, aclNotifyBurst),
+		localAuth:            auth.NewLocalProvider(),
+		health:               newHealthRegistry(),
 	}
 }
 
@@ -205,6 +243,12 @@ func (a *application) parseServerConfig(c *conf.Conf) {
 	a.svrCfg.ZombieTimeout = tools.ParseTimeOut(c.GetIntWithDef("/tars/application/server<zombietimeout>", ZombieTimeout))
 	a.svrCfg.QueueCap = c.GetIntWithDef("/tars/application/server<queuecap>", QueueCap)
 	a.svrCfg.GracedownTimeout = tools.ParseTimeOut(c.GetIntWithDef("/tars/application/server<gracedowntimeout>", GracedownTimeout))
+	a.svrCfg.NotReadyTimeout = tools.ParseTimeOut(c.GetIntWithDef("/tars/application/server<notreadytimeout>", NotReadyTimeout))
+	a.svrCfg.DeregisterTimeout = tools.ParseTimeOut(c.GetIntWithDef("/tars/application/server<deregistertimeout>", DeregisterTimeout))
+	a.svrCfg.PropagationDelay = tools.ParseTimeOut(c.GetIntWithDef("/tars/application/server<propagationdelay>", PropagationDelay))
+	a.svrCfg.StopAcceptTimeout = tools.ParseTimeOut(c.GetIntWithDef("/tars/application/server<stopaccepttimeout>", StopAcceptTimeout))
+	a.svrCfg.DrainTimeout = tools.ParseTimeOut(c.GetIntWithDef("/tars/application/server<draintimeout>", DrainTimeout))
+	a.svrCfg.DestroyTimeout = tools.ParseTimeOut(c.GetIntWithDef("/tars/application/server<destroytimeout>", DestroyTimeout))
 
 	// add tcp config
 	a.svrCfg.TCPReadBuffer = c.GetIntWithDef("/tars/application/server<tcpreadbuffer>", TCPReadBuffer)
@@ -219,6 +263,24 @@ func (a *application) parseServerConfig(c *conf.Conf) {
 	a.svrCfg.StatReportChannelBufLen = c.GetInt32WithDef("/tars/application/server<statreportchannelbuflen>", StatReportChannelBufLen)
 	// maxPackageLength
 	a.svrCfg.MaxPackageLength = c.GetIntWithDef("/tars/application/server<maxPackageLength>", MaxPackageLength)
+	a.svrCfg.CompressThreshold = c.GetIntWithDef("/tars/application/server<compressthreshold>", CompressThreshold)
+	a.svrCfg.PriorityQueue = c.GetBoolWithDef("/tars/application/server<priorityqueue>", false)
+	a.svrCfg.PriorityMaxSkip = c.GetIntWithDef("/tars/application/server<prioritymaxskip>", PriorityMaxSkip)
+	// connection limits
+	a.svrCfg.MaxConns = c.GetInt("/tars/application/server<maxconns>")
+	a.svrCfg.MaxConnsPerIP = c.GetInt("/tars/application/server<maxconnsperip>")
+	a.svrCfg.AcceptRate = c.GetFloatWithDef("/tars/application/server<acceptrate>", 0)
+	a.svrCfg.AcceptBurst = c.GetInt("/tars/application/server<acceptburst>")
+	// rate limit quotas
+	a.quotas.Reset(parseQuotas(c, "/tars/application/server/quota"))
+	// ip access control lists
+	a.acls.Reset(parseACLs(c))
+	// the keys of the local auth, which are added by the adapters too
+	if authFile := c.GetString("/tars/application/server<authfile>"); authFile != "" {
+		if err := a.localAuth.LoadFile(authFile); err != nil {
+			TLOG.Errorf("load auth file error: %v", err)
+		}
+	}
 
 	// tls
 	a.svrCfg.Key = c.GetString("/tars/application/server<key>")
@@ -240,23 +302,55 @@ func (a *application) parseServerConfig(c *conf.Conf) {
 	a.svrCfg.SampleType = c.GetString("/tars/application/server<sampletype>")
 	a.svrCfg.SampleAddress = c.GetString("/tars/application/server<sampleaddress>")
 	a.svrCfg.SampleEncoding = c.GetStringWithDef("/tars/application/server<sampleencoding>", "json")
+	a.svrCfg.HealthAddress = c.GetString("/tars/application/server<healthaddress>")
 
-	serList := c.GetDomain("/tars/application/server")
-	for _, adapter := range serList {
+	var serList []string
+	for _, adapter := range c.GetDomain("/tars/application/server") {
 		endString := c.GetString("/tars/application/server/" + adapter + "<endpoint>")
+		if endString == "" {
+			// not an adapter, such as the quota domain
+			continue
+		}
+		serList = append(serList, adapter)
 		end := endpoint.Parse(endString)
 		svrObj := c.GetString("/tars/application/server/" + adapter + "<servant>")
 		proto := c.GetString("/tars/application/server/" + adapter + "<protocol>")
 		queuecap := c.GetIntWithDef("/tars/application/server/"+adapter+"<queuecap>", a.svrCfg.QueueCap)
 		threads := c.GetInt("/tars/application/server/" + adapter + "<threads>")
-		a.svrCfg.Adapters[adapter] = adapterConfig{end, proto, svrObj, threads}
+		a.svrCfg.Adapters[adapter] = adapterConfig{
+			Endpoint:       end,
+			Protocol:       proto,
+			Obj:            svrObj,
+			Threads:        threads,
+			HandleTimeout:  tools.ParseTimeOut(c.GetInt("/tars/application/server/" + adapter + "<handletimeout>")),
+			FuncTimeouts:   parseFuncTimeouts(c, "/tars/application/server/"+adapter+"/functimeout"),
+			FuncPriorities: parseFuncPriorities(c, "/tars/application/server/"+adapter+"/priority"),
+			AuthType:       endpoint.AuthType(c.GetIntWithDef("/tars/application/server/"+adapter+"<authtype>", int(end.AuthType))),
+		}
+		if accessKey := c.GetString("/tars/application/server/" + adapter + "<accesskey>"); accessKey != "" {
+			a.localAuth.Add(svrObj, accessKey, c.GetString("/tars/application/server/"+adapter+"<secretkey>"))
+		}
 		host := end.Host
 		if end.Bind != "" {
 			host = end.Bind
 		}
 		var opts []ServerConfOption
 		opts = append(opts, WithQueueCap(queuecap))
-		if end.IsSSL() {
+		if handler := c.GetString("/tars/application/server/" + adapter + "<handler>"); handler != "" {
+			opts = append(opts, WithHandler(handler))
+		}
+		if c.GetBoolWithDef("/tars/application/server/"+adapter+"<priorityqueue>", a.svrCfg.PriorityQueue) {
+			opts = append(opts, WithPriorityQueue(a.svrCfg.PriorityMaxSkip))
+		}
+		opts = append(opts, WithConnLimit(
+			c.GetIntWithDef("/tars/application/server/"+adapter+"<maxconns>", a.svrCfg.MaxConns),
+			c.GetIntWithDef("/tars/application/server/"+adapter+"<maxconnsperip>", a.svrCfg.MaxConnsPerIP),
+		))
+		opts = append(opts, WithAcceptRate(
+			c.GetFloatWithDef("/tars/application/server/"+adapter+"<acceptrate>", a.svrCfg.AcceptRate),
+			c.GetIntWithDef("/tars/application/server/"+adapter+"<acceptburst>", a.svrCfg.AcceptBurst),
+		))
+		if end.IsSSL() || end.IsQuic() {
 			key := c.GetString("/tars/application/server/" + adapter + "<key>")
 			cert := c.GetString("/tars/application/server/" + adapter + "<cert>")
 			if key != "" && cert != "" {
@@ -274,15 +368,23 @@ func (a *application) parseServerConfig(c *conf.Conf) {
 				opts = append(opts, WithTlsConfig(tlsConfig))
 			}
 		}
-		a.tarsConfig[svrObj] = newTarsServerConf(end.Proto, fmt.Sprintf("%s:%d", host, end.Port), a.svrCfg, opts...)
+		addr := fmt.Sprintf("%s:%d", host, end.Port)
+		if end.IsUnix() {
+			addr = end.Host
+		}
+		a.tarsConfig[svrObj] = newTarsServerConf(end.Proto, addr, a.svrCfg, opts...)
 	}
 	a.serList = serList
 
 	if len(a.svrCfg.Local) > 0 {
 		localPoint := endpoint.Parse(a.svrCfg.Local)
 		// 管理端口不启动协程池
-		a.tarsConfig["AdminObj"] = newTarsServerConf(localPoint.Proto, fmt.Sprintf("%s:%d", localPoint.Host, localPoint.Port), a.svrCfg, WithMaxInvoke(0))
-		a.svrCfg.Adapters["AdminAdapter"] = adapterConfig{localPoint, localPoint.Proto, "AdminObj", 1}
+		localAddr := fmt.Sprintf("%s:%d", localPoint.Host, localPoint.Port)
+		if localPoint.IsUnix() {
+			localAddr = localPoint.Host
+		}
+		a.tarsConfig["AdminObj"] = newTarsServerConf(localPoint.Proto, localAddr, a.svrCfg, WithMaxInvoke(0))
+		a.svrCfg.Adapters["AdminAdapter"] = adapterConfig{Endpoint: localPoint, Protocol: localPoint.Proto, Obj: "AdminObj", Threads: 1}
 		RegisterAdmin(rogger.Admin, rogger.HandleDyeingAdmin)
 	}
 
@@ -310,6 +412,9 @@ func (a *application) parseClientConfig(c *conf.Conf) {
 	a.cltCfg.ClientDialTimeout = tools.ParseTimeOut(c.GetIntWithDef("/tars/application/client<clientdialtimeout>", ClientDialTimeout))
 	a.cltCfg.ReqDefaultTimeout = c.GetInt32WithDef("/tars/application/client<reqdefaulttimeout>", ReqDefaultTimeout)
 	a.cltCfg.ObjQueueMax = c.GetInt32WithDef("/tars/application/client<objqueuemax>", ObjQueueMax)
+	a.cltCfg.Zone = c.GetString("/tars/application/client<zone>")
+	a.cltCfg.ZoneMinHealthyPercent = c.GetIntWithDef("/tars/application/client<zone-min-healthy-percent>", ZoneMinHealthyPercent)
+	a.cltCfg.Connections = c.GetIntWithDef("/tars/application/client<connections>", ClientConnections)
 	a.cltCfg.context["node_name"] = a.svrCfg.NodeName
 	ca := c.GetString("/tars/application/client<ca>")
 	if ca != "" {
@@ -326,8 +431,9 @@ func (a *application) parseClientConfig(c *conf.Conf) {
 	auths := c.GetDomain("/tars/application/client")
 	for _, objName := range auths {
 		authInfo := make(map[string]string)
-		// authInfo["accesskey"] = c.GetString("/tars/application/client/" + objName + "<accesskey>")
-		// authInfo["secretkey"] = c.GetString("/tars/application/client/" + objName + "<secretkey>")
+		authInfo["accesskey"] = c.GetString("/tars/application/client/" + objName + "<accesskey>")
+		authInfo["secretkey"] = c.GetString("/tars/application/client/" + objName + "<secretkey>")
+		authInfo["authtype"] = c.GetString("/tars/application/client/" + objName + "<authtype>")
 		authInfo["ca"] = c.GetString("/tars/application/client/" + objName + "<ca>")
 		authInfo["cert"] = c.GetString("/tars/application/client/" + objName + "<cert>")
 		authInfo["key"] = c.GetString("/tars/application/client/" + objName + "<key>")
@@ -340,6 +446,27 @@ func (a *application) parseClientConfig(c *conf.Conf) {
 			}
 			a.clientObjTlsConfig[objName] = objTlsConfig
 		}
+		if retryPolicy := parseRetryPolicy(c, "/tars/application/client/"+objName); retryPolicy != nil {
+			a.clientObjRetryPolicy[objName] = retryPolicy
+		}
+		if hedgePolicy := parseHedgePolicy(c, "/tars/application/client/"+objName); hedgePolicy != nil {
+			a.clientObjHedgePolicy[objName] = hedgePolicy
+		}
+		if name := c.GetString("/tars/application/client/" + objName + "<selector>"); name != "" {
+			a.clientObjSelector[objName] = name
+		}
+		if breaker := parseCircuitBreaker(c, "/tars/application/client/"+objName); breaker != nil {
+			a.clientObjBreaker[objName] = breaker
+		}
+		if n := c.GetInt("/tars/application/client/" + objName + "<connections>"); n > 0 {
+			a.clientObjConnections[objName] = n
+		}
+		if compress := parseCompressPolicy(c, "/tars/application/client/"+objName); compress != nil {
+			a.clientObjCompress[objName] = compress
+		}
+		if probePolicy := parseProbePolicy(c, "/tars/application/client/"+objName); probePolicy != nil {
+			a.clientObjProbePolicy[objName] = probePolicy
+		}
 	}
 }
 
@@ -367,7 +494,14 @@ func (a *application) Run(opts ...Option) {
 		AddServant(adf, ad, "AdminObj")
 	}
 
+	if err := a.runHooks(context.Background(), hookBeforeStart, true); err != nil {
+		a.teerDown(err)
+		return
+	}
+	defer a.runHooks(context.Background(), hookAfterStop, false)
+
 	lisDone := &sync.WaitGroup{}
+	var lisFailed int32
 	for _, obj := range a.objRunList {
 		if s, ok := a.httpSvrs[obj]; ok {
 			lisDone.Add(1)
@@ -375,12 +509,14 @@ func (a *application) Run(opts ...Option) {
 				addr := s.Addr
 				TLOG.Infof("%s http server start on %s", obj, s.Addr)
 				if addr == "" {
+					atomic.StoreInt32(&lisFailed, 1)
 					lisDone.Done()
 					a.teerDown(fmt.Errorf("empty addr for %s", obj))
 					return
 				}
 				ln, err := grace.CreateListener("tcp", addr)
 				if err != nil {
+					atomic.StoreInt32(&lisFailed, 1)
 					lisDone.Done()
 					a.teerDown(fmt.Errorf("start http server for %s failed: %v", obj, err))
 					return
@@ -405,6 +541,7 @@ func (a *application) Run(opts ...Option) {
 
 		s := a.goSvrs[obj]
 		if s == nil {
+			atomic.StoreInt32(&lisFailed, 1)
 			a.teerDown(fmt.Errorf("obj not found %s", obj))
 			break
 		}
@@ -412,6 +549,7 @@ func (a *application) Run(opts ...Option) {
 		lisDone.Add(1)
 		go func(obj string) {
 			if err := s.Listen(); err != nil {
+				atomic.StoreInt32(&lisFailed, 1)
 				lisDone.Done()
 				a.teerDown(fmt.Errorf("listen obj for %s failed: %v", obj, err))
 				return
@@ -427,6 +565,19 @@ func (a *application) Run(opts ...Option) {
 	go ReportNotifyInfo(NotifyNormal, "restart")
 
 	lisDone.Wait()
+	// the server failing to listen is not ready, it is torn down in the main loop
+	if atomic.LoadInt32(&lisFailed) == 0 {
+		atomic.StoreInt32(&a.ready, 1)
+		a.startHealthServer()
+		go func() {
+			if err := a.runHooks(context.Background(), hookAfterStart, true); err != nil {
+				// the server is serving, so it is deregistered and drained before exit
+				TLOG.Errorf("after start hooks failed, shut down: %v", err)
+				go ReportNotifyInfo(NotifyNormal, "server is fatal: "+err.Error())
+				a.graceShutdown()
+			}
+		}()
+	}
 	if os.Getenv("GRACE_RESTART") == "1" {
 		ppid := os.Getppid()
 		TLOG.Infof("stop ppid %d", ppid)
@@ -491,9 +642,11 @@ func (a *application) graceRestart() {
 }
 
 func (a *application) graceShutdown() {
-	var wg sync.WaitGroup
-
-	atomic.StoreInt32(&a.isShutdowning, 1)
+	// the signal, the admin command and the after start hooks may shut down at the same time
+	if !atomic.CompareAndSwapInt32(&a.isShutdowning, 0, 1) {
+		TLOG.Info("grace shutdown is in progress")
+		return
+	}
 	pid := os.Getpid()
 
 	var graceShutdownTimeout time.Duration
@@ -506,58 +659,14 @@ func (a *application) graceShutdown() {
 
 	TLOG.Infof("grace shutdown start %d in %v", pid, graceShutdownTimeout)
 	ctx, cancel := context.WithTimeout(context.Background(), graceShutdownTimeout)
-	// deregister service
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		a.deregisterAdapters(ctx)
-	}()
-
-	for _, obj := range a.destroyableObjs {
-		wg.Add(1)
-		go func(wg *sync.WaitGroup, obj destroyableImp) {
-			defer wg.Done()
-			obj.Destroy()
-			TLOG.Infof("grace Destroy success %d", pid)
-		}(&wg, obj)
-	}
-
-	for _, obj := range a.objRunList {
-		if s, ok := a.httpSvrs[obj]; ok {
-			wg.Add(1)
-			go func(s *http.Server, ctx context.Context, wg *sync.WaitGroup, objstr string) {
-				defer wg.Done()
-				if err := s.Shutdown(ctx); err != nil {
-					TLOG.Errorf("grace shutdown http %s failed within %v, err%v", objstr, graceShutdownTimeout, err)
-				} else {
-					TLOG.Infof("grace shutdown http %s success %d", objstr, pid)
-				}
-			}(s, ctx, &wg, obj)
-		}
-
-		if s, ok := a.goSvrs[obj]; ok {
-			wg.Add(1)
-			go func(s *transport.TarsServer, ctx context.Context, wg *sync.WaitGroup, objstr string) {
-				defer wg.Done()
-				if err := s.Shutdown(ctx); err != nil {
-					TLOG.Errorf("grace shutdown tars %s failed within %v, err: %v", objstr, graceShutdownTimeout, err)
-				} else {
-					TLOG.Infof("grace shutdown tars %s success %d", objstr, pid)
-				}
-			}(s, ctx, &wg, obj)
-		}
-	}
-
-	go func() {
-		wg.Wait()
-		cancel()
-	}()
-
-	select {
-	case <-ctx.Done():
-		TLOG.Infof("grace shutdown all success within : %v", graceShutdownTimeout)
-	case <-time.After(graceShutdownTimeout):
+	defer cancel()
+	a.runHooks(ctx, hookBeforeStop, false)
+	a.runShutdown(ctx)
+	a.stopHealthServer()
+	if ctx.Err() != nil {
 		TLOG.Errorf("grace shutdown timeout within : %v", graceShutdownTimeout)
+	} else {
+		TLOG.Infof("grace shutdown all success within : %v", graceShutdownTimeout)
 	}
 
 	a.teerDown(nil)
@@ -606,6 +715,7 @@ func (a *application) mainLoop() {
 			if atomic.LoadInt32(&a.isShutdowning) == 1 {
 				continue
 			}
+			a.reportQueueStats()
 			for name, adapter := range a.svrCfg.Adapters {
 				if adapter.Protocol == "not_tars" {
 					// TODO not_tars support
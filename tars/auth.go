@@ -0,0 +1,176 @@
+package tars
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/auth"
+	"github.com/TarsCloud/TarsGo/tars/protocol"
+	"github.com/TarsCloud/TarsGo/tars/protocol/codec"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/authf"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+	"github.com/TarsCloud/TarsGo/tars/util/current"
+	"github.com/TarsCloud/TarsGo/tars/util/tools"
+)
+
+// authFuncName is the function of the auth request, which is sent first on the connection.
+const authFuncName = "tars_auth"
+
+// authRequestID is the request id of the auth request, which differs from the close message.
+const authRequestID = 1
+
+// AuthProvider verifies the auth packages of the connections to the servants whose adapters enable
+// the local auth type.
+type AuthProvider interface {
+	// Verify verifies the auth package of the connection to obj, it returns AUTH_STATE_AUTH_SUCC if passed.
+	Verify(obj string, pkg *authf.BasicAuthPackage) authf.AUTH_STATE
+}
+
+// SetAuthProvider sets the auth provider of the servants, the keys of the adapters and the
+// auth file in the server config are used by default.
+func SetAuthProvider(p AuthProvider) {
+	defaultApp.SetAuthProvider(p)
+}
+
+// SetAuthProvider sets the auth provider of the servants.
+func (a *application) SetAuthProvider(p AuthProvider) {
+	a.authProvider = p
+}
+
+func (a *application) getAuthProvider() AuthProvider {
+	if a.authProvider != nil {
+		return a.authProvider
+	}
+	return a.localAuth
+}
+
+// authHandshake signs the auth package of the obj, and sends it on the connection before any request.
+func (c *AdapterProxy) authHandshake(accessKey, secretKey string) func(conn net.Conn) error {
+	return func(conn net.Conn) error {
+		pkg, err := auth.Sign(c.objName, accessKey, secretKey, time.Now())
+		if err != nil {
+			return err
+		}
+		buf := codec.NewBuffer()
+		if err = pkg.WriteTo(buf); err != nil {
+			return err
+		}
+		timeout := c.comm.Client.ClientDialTimeout
+		proto := &protocol.TarsProtocol{}
+		req, err := proto.RequestPack(&requestf.RequestPacket{
+			IVersion:     basef.TARSVERSION,
+			CPacketType:  basef.TARSNORMAL,
+			IRequestId:   authRequestID,
+			SServantName: c.objName,
+			SFuncName:    authFuncName,
+			SBuffer:      tools.ByteToInt8(buf.ToBytes()),
+			ITimeout:     int32(timeout / time.Millisecond),
+		})
+		if err != nil {
+			return err
+		}
+		if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			return err
+		}
+		defer conn.SetDeadline(time.Time{})
+		if _, err = conn.Write(req); err != nil {
+			return err
+		}
+		rsp, err := readResponse(conn, proto)
+		if err != nil {
+			return fmt.Errorf("auth of %s: %w", c.objName, err)
+		}
+		if rsp.IRequestId != authRequestID {
+			return fmt.Errorf("auth of %s: unexpected response: %s", c.objName, rsp.SResultDesc)
+		}
+		if rsp.IRet != int32(authf.AUTH_STATE_AUTH_SUCC) {
+			return fmt.Errorf("auth of %s failed: %s", c.objName, rsp.SResultDesc)
+		}
+		return nil
+	}
+}
+
+// readResponse reads a response package from the connection.
+func readResponse(conn net.Conn, proto *protocol.TarsProtocol) (*requestf.ResponsePacket, error) {
+	var pkg []byte
+	buffer := make([]byte, 1024)
+	for {
+		n, err := conn.Read(buffer)
+		if n > 0 {
+			pkg = append(pkg, buffer[:n]...)
+			pkgLen, status := proto.ParsePackage(pkg)
+			if status == protocol.PackageFull {
+				return proto.ResponseUnpack(pkg[:pkgLen])
+			}
+			if status != protocol.PackageLess {
+				return nil, errors.New("parse package error")
+			}
+		}
+		if err == io.EOF {
+			return nil, errors.New("connection closed by remote")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// authenticate verifies the auth request, and marks the connection authenticated if passed.
+func (s *Protocol) authenticate(ctx context.Context, req *requestf.RequestPacket, rsp *requestf.ResponsePacket) {
+	if !s.auth {
+		// the client authenticates though the servant does not require it
+		rsp.SResultDesc = "auth not required"
+		return
+	}
+	state := s.verify(ctx, req)
+	rsp.IRet = int32(state)
+	rsp.SResultDesc = auth.StateText(state)
+	if state != authf.AUTH_STATE_AUTH_SUCC {
+		ip, _ := current.GetClientIPFromContext(ctx)
+		TLOG.Errorf("auth failed, obj: %s, ip: %s, %s", s.servant(req), ip, rsp.SResultDesc)
+		return
+	}
+	conn, _, _ := current.GetRawConn(ctx)
+	s.authed.Store(conn, true)
+}
+
+func (s *Protocol) verify(ctx context.Context, req *requestf.RequestPacket) authf.AUTH_STATE {
+	if _, udpAddr, ok := current.GetRawConn(ctx); !ok || udpAddr != nil {
+		// only the stream connections can be authenticated
+		return authf.AUTH_STATE_AUTH_PROTO_ERR
+	}
+	pkg := &authf.BasicAuthPackage{}
+	if err := pkg.ReadFrom(codec.NewReader(tools.Int8ToByte(req.SBuffer))); err != nil {
+		return authf.AUTH_STATE_AUTH_PROTO_ERR
+	}
+	p := s.app.getAuthProvider()
+	if p == nil {
+		return authf.AUTH_STATE_AUTH_ERROR
+	}
+	return p.Verify(s.servant(req), pkg)
+}
+
+// authenticated returns whether the connection of the request is authenticated, or the servant does not require it.
+func (s *Protocol) authenticated(ctx context.Context) bool {
+	if !s.auth {
+		return true
+	}
+	conn, udpAddr, ok := current.GetRawConn(ctx)
+	if !ok || udpAddr != nil {
+		return false
+	}
+	_, ok = s.authed.Load(conn)
+	return ok
+}
+
+func (s *Protocol) servant(req *requestf.RequestPacket) string {
+	if s.obj != "" {
+		return s.obj
+	}
+	return req.SServantName
+}
@@ -0,0 +1,131 @@
+// Package auth implements the local authentication of the tars connections by the access key and the secret key.
+//
+// The client signs the auth package following the comment of AuthF.tars: tmpKey = md5(secret2 | timestamp),
+// where secret1 = sha1(secretKey) and secret2 = sha1(secret1), and the signature is secret1 encrypted by tmpKey.
+// The server which knows secret2 decrypts the signature, and checks sha1 of it against secret2.
+//
+// AuthF.tars does not specify the cipher, and this package encrypts the signature by AES-GCM, which is a TarsGo
+// only scheme named by HashMethod. The other Tars implementations encrypt it differently, so TarsGo can only
+// authenticate with TarsGo: the peers of the other implementations are rejected with AUTH_NOT_SUPPORT_ENC.
+//
+// The auth package proves that the client knows the secret key, it is not bound to the connection. The server
+// rejects the package replayed to it, but a package captured on the network can be replayed to the other servers
+// of the obj within the max skew of the time, so the connections should be protected by tls.
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/authf"
+)
+
+// HashMethod names the scheme of the signature, which is the only one supported.
+const HashMethod = "tarsgo-aes-gcm-sha1"
+
+// DefaultMaxSkew is the default max difference between the time of the auth package and the server time.
+const DefaultMaxSkew = 10 * time.Minute
+
+// HashSecretKey returns secret1 of the secret key, which is sha1 of it in hex.
+func HashSecretKey(secretKey string) string {
+	return sha1Hex(secretKey)
+}
+
+// HashSecretKey2 returns secret2 of the secret key, which is sha1 of secret1 in hex.
+// The server can keep secret2 instead of the secret key.
+func HashSecretKey2(secretKey string) string {
+	return sha1Hex(sha1Hex(secretKey))
+}
+
+// Sign returns the auth package of the client to obj signed at the time.
+func Sign(obj, accessKey, secretKey string, now time.Time) (*authf.BasicAuthPackage, error) {
+	pkg := &authf.BasicAuthPackage{
+		SObjName:    obj,
+		SAccessKey:  accessKey,
+		ITime:       now.Unix(),
+		SHashMethod: HashMethod,
+	}
+	secret1 := HashSecretKey(secretKey)
+	aead, err := newAEAD(sha1Hex(secret1), pkg.ITime)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	pkg.SSignature = string(aead.Seal(nonce, nonce, []byte(secret1), nil))
+	return pkg, nil
+}
+
+// Verify verifies the signature and the time of the auth package by secret2 of the secret key,
+// the time is not checked if maxSkew is not positive.
+func Verify(pkg *authf.BasicAuthPackage, hashSecretKey2 string, maxSkew time.Duration, now time.Time) authf.AUTH_STATE {
+	// the empty method is sha1 by default, which is the scheme of the other implementations
+	if pkg.SHashMethod != HashMethod {
+		return authf.AUTH_STATE_AUTH_NOT_SUPPORT_ENC
+	}
+	if maxSkew > 0 {
+		skew := now.Sub(time.Unix(pkg.ITime, 0))
+		if skew > maxSkew || skew < -maxSkew {
+			return authf.AUTH_STATE_AUTH_WRONG_TIME
+		}
+	}
+	aead, err := newAEAD(hashSecretKey2, pkg.ITime)
+	if err != nil {
+		return authf.AUTH_STATE_AUTH_ERROR
+	}
+	sig := []byte(pkg.SSignature)
+	if len(sig) < aead.NonceSize() {
+		return authf.AUTH_STATE_AUTH_DEC_FAIL
+	}
+	secret1, err := aead.Open(nil, sig[:aead.NonceSize()], sig[aead.NonceSize():], nil)
+	if err != nil || sha1Hex(string(secret1)) != hashSecretKey2 {
+		return authf.AUTH_STATE_AUTH_DEC_FAIL
+	}
+	return authf.AUTH_STATE_AUTH_SUCC
+}
+
+// StateText returns the description of the auth state.
+func StateText(state authf.AUTH_STATE) string {
+	switch state {
+	case authf.AUTH_STATE_AUTH_SUCC:
+		return "auth succ"
+	case authf.AUTH_STATE_AUTH_PROTO_ERR:
+		return "auth protocol error"
+	case authf.AUTH_STATE_AUTH_WRONG_OBJ:
+		return "auth wrong obj"
+	case authf.AUTH_STATE_AUTH_WRONG_AK:
+		return "auth wrong access key"
+	case authf.AUTH_STATE_AUTH_WRONG_TIME:
+		return "auth wrong time"
+	case authf.AUTH_STATE_AUTH_NOT_SUPPORT_ENC:
+		return "auth hash method not supported"
+	case authf.AUTH_STATE_AUTH_DEC_FAIL:
+		return "auth signature mismatch"
+	default:
+		return fmt.Sprintf("auth failed: %d", state)
+	}
+}
+
+// newAEAD returns the cipher of tmpKey = md5(secret2 | timestamp).
+func newAEAD(secret2 string, timestamp int64) (cipher.AEAD, error) {
+	tmpKey := md5.Sum([]byte(secret2 + strconv.FormatInt(timestamp, 10)))
+	block, err := aes.NewCipher(tmpKey[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
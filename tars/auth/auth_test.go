@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/authf"
+)
+
+func TestFileProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "keys")
+	err = ioutil.WriteFile(path, []byte("# obj accesskey secretkey\nApp.Server.Obj ak sk\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewFileProvider(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	cases := []struct {
+		obj       string
+		accessKey string
+		secretKey string
+		time      time.Time
+		want      authf.AUTH_STATE
+	}{
+		{"App.Server.Obj", "ak", "sk", now, authf.AUTH_STATE_AUTH_SUCC},
+		{"App.Server.Other", "ak", "sk", now, authf.AUTH_STATE_AUTH_WRONG_OBJ},
+		{"App.Server.Obj", "bad", "sk", now, authf.AUTH_STATE_AUTH_WRONG_AK},
+		{"App.Server.Obj", "ak", "bad", now, authf.AUTH_STATE_AUTH_DEC_FAIL},
+		{"App.Server.Obj", "ak", "sk", now.Add(-time.Hour), authf.AUTH_STATE_AUTH_WRONG_TIME},
+	}
+	for _, c := range cases {
+		pkg, err := Sign(c.obj, c.accessKey, c.secretKey, c.time)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Verify("App.Server.Obj", pkg); got != c.want {
+			t.Errorf("Verify(%s, %s, %s) = %s, want %s", c.obj, c.accessKey, c.secretKey, StateText(got), StateText(c.want))
+		}
+	}
+
+	// sha1 and the empty method are the scheme of the other implementations
+	for _, method := range []string{"md5", "sha1", ""} {
+		pkg, _ := Sign("App.Server.Obj", "ak", "sk", now)
+		pkg.SHashMethod = method
+		if got := p.Verify("App.Server.Obj", pkg); got != authf.AUTH_STATE_AUTH_NOT_SUPPORT_ENC {
+			t.Errorf("hash method %q: %s", method, StateText(got))
+		}
+	}
+	if err = ioutil.WriteFile(path, []byte("App.Server.Obj ak\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.LoadFile(path); err == nil {
+		t.Error("invalid key file is loaded")
+	}
+}
+
+func TestLocalProviderReplay(t *testing.T) {
+	p := NewLocalProvider()
+	p.Add("App.Server.Obj", "ak", "sk")
+	now := time.Now()
+	pkg, err := Sign("App.Server.Obj", "ak", "sk", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Verify("App.Server.Obj", pkg); got != authf.AUTH_STATE_AUTH_SUCC {
+		t.Fatalf("Verify = %s", StateText(got))
+	}
+	if got := p.Verify("App.Server.Obj", pkg); got != authf.AUTH_STATE_AUTH_ERROR {
+		t.Errorf("replayed Verify = %s", StateText(got))
+	}
+	pkg, _ = Sign("App.Server.Obj", "ak", "sk", now)
+	if got := p.Verify("App.Server.Obj", pkg); got != authf.AUTH_STATE_AUTH_SUCC {
+		t.Errorf("resigned Verify = %s", StateText(got))
+	}
+}
+
+func TestReplayCache(t *testing.T) {
+	var c replayCache
+	now := time.Now()
+	k := replayKey{accessKey: "ak", time: now.Unix(), signature: "s"}
+	if !c.add(k, now.Add(time.Second), now) || c.add(k, now.Add(time.Second), now) {
+		t.Error("replay is not detected")
+	}
+	if !c.add(k, now.Add(3*time.Second), now.Add(2*time.Second)) {
+		t.Error("expired entry is not dropped")
+	}
+	for i := 0; i < maxReplayEntries; i++ {
+		c.add(replayKey{time: int64(i)}, now.Add(time.Hour), now)
+	}
+	if len(c.entries) != maxReplayEntries || len(c.seen) != maxReplayEntries {
+		t.Errorf("entries = %d, seen = %d", len(c.entries), len(c.seen))
+	}
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/authf"
+)
+
+// maxReplayEntries is the max number of the auth packages remembered to reject the replayed ones.
+const maxReplayEntries = 100000
+
+type localKey struct {
+	obj       string
+	accessKey string
+}
+
+// LocalProvider verifies the auth packages by the keys of the servants kept locally.
+// The auth packages verified within MaxSkew are remembered, so that the ones replayed are rejected.
+// They are remembered by the process, which does not stop the replay to the other servers of the obj.
+type LocalProvider struct {
+	// MaxSkew is the max difference between the time of the auth package and the server time.
+	MaxSkew time.Duration
+
+	mu     sync.RWMutex
+	keys   map[localKey]string // secret2 of the secret keys
+	replay replayCache
+}
+
+// NewLocalProvider returns a local provider without keys.
+func NewLocalProvider() *LocalProvider {
+	return &LocalProvider{MaxSkew: DefaultMaxSkew, keys: make(map[localKey]string)}
+}
+
+// NewFileProvider returns a local provider with the keys loaded from the file.
+func NewFileProvider(path string) (*LocalProvider, error) {
+	p := NewLocalProvider()
+	if err := p.LoadFile(path); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Add adds the access key and the secret key of the servant.
+func (p *LocalProvider) Add(obj, accessKey, secretKey string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys[localKey{obj: obj, accessKey: accessKey}] = HashSecretKey2(secretKey)
+}
+
+// LoadFile replaces the keys with the lines of the file in the form of "obj accesskey secretkey",
+// the empty lines and the lines starting with # are skipped.
+func (p *LocalProvider) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	keys := make(map[localKey]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return fmt.Errorf("%s:%d: invalid key, want: obj accesskey secretkey", path, n)
+		}
+		keys[localKey{obj: fields[0], accessKey: fields[1]}] = HashSecretKey2(fields[2])
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	return nil
+}
+
+// Verify verifies the auth package of the connection to obj.
+func (p *LocalProvider) Verify(obj string, pkg *authf.BasicAuthPackage) authf.AUTH_STATE {
+	if pkg.SObjName != obj {
+		return authf.AUTH_STATE_AUTH_WRONG_OBJ
+	}
+	p.mu.RLock()
+	secret2, ok := p.keys[localKey{obj: obj, accessKey: pkg.SAccessKey}]
+	p.mu.RUnlock()
+	if !ok {
+		return authf.AUTH_STATE_AUTH_WRONG_AK
+	}
+	now := time.Now()
+	if state := Verify(pkg, secret2, p.MaxSkew, now); state != authf.AUTH_STATE_AUTH_SUCC {
+		return state
+	}
+	// the signature has a random nonce, so the same one is replayed
+	if !p.replay.add(replayKey{accessKey: pkg.SAccessKey, time: pkg.ITime, signature: pkg.SSignature},
+		time.Unix(pkg.ITime, 0).Add(p.MaxSkew), now) {
+		return authf.AUTH_STATE_AUTH_ERROR
+	}
+	return authf.AUTH_STATE_AUTH_SUCC
+}
+
+type replayKey struct {
+	accessKey string
+	time      int64
+	signature string
+}
+
+type replayEntry struct {
+	key    replayKey
+	expire time.Time
+}
+
+// replayCache remembers the auth packages until they expire, the oldest ones are dropped
+// when there are more than maxReplayEntries.
+type replayCache struct {
+	mu      sync.Mutex
+	seen    map[replayKey]struct{}
+	entries []replayEntry // in the order of adding
+}
+
+// add returns false if the key has been added and not expired.
+func (c *replayCache) add(key replayKey, expire, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen == nil {
+		c.seen = make(map[replayKey]struct{})
+	}
+	n := 0
+	for n < len(c.entries) && (len(c.entries)-n >= maxReplayEntries || c.entries[n].expire.Before(now)) {
+		delete(c.seen, c.entries[n].key)
+		n++
+	}
+	c.entries = c.entries[n:]
+	if _, ok := c.seen[key]; ok {
+		return false
+	}
+	c.seen[key] = struct{}{}
+	c.entries = append(c.entries, replayEntry{key: key, expire: expire})
+	return true
+}
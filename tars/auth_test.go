@@ -0,0 +1,72 @@
+package tars
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+	"github.com/TarsCloud/TarsGo/tars/util/endpoint"
+)
+
+func TestAuthHandshake(t *testing.T) {
+	app := newApp()
+	app.localAuth.Add("App.Server.Obj", "ak", "sk")
+	server := NewTarsProtocol(&echoDispatcher{}, nil, false)
+	server.app = app
+	server.obj = "App.Server.Obj"
+	server.auth = true
+	address := startServer(t, server)
+
+	comm := newCommunicator(app, app.cltCfg)
+	point := endpoint.Endpoint2tars(endpoint.Parse("tcp -h 127.0.0.1 -p " + strconv.Itoa(address.Port) + " -e 1"))
+	newAdapter := func(accessKey, secretKey string) *AdapterProxy {
+		app.clientObjInfo["App.Server.Obj"] = map[string]string{"accesskey": accessKey, "secretkey": secretKey}
+		adp := NewAdapterProxy("App.Server.Obj", &point, comm)
+		adp.servantProxy = &ServantProxy{name: "App.Server.Obj", version: basef.TARSVERSION, proto: &protocol.TarsProtocol{}}
+		return adp
+	}
+
+	adp := newAdapter("ak", "sk")
+	defer adp.Close()
+	if err := adp.tarsClient.ReConnect(); err != nil {
+		t.Fatalf("auth with the right key failed: %v", err)
+	}
+	if err := adp.ping(time.Second, false); err != nil {
+		t.Errorf("ping on the authenticated connection failed: %v", err)
+	}
+
+	bad := newAdapter("ak", "bad")
+	defer bad.Close()
+	if err := bad.tarsClient.ReConnect(); err == nil {
+		t.Error("auth with the wrong key passed")
+	}
+
+	// the requests on the connection not authenticated are rejected
+	conn, err := net.Dial("tcp", address.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	proto := &protocol.TarsProtocol{}
+	req, _ := proto.RequestPack(&requestf.RequestPacket{
+		IVersion:     basef.TARSVERSION,
+		IRequestId:   1,
+		SServantName: "App.Server.Obj",
+		SFuncName:    "Echo",
+	})
+	if _, err = conn.Write(req); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	rsp, err := readResponse(conn, proto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.IRet != basef.TARSSERVERACCESSDENIED {
+		t.Errorf("request not authenticated returns %d: %s", rsp.IRet, rsp.SResultDesc)
+	}
+}
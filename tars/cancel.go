@@ -0,0 +1,64 @@
+package tars
+
+import (
+	"github.com/TarsCloud/TarsGo/tars/protocol/codec"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+	"github.com/TarsCloud/TarsGo/tars/transport"
+)
+
+// cancelFuncName is the function name of the cancel frame, which cancels the in-flight request
+// with the same request id on the same connection. The cancel frame is one way,
+// so the servers which do not support it just fail to dispatch it without response.
+const cancelFuncName = "tars_cancel"
+
+var _ transport.CancelProtocol = (*Protocol)(nil)
+
+// ParseRequest returns the request id of the package, and whether it is a cancel frame.
+// It is called for every package before queued, so only the header tags are read,
+// and the function name is compared in place for the one way packages.
+func (s *Protocol) ParseRequest(pkg []byte) (int32, bool) {
+	var (
+		packetType int8
+		reqID      int32
+	)
+	is := codec.NewReader(pkg[4:])
+	if err := is.ReadInt8(&packetType, 2, true); err != nil {
+		return 0, false
+	}
+	if err := is.ReadInt32(&reqID, 4, true); err != nil {
+		return 0, false
+	}
+	if packetType != basef.TARSONEWAY {
+		return reqID, false
+	}
+	have, ty, err := is.SkipToNoCheck(6, true)
+	if err != nil || !have || ty != codec.STRING1 {
+		return reqID, false
+	}
+	if n := is.Next(1); len(n) != 1 || int(n[0]) != len(cancelFuncName) {
+		return reqID, false
+	}
+	return reqID, string(is.Next(len(cancelFuncName))) == cancelFuncName
+}
+
+// sendCancel sends the cancel frame of the request on the connection which the request is sent on.
+func (c *AdapterProxy) sendCancel(req *requestf.RequestPacket, conn int32) {
+	if req.CPacketType == basef.TARSONEWAY {
+		return
+	}
+	cancel := &requestf.RequestPacket{
+		IVersion:     req.IVersion,
+		CPacketType:  basef.TARSONEWAY,
+		IRequestId:   req.IRequestId,
+		SServantName: req.SServantName,
+		SFuncName:    cancelFuncName,
+	}
+	sbuf, err := c.servantProxy.proto.RequestPack(cancel)
+	if err != nil {
+		return
+	}
+	if err = c.tarsClient.SendOn(conn, sbuf); err != nil {
+		TLOG.Debugf("send cancel frame error, reqid: %d, err: %v", req.IRequestId, err)
+	}
+}
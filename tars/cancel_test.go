@@ -0,0 +1,34 @@
+package tars
+
+import (
+	"testing"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+)
+
+func TestProtocol_ParseRequest(t *testing.T) {
+	proto := &protocol.TarsProtocol{}
+	s := &Protocol{}
+	tests := []struct {
+		req        requestf.RequestPacket
+		wantCancel bool
+	}{
+		{requestf.RequestPacket{IRequestId: 7, SServantName: "App.Server.Obj", SFuncName: "add"}, false},
+		{requestf.RequestPacket{CPacketType: basef.TARSONEWAY, IRequestId: 8, SServantName: "App.Server.Obj", SFuncName: cancelFuncName}, true},
+		{requestf.RequestPacket{IRequestId: 9, SServantName: "App.Server.Obj", SFuncName: cancelFuncName}, false},
+		{requestf.RequestPacket{CPacketType: basef.TARSONEWAY, IRequestId: 10, SServantName: "App.Server.Obj", SFuncName: "notify"}, false},
+		{requestf.RequestPacket{CPacketType: basef.TARSONEWAY, IRequestId: 11, SServantName: "App.Server.Obj", SFuncName: cancelFuncName + "_all"}, false},
+	}
+	for _, tt := range tests {
+		pkg, err := proto.RequestPack(&tt.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, isCancel := s.ParseRequest(pkg)
+		if id != tt.req.IRequestId || isCancel != tt.wantCancel {
+			t.Errorf("ParseRequest() = %d, %v, want %d, %v", id, isCancel, tt.req.IRequestId, tt.wantCancel)
+		}
+	}
+}
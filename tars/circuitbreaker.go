@@ -0,0 +1,85 @@
+package tars
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/circuitbreaker"
+	"github.com/TarsCloud/TarsGo/tars/util/conf"
+	"github.com/TarsCloud/TarsGo/tars/util/endpoint"
+)
+
+// CircuitBreakerHook is called when the circuit breaker of an endpoint changes its state.
+type CircuitBreakerHook func(objName string, ep endpoint.Endpoint, from, to circuitbreaker.State)
+
+// RegisterCircuitBreakerHook registers the hook of circuit breaker state changes.
+func RegisterCircuitBreakerHook(hook CircuitBreakerHook) {
+	defaultApp.RegisterCircuitBreakerHook(hook)
+}
+
+// RegisterCircuitBreakerHook registers the hook of circuit breaker state changes.
+func (a *application) RegisterCircuitBreakerHook(hook CircuitBreakerHook) {
+	a.breakerHooks = append(a.breakerHooks, hook)
+}
+
+func (a *application) onBreakerStateChange(adp *AdapterProxy, from, to circuitbreaker.State) {
+	ep := endpoint.Tars2endpoint(*adp.GetPoint())
+	TLOG.Infof("circuit breaker of %s %s changes from %s to %s", adp.objName, ep.Key, from, to)
+	for _, hook := range a.breakerHooks {
+		hook(adp.objName, ep, from, to)
+	}
+	// half-open is just a transient state for checking, only report the blocking and recovering.
+	if to == circuitbreaker.StateOpen && from == circuitbreaker.StateClosed {
+		go ReportNotifyInfo(NotifyWarn, fmt.Sprintf("circuit breaker open, obj: %s, endpoint: %s", adp.objName, ep.Key))
+	} else if to == circuitbreaker.StateClosed {
+		go ReportNotifyInfo(NotifyNormal, fmt.Sprintf("circuit breaker closed, obj: %s, endpoint: %s", adp.objName, ep.Key))
+	}
+}
+
+func defaultCircuitBreakerConfig() circuitbreaker.DefaultConfig {
+	return circuitbreaker.DefaultConfig{
+		FailN:        fainN,
+		FailInterval: time.Duration(failInterval) * time.Second,
+		CheckTime:    time.Duration(checkTime) * time.Second,
+		OverN:        overN,
+		FailRatio:    failRatio,
+		TryInterval:  time.Duration(tryTimeInterval) * time.Second,
+	}
+}
+
+func newDefaultCircuitBreaker() circuitbreaker.CircuitBreaker {
+	return circuitbreaker.NewDefault(defaultCircuitBreakerConfig())
+}
+
+// parseCircuitBreaker parses the circuit breaker of the obj from the client config domain,
+// returns nil if the breaker is not configured.
+func parseCircuitBreaker(c *conf.Conf, path string) circuitbreaker.Factory {
+	switch strings.ToLower(c.GetString(path + "<breaker>")) {
+	case "":
+		return nil
+	case "default":
+		cfg := defaultCircuitBreakerConfig()
+		cfg.FailN = c.GetInt32WithDef(path+"<breaker-fail-n>", cfg.FailN)
+		cfg.FailInterval = time.Duration(c.GetIntWithDef(path+"<breaker-fail-interval>", int(failInterval*1000))) * time.Millisecond
+		cfg.CheckTime = time.Duration(c.GetIntWithDef(path+"<breaker-check-time>", int(checkTime*1000))) * time.Millisecond
+		cfg.OverN = c.GetInt32WithDef(path+"<breaker-over-n>", cfg.OverN)
+		cfg.FailRatio = float32(c.GetFloatWithDef(path+"<breaker-fail-ratio>", float64(cfg.FailRatio)))
+		cfg.TryInterval = time.Duration(c.GetIntWithDef(path+"<breaker-try-interval>", int(tryTimeInterval*1000))) * time.Millisecond
+		return circuitbreaker.NewDefaultFactory(cfg)
+	case "slidingwindow":
+		cfg := circuitbreaker.SlidingWindowConfig{
+			Window:           time.Duration(c.GetIntWithDef(path+"<breaker-window>", breakerWindow)) * time.Millisecond,
+			Buckets:          c.GetIntWithDef(path+"<breaker-buckets>", breakerBuckets),
+			MinRequests:      int64(c.GetIntWithDef(path+"<breaker-min-requests>", breakerMinRequests)),
+			ErrorRatio:       c.GetFloatWithDef(path+"<breaker-error-ratio>", breakerErrorRatio),
+			SlowCall:         time.Duration(c.GetIntWithDef(path+"<breaker-slow-call>", 0)) * time.Millisecond,
+			OpenTimeout:      time.Duration(c.GetIntWithDef(path+"<breaker-open-timeout>", int(tryTimeInterval*1000))) * time.Millisecond,
+			HalfOpenRequests: int64(c.GetIntWithDef(path+"<breaker-half-open-requests>", 1)),
+		}
+		return circuitbreaker.NewSlidingWindowFactory(cfg)
+	default:
+		TLOG.Errorf("unknown circuit breaker %s of %s, use default", c.GetString(path+"<breaker>"), path)
+		return nil
+	}
+}
@@ -0,0 +1,48 @@
+package circuitbreaker
+
+import "time"
+
+// State is the state of a circuit breaker.
+type State int32
+
+// State enum
+const (
+	// StateClosed means the endpoint is healthy and requests go through.
+	StateClosed State = iota
+	// StateOpen means the endpoint is blocked.
+	StateOpen
+	// StateHalfOpen means the endpoint is being checked by a few requests.
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker decides whether an endpoint is available according to the results of the requests.
+type CircuitBreaker interface {
+	// State returns the current state.
+	State() State
+	// OnSend is called when a request is sent.
+	OnSend()
+	// OnSuccess is called when a request succeeds with its cost.
+	OnSuccess(cost time.Duration)
+	// OnFailure is called when a request fails with its cost.
+	OnFailure(cost time.Duration)
+	// Check is called periodically, the breaker may change its state, and returns the current state.
+	Check(now time.Time) State
+	// Reset closes the breaker and clears the statistics.
+	Reset()
+}
+
+// Factory creates a circuit breaker for an endpoint.
+type Factory func() CircuitBreaker
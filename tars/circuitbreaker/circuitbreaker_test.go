@@ -0,0 +1,68 @@
+package circuitbreaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefault(t *testing.T) {
+	cb := NewDefault(DefaultConfig{
+		FailN:        3,
+		FailInterval: time.Second,
+		CheckTime:    time.Minute,
+		OverN:        100,
+		FailRatio:    0.5,
+		TryInterval:  time.Second,
+	})
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		cb.OnSend()
+		cb.OnFailure(0)
+	}
+	if got := cb.Check(now); got != StateOpen {
+		t.Fatalf("Check() = %v, want %v after failures in a row", got, StateOpen)
+	}
+	if got := cb.Check(now.Add(500 * time.Millisecond)); got != StateOpen {
+		t.Fatalf("Check() = %v, want %v before try interval", got, StateOpen)
+	}
+	if got := cb.Check(now.Add(2 * time.Second)); got != StateHalfOpen {
+		t.Fatalf("Check() = %v, want %v after try interval", got, StateHalfOpen)
+	}
+	cb.OnSuccess(0)
+	if got := cb.State(); got != StateClosed {
+		t.Fatalf("State() = %v, want %v after success in half-open", got, StateClosed)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	cb := NewSlidingWindow(SlidingWindowConfig{
+		Window:      time.Minute,
+		Buckets:     6,
+		MinRequests: 4,
+		ErrorRatio:  0.5,
+		SlowCall:    100 * time.Millisecond,
+		OpenTimeout: time.Second,
+	})
+	cb.OnSuccess(time.Millisecond)
+	cb.OnSuccess(time.Millisecond)
+	cb.OnSuccess(time.Second) // slow call
+	if got := cb.State(); got != StateClosed {
+		t.Fatalf("State() = %v, want %v under min requests", got, StateClosed)
+	}
+	cb.OnFailure(0)
+	if got := cb.State(); got != StateOpen {
+		t.Fatalf("State() = %v, want %v with error ratio 0.5", got, StateOpen)
+	}
+	if got := cb.Check(time.Now().Add(2 * time.Second)); got != StateHalfOpen {
+		t.Fatalf("Check() = %v, want %v after open timeout", got, StateHalfOpen)
+	}
+	cb.OnSuccess(time.Second)
+	if got := cb.State(); got != StateOpen {
+		t.Fatalf("State() = %v, want %v after slow call in half-open", got, StateOpen)
+	}
+	cb.Check(time.Now().Add(2 * time.Second))
+	cb.OnSuccess(time.Millisecond)
+	if got := cb.State(); got != StateClosed {
+		t.Fatalf("State() = %v, want %v after success in half-open", got, StateClosed)
+	}
+}
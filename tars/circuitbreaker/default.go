@@ -0,0 +1,132 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultConfig is the config of the default circuit breaker.
+type DefaultConfig struct {
+	// FailN & FailInterval: the breaker opens if it fails FailN times in a row and no success within FailInterval.
+	FailN        int32
+	FailInterval time.Duration
+	// CheckTime & OverN & FailRatio: the breaker checks every CheckTime, and opens if the failures
+	// are more than OverN and the failure ratio is more than FailRatio.
+	CheckTime time.Duration
+	OverN     int32
+	FailRatio float32
+	// TryInterval is the wait time before the open breaker turns into half-open.
+	TryInterval time.Duration
+}
+
+// Default is the default circuit breaker, which counts the failures in a row and the failure ratio.
+type Default struct {
+	mu              sync.Mutex
+	conf            DefaultConfig
+	state           State
+	sendCount       int32
+	failCount       int32
+	lastFailCount   int32
+	lastSuccessTime time.Time
+	lastBlockTime   time.Time
+	lastCheckTime   time.Time
+}
+
+var _ CircuitBreaker = (*Default)(nil)
+
+// NewDefault returns the default circuit breaker.
+func NewDefault(conf DefaultConfig) *Default {
+	return &Default{conf: conf}
+}
+
+// NewDefaultFactory returns a factory of the default circuit breaker.
+func NewDefaultFactory(conf DefaultConfig) Factory {
+	return func() CircuitBreaker {
+		return NewDefault(conf)
+	}
+}
+
+// State returns the current state.
+func (d *Default) State() State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state
+}
+
+// OnSend counts the sent requests.
+func (d *Default) OnSend() {
+	d.mu.Lock()
+	d.sendCount++
+	d.mu.Unlock()
+}
+
+// OnSuccess closes the half-open breaker, and clears the failures in a row.
+func (d *Default) OnSuccess(_ time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.state == StateHalfOpen {
+		d.resetLocked(time.Now())
+		return
+	}
+	d.lastSuccessTime = time.Now()
+	d.lastFailCount = 0
+}
+
+// OnFailure counts the failures, and opens the half-open breaker again.
+func (d *Default) OnFailure(_ time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.state == StateHalfOpen {
+		d.state = StateOpen
+		d.lastBlockTime = time.Now()
+		return
+	}
+	d.lastFailCount++
+	d.failCount++
+}
+
+// Check opens the breaker if it fails too many times, and turns the open breaker into half-open after TryInterval.
+func (d *Default) Check(now time.Time) State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch d.state {
+	case StateClosed:
+		// fail FailN times in a row within FailInterval
+		if now.Sub(d.lastSuccessTime) >= d.conf.FailInterval && d.lastFailCount >= d.conf.FailN {
+			d.state = StateOpen
+			d.lastBlockTime = now
+			break
+		}
+		// lastCheckTime is only updated by Reset, as the former AdapterProxy.checkActive did.
+		if now.Sub(d.lastCheckTime) >= d.conf.CheckTime {
+			d.lastBlockTime = now
+			if d.failCount >= d.conf.OverN && float32(d.failCount)/float32(d.sendCount) >= d.conf.FailRatio {
+				d.state = StateOpen
+			}
+		}
+	case StateOpen:
+		if now.Sub(d.lastBlockTime) >= d.conf.TryInterval {
+			d.lastBlockTime = now
+			d.state = StateHalfOpen
+		}
+	case StateHalfOpen:
+		// no request checks the endpoint within TryInterval, open it and try again
+		if now.Sub(d.lastBlockTime) >= d.conf.TryInterval {
+			d.state = StateOpen
+		}
+	}
+	return d.state
+}
+
+// Reset closes the breaker and clears the statistics.
+func (d *Default) Reset() {
+	d.mu.Lock()
+	d.resetLocked(time.Now())
+	d.mu.Unlock()
+}
+
+func (d *Default) resetLocked(now time.Time) {
+	d.state = StateClosed
+	d.sendCount, d.failCount, d.lastFailCount = 0, 0, 0
+	d.lastBlockTime, d.lastCheckTime = now, now
+}
@@ -0,0 +1,200 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"time"
+)
+
+// SlidingWindowConfig is the config of the sliding window circuit breaker.
+type SlidingWindowConfig struct {
+	// Window is the time span of the statistics, which is divided into Buckets.
+	Window  time.Duration
+	Buckets int
+	// MinRequests is the min number of requests in the window before the breaker can open.
+	MinRequests int64
+	// ErrorRatio is the ratio of failed and slow requests which opens the breaker.
+	ErrorRatio float64
+	// SlowCall is the cost over which a successful request is counted as slow, zero means not counting slow calls.
+	SlowCall time.Duration
+	// OpenTimeout is the wait time before the open breaker turns into half-open.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of successful requests needed to close the half-open breaker.
+	HalfOpenRequests int64
+}
+
+type bucket struct {
+	total  int64
+	failed int64
+	slow   int64
+}
+
+// SlidingWindow is a circuit breaker based on the error rate of a sliding time window,
+// slow calls are also counted as errors.
+type SlidingWindow struct {
+	mu        sync.Mutex
+	conf      SlidingWindowConfig
+	state     State
+	buckets   []bucket
+	bucketDur time.Duration
+	current   int
+	bucketAt  time.Time
+	openAt    time.Time
+	halfOpenN int64
+}
+
+var _ CircuitBreaker = (*SlidingWindow)(nil)
+
+// NewSlidingWindow returns a sliding window circuit breaker.
+func NewSlidingWindow(conf SlidingWindowConfig) *SlidingWindow {
+	if conf.Buckets <= 0 {
+		conf.Buckets = 10
+	}
+	if conf.HalfOpenRequests <= 0 {
+		conf.HalfOpenRequests = 1
+	}
+	bucketDur := conf.Window / time.Duration(conf.Buckets)
+	if bucketDur <= 0 {
+		bucketDur = time.Second
+	}
+	return &SlidingWindow{
+		conf:      conf,
+		buckets:   make([]bucket, conf.Buckets),
+		bucketDur: bucketDur,
+		bucketAt:  time.Now(),
+	}
+}
+
+// NewSlidingWindowFactory returns a factory of the sliding window circuit breaker.
+func NewSlidingWindowFactory(conf SlidingWindowConfig) Factory {
+	return func() CircuitBreaker {
+		return NewSlidingWindow(conf)
+	}
+}
+
+// State returns the current state.
+func (w *SlidingWindow) State() State {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.state
+}
+
+// OnSend does nothing, requests are counted when they finish.
+func (w *SlidingWindow) OnSend() {}
+
+// OnSuccess counts the successful request, and the slow one is counted as an error.
+func (w *SlidingWindow) OnSuccess(cost time.Duration) {
+	slow := w.conf.SlowCall > 0 && cost >= w.conf.SlowCall
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := time.Now()
+	if w.state == StateHalfOpen {
+		if slow {
+			w.openLocked(now)
+			return
+		}
+		w.halfOpenN++
+		if w.halfOpenN >= w.conf.HalfOpenRequests {
+			w.resetLocked(now)
+		}
+		return
+	}
+	b := w.bucketLocked(now)
+	b.total++
+	if slow {
+		b.slow++
+	}
+	w.tripLocked(now)
+}
+
+// OnFailure counts the failed request.
+func (w *SlidingWindow) OnFailure(_ time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := time.Now()
+	if w.state == StateHalfOpen {
+		w.openLocked(now)
+		return
+	}
+	b := w.bucketLocked(now)
+	b.total++
+	b.failed++
+	w.tripLocked(now)
+}
+
+// Check turns the open breaker into half-open after OpenTimeout.
+func (w *SlidingWindow) Check(now time.Time) State {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch w.state {
+	case StateClosed:
+		w.bucketLocked(now)
+		w.tripLocked(now)
+	case StateOpen:
+		if now.Sub(w.openAt) >= w.conf.OpenTimeout {
+			w.state = StateHalfOpen
+			w.openAt = now
+			w.halfOpenN = 0
+		}
+	case StateHalfOpen:
+		// no request checks the endpoint within OpenTimeout, open it and try again
+		if now.Sub(w.openAt) >= w.conf.OpenTimeout {
+			w.state = StateOpen
+		}
+	}
+	return w.state
+}
+
+// Reset closes the breaker and clears the window.
+func (w *SlidingWindow) Reset() {
+	w.mu.Lock()
+	w.resetLocked(time.Now())
+	w.mu.Unlock()
+}
+
+func (w *SlidingWindow) resetLocked(now time.Time) {
+	w.state = StateClosed
+	w.halfOpenN = 0
+	for i := range w.buckets {
+		w.buckets[i] = bucket{}
+	}
+	w.current = 0
+	w.bucketAt = now
+}
+
+func (w *SlidingWindow) openLocked(now time.Time) {
+	w.state = StateOpen
+	w.openAt = now
+	w.halfOpenN = 0
+}
+
+// bucketLocked slides the window to now, and returns the current bucket.
+func (w *SlidingWindow) bucketLocked(now time.Time) *bucket {
+	if now.Sub(w.bucketAt) >= w.bucketDur*time.Duration(len(w.buckets)) {
+		// the whole window is expired
+		for i := range w.buckets {
+			w.buckets[i] = bucket{}
+		}
+		w.current = 0
+		w.bucketAt = now
+	}
+	for now.Sub(w.bucketAt) >= w.bucketDur {
+		w.current = (w.current + 1) % len(w.buckets)
+		w.buckets[w.current] = bucket{}
+		w.bucketAt = w.bucketAt.Add(w.bucketDur)
+	}
+	return &w.buckets[w.current]
+}
+
+func (w *SlidingWindow) tripLocked(now time.Time) {
+	if w.state != StateClosed {
+		return
+	}
+	var total, errs int64
+	for _, b := range w.buckets {
+		total += b.total
+		errs += b.failed + b.slow
+	}
+	if total >= w.conf.MinRequests && total > 0 && float64(errs)/float64(total) >= w.conf.ErrorRatio {
+		w.openLocked(now)
+	}
+}
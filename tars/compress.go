@@ -0,0 +1,179 @@
+package tars
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TarsCloud/TarsGo/tars/compress"
+	"github.com/TarsCloud/TarsGo/tars/protocol"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+	"github.com/TarsCloud/TarsGo/tars/util/conf"
+	"github.com/TarsCloud/TarsGo/tars/util/tools"
+)
+
+const (
+	// StatusCompressKey is the status key of the compressor of the payload, which is set with TARSMESSAGETYPECOMPRESS.
+	StatusCompressKey = "STATUS_COMPRESS_KEY"
+	// StatusAcceptCompressKey is the status key of the compressors accepted by the peer, joined by ",".
+	// The client sends it to accept compressed responses, and the server echoes its compressors,
+	// so the client compresses the requests only for the servers which have echoed.
+	StatusAcceptCompressKey = "STATUS_ACCEPT_COMPRESS_KEY"
+)
+
+// CompressPolicy is the client side payload compression of a servant proxy.
+type CompressPolicy struct {
+	// Algorithms are the compressors accepted for the responses in the order of preference,
+	// and the request is compressed by the first one which is supported by the server.
+	Algorithms []string
+	// Threshold is the min length of the request payload to compress.
+	Threshold int
+}
+
+// NewCompressPolicy returns a compress policy of the algorithms with the default threshold.
+func NewCompressPolicy(algorithms ...string) *CompressPolicy {
+	return &CompressPolicy{Algorithms: algorithms, Threshold: CompressThreshold}
+}
+
+func (p *CompressPolicy) enabled() bool {
+	return p != nil && len(p.Algorithms) > 0
+}
+
+// compressor returns the compressor to compress the request for the server which supports the names.
+func (p *CompressPolicy) compressor(names string) compress.Compressor {
+	for _, algorithm := range p.Algorithms {
+		for _, name := range strings.Split(names, ",") {
+			if name == algorithm {
+				return compress.Get(name)
+			}
+		}
+	}
+	return nil
+}
+
+func parseCompressPolicy(c *conf.Conf, path string) *CompressPolicy {
+	algorithms := splitConfList(c.GetString(path + "<compress>"))
+	if len(algorithms) == 0 {
+		return nil
+	}
+	for _, name := range algorithms {
+		if compress.Get(name) == nil {
+			TLOG.Errorf("compressor %s of %s is not registered", name, path)
+		}
+	}
+	p := NewCompressPolicy(algorithms...)
+	p.Threshold = c.GetIntWithDef(path+"<compress-threshold>", CompressThreshold)
+	return p
+}
+
+// compressRequest returns a copy of the request with the payload compressed if the adapter supports it,
+// otherwise the request itself.
+func (s *ServantProxy) compressRequest(req *requestf.RequestPacket, adp *AdapterProxy) *requestf.RequestPacket {
+	p := s.compress
+	if !p.enabled() || len(req.SBuffer) < p.Threshold || req.HasMessageType(basef.TARSMESSAGETYPECOMPRESS) {
+		return req
+	}
+	names, _ := adp.compressNames.Load().(string)
+	c := p.compressor(names)
+	if c == nil {
+		return req
+	}
+	data, err := c.Compress(tools.Int8ToByte(req.SBuffer))
+	if err != nil {
+		TLOG.Errorf("compress request of %s.%s error: %v", req.SServantName, req.SFuncName, err)
+		return req
+	}
+	if len(data) >= len(req.SBuffer) {
+		return req
+	}
+	cReq := *req
+	cReq.Status = make(map[string]string, len(req.Status)+1)
+	for k, v := range req.Status {
+		cReq.Status[k] = v
+	}
+	cReq.Status[StatusCompressKey] = c.Name()
+	cReq.AddMessageType(basef.TARSMESSAGETYPECOMPRESS)
+	cReq.SBuffer = tools.ByteToInt8(data)
+	return &cReq
+}
+
+// decompressResponse decompresses the payload of the response, and records the compressors supported by the server.
+func (c *AdapterProxy) decompressResponse(rsp *requestf.ResponsePacket) {
+	if names, ok := rsp.Status[StatusAcceptCompressKey]; ok {
+		if old, _ := c.compressNames.Load().(string); old != names {
+			c.compressNames.Store(names)
+		}
+	}
+	if !rsp.HasMessageType(basef.TARSMESSAGETYPECOMPRESS) {
+		return
+	}
+	data, err := decompressPayload(rsp.Status, rsp.SBuffer, protocol.GetMaxPackageLength())
+	if err != nil {
+		TLOG.Errorf("decompress response %d error: %v", rsp.IRequestId, err)
+		rsp.IRet = basef.TARSCLIENTDECODEERR
+		rsp.SResultDesc = err.Error()
+		rsp.SBuffer = nil
+		return
+	}
+	rsp.SBuffer = data
+}
+
+// decompressRequest decompresses the payload of the request.
+func (s *Protocol) decompressRequest(req *requestf.RequestPacket) error {
+	if !req.HasMessageType(basef.TARSMESSAGETYPECOMPRESS) {
+		return nil
+	}
+	data, err := decompressPayload(req.Status, req.SBuffer, s.app.svrCfg.MaxPackageLength)
+	if err != nil {
+		TLOG.Errorf("decompress request of %s.%s error: %v", req.SServantName, req.SFuncName, err)
+		return err
+	}
+	req.SBuffer = data
+	req.IMessageType &^= basef.TARSMESSAGETYPECOMPRESS
+	delete(req.Status, StatusCompressKey)
+	return nil
+}
+
+// compressResponse compresses the payload of the response if the client accepts it,
+// and tells the client the compressors supported by the server.
+func (s *Protocol) compressResponse(req *requestf.RequestPacket, rsp *requestf.ResponsePacket) {
+	accept, ok := req.Status[StatusAcceptCompressKey]
+	if !ok || rsp.IVersion != basef.TARSVERSION {
+		return
+	}
+	if rsp.Status == nil {
+		rsp.Status = make(map[string]string)
+	}
+	rsp.Status[StatusAcceptCompressKey] = compress.Names()
+	if len(rsp.SBuffer) < s.app.svrCfg.CompressThreshold {
+		return
+	}
+	c := compress.Negotiate(accept)
+	if c == nil {
+		return
+	}
+	data, err := c.Compress(tools.Int8ToByte(rsp.SBuffer))
+	if err != nil {
+		TLOG.Errorf("compress response of %s.%s error: %v", req.SServantName, req.SFuncName, err)
+		return
+	}
+	if len(data) >= len(rsp.SBuffer) {
+		return
+	}
+	rsp.Status[StatusCompressKey] = c.Name()
+	rsp.AddMessageType(basef.TARSMESSAGETYPECOMPRESS)
+	rsp.SBuffer = tools.ByteToInt8(data)
+}
+
+func decompressPayload(status map[string]string, payload []int8, limit int) ([]int8, error) {
+	name := status[StatusCompressKey]
+	c := compress.Get(name)
+	if c == nil {
+		return nil, fmt.Errorf("unsupported compressor: %s", name)
+	}
+	data, err := c.Decompress(tools.Int8ToByte(payload), limit)
+	if err != nil {
+		return nil, err
+	}
+	return tools.ByteToInt8(data), nil
+}
@@ -0,0 +1,66 @@
+// Package compress provides the compressors of the request and response payloads.
+// The gzip compressor is built in, others such as snappy and zstd can be registered by Register.
+package compress
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrTooLarge is returned when the decompressed data exceeds the limit.
+var ErrTooLarge = errors.New("compress: decompressed data is too large")
+
+// Compressor compresses and decompresses the payloads.
+type Compressor interface {
+	// Name is the name of the algorithm, which is negotiated by the client and the server.
+	Name() string
+	// Compress returns the compressed data.
+	Compress(data []byte) ([]byte, error)
+	// Decompress returns the decompressed data, ErrTooLarge if it is larger than limit.
+	Decompress(data []byte, limit int) ([]byte, error)
+}
+
+var (
+	mu          sync.RWMutex
+	compressors = make(map[string]Compressor)
+	names       []string
+)
+
+func init() {
+	Register(&gzipCompressor{})
+}
+
+// Register registers the compressor by its name, the compressor registered later replaces the former.
+func Register(c Compressor) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := compressors[c.Name()]; !ok {
+		names = append(names, c.Name())
+	}
+	compressors[c.Name()] = c
+}
+
+// Get returns the compressor of the name, nil if it is not registered.
+func Get(name string) Compressor {
+	mu.RLock()
+	defer mu.RUnlock()
+	return compressors[name]
+}
+
+// Names returns the names of the registered compressors joined by ",".
+func Names() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return strings.Join(names, ",")
+}
+
+// Negotiate returns the first compressor of the names joined by "," which is registered.
+func Negotiate(names string) Compressor {
+	for _, name := range strings.Split(names, ",") {
+		if c := Get(strings.TrimSpace(name)); c != nil {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package compress
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGzip(t *testing.T) {
+	c := Get(Gzip)
+	if c == nil {
+		t.Fatal("gzip is not registered")
+	}
+	data := bytes.Repeat([]byte("tars"), 1024)
+	compressed, err := c.Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) >= len(data) {
+		t.Errorf("compressed %d bytes to %d bytes", len(data), len(compressed))
+	}
+	out, err := c.Decompress(compressed, len(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Error("decompressed data mismatch")
+	}
+	if _, err = c.Decompress(compressed, len(data)-1); err != ErrTooLarge {
+		t.Errorf("Decompress over limit: %v", err)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	if c := Negotiate("unknown, gzip"); c == nil || c.Name() != Gzip {
+		t.Errorf("Negotiate() = %v, want gzip", c)
+	}
+	if c := Negotiate("unknown"); c != nil {
+		t.Errorf("Negotiate() = %v, want nil", c)
+	}
+}
@@ -0,0 +1,63 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// Gzip is the name of the gzip compressor.
+const Gzip = "gzip"
+
+var (
+	gzipWriters sync.Pool
+	gzipReaders sync.Pool
+)
+
+type gzipCompressor struct{}
+
+func (c *gzipCompressor) Name() string {
+	return Gzip
+}
+
+func (c *gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, ok := gzipWriters.Get().(*gzip.Writer)
+	if ok {
+		w.Reset(&buf)
+	} else {
+		w = gzip.NewWriter(&buf)
+	}
+	defer gzipWriters.Put(w)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *gzipCompressor) Decompress(data []byte, limit int) ([]byte, error) {
+	var err error
+	r, ok := gzipReaders.Get().(*gzip.Reader)
+	if ok {
+		err = r.Reset(bytes.NewReader(data))
+	} else {
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReaders.Put(r)
+	out, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > limit {
+		return nil, ErrTooLarge
+	}
+	return out, nil
+}
@@ -0,0 +1,97 @@
+package tars
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/TarsCloud/TarsGo/tars/compress"
+	"github.com/TarsCloud/TarsGo/tars/protocol"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+	"github.com/TarsCloud/TarsGo/tars/util/tools"
+)
+
+type echoDispatcher struct {
+	compressed bool
+}
+
+func (d *echoDispatcher) Dispatch(ctx context.Context, imp interface{}, req *requestf.RequestPacket, rsp *requestf.ResponsePacket, withContext bool) error {
+	d.compressed = req.HasMessageType(basef.TARSMESSAGETYPECOMPRESS)
+	rsp.SBuffer = req.SBuffer
+	return nil
+}
+
+func TestCompressNegotiation(t *testing.T) {
+	app := newApp()
+	d := &echoDispatcher{}
+	server := NewTarsProtocol(d, nil, false)
+	server.app = app
+	sp := &ServantProxy{proto: &protocol.TarsProtocol{}, compress: NewCompressPolicy("unknown", compress.Gzip)}
+	adp := &AdapterProxy{servantProxy: sp}
+	payload := bytes.Repeat([]byte("tars"), CompressThreshold)
+
+	invoke := func() (*requestf.RequestPacket, *requestf.ResponsePacket) {
+		req := &requestf.RequestPacket{
+			IVersion:    basef.TARSVERSION,
+			CPacketType: basef.TARSNORMAL,
+			IRequestId:  1,
+			SFuncName:   "echo",
+			SBuffer:     tools.ByteToInt8(payload),
+			Status:      map[string]string{StatusAcceptCompressKey: "unknown,gzip"},
+		}
+		sent := sp.compressRequest(req, adp)
+		pkg, err := sp.proto.RequestPack(sent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp, err := sp.proto.ResponseUnpack(server.Invoke(context.Background(), pkg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rsp.HasMessageType(basef.TARSMESSAGETYPECOMPRESS) || len(rsp.SBuffer) >= len(payload) {
+			t.Error("response is not compressed")
+		}
+		adp.decompressResponse(rsp)
+		if rsp.IRet != 0 || !bytes.Equal(tools.Int8ToByte(rsp.SBuffer), payload) {
+			t.Errorf("response mismatch, ret: %d, desc: %s", rsp.IRet, rsp.SResultDesc)
+		}
+		return sent, rsp
+	}
+
+	// the request is not compressed until the server tells it supports gzip
+	if sent, _ := invoke(); sent.HasMessageType(basef.TARSMESSAGETYPECOMPRESS) {
+		t.Error("request is compressed before negotiation")
+	}
+	if sent, _ := invoke(); !sent.HasMessageType(basef.TARSMESSAGETYPECOMPRESS) || sent.Status[StatusCompressKey] != compress.Gzip {
+		t.Error("request is not compressed after negotiation")
+	}
+	if d.compressed {
+		t.Error("request is not decompressed before dispatch")
+	}
+}
+
+func TestCompressUnsupported(t *testing.T) {
+	server := NewTarsProtocol(&echoDispatcher{}, nil, false)
+	server.app = newApp()
+	proto := &protocol.TarsProtocol{}
+	req := &requestf.RequestPacket{
+		IVersion:     basef.TARSVERSION,
+		IRequestId:   1,
+		SFuncName:    "echo",
+		SBuffer:      []int8{1, 2, 3},
+		Status:       map[string]string{StatusCompressKey: "unknown"},
+		IMessageType: basef.TARSMESSAGETYPECOMPRESS,
+	}
+	pkg, err := proto.RequestPack(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp, err := proto.ResponseUnpack(server.Invoke(context.Background(), pkg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.IRet != basef.TARSSERVERDECODEERR {
+		t.Errorf("IRet = %d, want %d", rsp.IRet, basef.TARSSERVERDECODEERR)
+	}
+}
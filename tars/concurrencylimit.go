@@ -0,0 +1,67 @@
+package tars
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/concurrencylimit"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+)
+
+type objLimiter struct {
+	*concurrencylimit.Limiter
+	limitReport  *PropertyReport
+	rejectReport *PropertyReport
+}
+
+// NewConcurrencyLimitMiddleware returns the client filter middleware which limits the in-flight requests of every object,
+// the limit is adjusted by the algorithm created by the factory, such as AIMD, Vegas and Gradient.
+// The requests over the limit fail fast with TARSCLIENTCONCURRENCYLIMITED, the limit and the rejected requests
+// are reported as the properties "<obj>_concurrency_limit" and "<obj>_concurrency_reject".
+func NewConcurrencyLimitMiddleware(f concurrencylimit.Factory) ClientFilterMiddleware {
+	var limiters sync.Map
+	getLimiter := func(obj string) *objLimiter {
+		if v, ok := limiters.Load(obj); ok {
+			return v.(*objLimiter)
+		}
+		l := &objLimiter{Limiter: concurrencylimit.NewLimiter(f())}
+		// the property reports need the property server
+		if GetClientConfig().ValidateProperty() == nil {
+			l.limitReport = CreatePropertyReport(obj+"_concurrency_limit", NewAvg(), NewMin())
+			l.rejectReport = CreatePropertyReport(obj+"_concurrency_reject", NewSum())
+		}
+		v, _ := limiters.LoadOrStore(obj, l)
+		return v.(*objLimiter)
+	}
+	return func(next ClientFilter) ClientFilter {
+		return func(ctx context.Context, msg *Message, invoke Invoke, timeout time.Duration) error {
+			if msg.Req.CPacketType == basef.TARSONEWAY {
+				return next(ctx, msg, invoke, timeout)
+			}
+			l := getLimiter(msg.Req.SServantName)
+			inflight, ok := l.Acquire()
+			if !ok {
+				if l.rejectReport != nil {
+					l.rejectReport.Report(1)
+				}
+				return Errorf(basef.TARSCLIENTCONCURRENCYLIMITED, "concurrency limit %d exceeded, obj: %s, func: %s",
+					l.Limit(), msg.Req.SServantName, msg.Req.SFuncName)
+			}
+			start := time.Now()
+			err := next(ctx, msg, invoke, timeout)
+			dropped := false
+			if err != nil {
+				switch msgErrorCode(msg, err) {
+				case basef.TARSINVOKETIMEOUT, basef.TARSSERVEROVERLOAD, basef.TARSSERVERQUEUETIMEOUT:
+					dropped = true
+				}
+			}
+			l.Release(inflight, time.Since(start), dropped)
+			if l.limitReport != nil {
+				l.limitReport.Report(l.Limit())
+			}
+			return err
+		}
+	}
+}
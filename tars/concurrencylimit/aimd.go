@@ -0,0 +1,65 @@
+package concurrencylimit
+
+import (
+	"sync"
+	"time"
+)
+
+// AIMDConfig is the config of the AIMD limit.
+type AIMDConfig struct {
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+	// BackoffRatio is the ratio by which the limit is multiplied when a request is dropped.
+	BackoffRatio float64
+	// Timeout is the rtt over which a request is counted as dropped, zero means not checking the rtt.
+	Timeout time.Duration
+}
+
+// AIMD increases the limit by one when the requests succeed and the limit is used by at least half,
+// and decreases it multiplicatively when a request is dropped.
+type AIMD struct {
+	mu    sync.Mutex
+	conf  AIMDConfig
+	limit float64
+}
+
+var _ Limit = (*AIMD)(nil)
+
+// NewAIMD returns an AIMD limit.
+func NewAIMD(conf AIMDConfig) *AIMD {
+	fixLimits(&conf.InitialLimit, &conf.MinLimit, &conf.MaxLimit)
+	if conf.BackoffRatio <= 0 || conf.BackoffRatio >= 1 {
+		conf.BackoffRatio = 0.9
+	}
+	return &AIMD{conf: conf, limit: float64(conf.InitialLimit)}
+}
+
+// NewAIMDFactory returns a factory of the AIMD limit.
+func NewAIMDFactory(conf AIMDConfig) Factory {
+	return func() Limit {
+		return NewAIMD(conf)
+	}
+}
+
+// Limit returns the current concurrency limit.
+func (a *AIMD) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.limit)
+}
+
+// OnSample adjusts the limit by the sample.
+func (a *AIMD) OnSample(rtt time.Duration, inflight int, dropped bool) {
+	if a.conf.Timeout > 0 && rtt > a.conf.Timeout {
+		dropped = true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if dropped {
+		a.limit = a.limit * a.conf.BackoffRatio
+	} else if float64(inflight)*2 >= a.limit {
+		a.limit++
+	}
+	a.limit = clamp(a.limit, float64(a.conf.MinLimit), float64(a.conf.MaxLimit))
+}
@@ -0,0 +1,93 @@
+package concurrencylimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// GradientConfig is the config of the Gradient limit.
+type GradientConfig struct {
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+	// Smoothing is the weight of the new limit, in (0, 1].
+	Smoothing float64
+	// Tolerance is the ratio of the short-term rtt to the long-term rtt which is tolerated before reducing the limit.
+	Tolerance float64
+	// LongWindow is the number of samples of the long-term rtt average.
+	LongWindow int
+}
+
+// Gradient adjusts the limit by the gradient of the long-term average rtt to the current rtt,
+// the limit shrinks when the rtt grows over the tolerance, and grows by sqrt(limit) otherwise.
+type Gradient struct {
+	mu      sync.Mutex
+	conf    GradientConfig
+	limit   float64
+	longRtt float64
+}
+
+var _ Limit = (*Gradient)(nil)
+
+// NewGradient returns a Gradient limit.
+func NewGradient(conf GradientConfig) *Gradient {
+	fixLimits(&conf.InitialLimit, &conf.MinLimit, &conf.MaxLimit)
+	if conf.Smoothing <= 0 || conf.Smoothing > 1 {
+		conf.Smoothing = 0.2
+	}
+	if conf.Tolerance < 1 {
+		conf.Tolerance = 1.5
+	}
+	if conf.LongWindow <= 0 {
+		conf.LongWindow = 600
+	}
+	return &Gradient{conf: conf, limit: float64(conf.InitialLimit)}
+}
+
+// NewGradientFactory returns a factory of the Gradient limit.
+func NewGradientFactory(conf GradientConfig) Factory {
+	return func() Limit {
+		return NewGradient(conf)
+	}
+}
+
+// Limit returns the current concurrency limit.
+func (g *Gradient) Limit() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return int(g.limit)
+}
+
+// OnSample adjusts the limit by the sample.
+func (g *Gradient) OnSample(rtt time.Duration, inflight int, dropped bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var gradient float64
+	if dropped {
+		gradient = 0.5
+	} else {
+		if rtt <= 0 {
+			return
+		}
+		shortRtt := float64(rtt)
+		if g.longRtt == 0 {
+			g.longRtt = shortRtt
+		} else {
+			alpha := 2 / float64(g.conf.LongWindow+1)
+			g.longRtt = g.longRtt*(1-alpha) + shortRtt*alpha
+		}
+		if g.longRtt/shortRtt > 2 {
+			// the load has dropped a lot, let the long-term rtt catch up
+			g.longRtt *= 0.95
+		}
+		if float64(inflight)*2 < g.limit {
+			// the limit is not used, it tells nothing
+			return
+		}
+		gradient = clamp(g.conf.Tolerance*g.longRtt/shortRtt, 0.5, 1)
+	}
+	newLimit := g.limit*gradient + math.Sqrt(g.limit)
+	g.limit = g.limit*(1-g.conf.Smoothing) + newLimit*g.conf.Smoothing
+	g.limit = clamp(g.limit, float64(g.conf.MinLimit), float64(g.conf.MaxLimit))
+}
@@ -0,0 +1,84 @@
+package concurrencylimit
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const defaultMaxLimit = 1000
+
+// Limit is the algorithm which adjusts the concurrency limit according to the samples of the requests.
+type Limit interface {
+	// Limit returns the current concurrency limit.
+	Limit() int
+	// OnSample is called when a request finishes, with its round trip time, the in-flight requests when it was sent,
+	// and whether it was dropped, which means timeout or overload.
+	OnSample(rtt time.Duration, inflight int, dropped bool)
+}
+
+// Factory creates a limit algorithm for an object.
+type Factory func() Limit
+
+// Limiter limits the in-flight requests by the limit algorithm.
+type Limiter struct {
+	limit    Limit
+	inflight int32
+}
+
+// NewLimiter returns a limiter with the limit algorithm.
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{limit: limit}
+}
+
+// Acquire takes a slot for the request, it returns false if the limit is reached.
+// The returned inflight should be passed to Release when the request finishes.
+func (l *Limiter) Acquire() (inflight int, ok bool) {
+	n := atomic.AddInt32(&l.inflight, 1)
+	if int(n) > l.limit.Limit() {
+		atomic.AddInt32(&l.inflight, -1)
+		return 0, false
+	}
+	return int(n), true
+}
+
+// Release gives back the slot and feeds the sample to the limit algorithm.
+func (l *Limiter) Release(inflight int, rtt time.Duration, dropped bool) {
+	atomic.AddInt32(&l.inflight, -1)
+	l.limit.OnSample(rtt, inflight, dropped)
+}
+
+// Limit returns the current concurrency limit.
+func (l *Limiter) Limit() int {
+	return l.limit.Limit()
+}
+
+// Inflight returns the number of in-flight requests.
+func (l *Limiter) Inflight() int {
+	return int(atomic.LoadInt32(&l.inflight))
+}
+
+func clamp(v, min, max float64) float64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// fixLimits fills the invalid limits with defaults.
+func fixLimits(initial, min, max *int) {
+	if *min <= 0 {
+		*min = 1
+	}
+	if *max < *min {
+		*max = defaultMaxLimit
+	}
+	if *initial < *min {
+		*initial = *min
+	}
+	if *initial > *max {
+		*initial = *max
+	}
+}
@@ -0,0 +1,77 @@
+package concurrencylimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(NewAIMD(AIMDConfig{InitialLimit: 2, MaxLimit: 10}))
+	n1, ok1 := l.Acquire()
+	n2, ok2 := l.Acquire()
+	if !ok1 || !ok2 {
+		t.Fatal("Acquire() = false, want true under the limit")
+	}
+	if _, ok := l.Acquire(); ok {
+		t.Fatal("Acquire() = true, want false over the limit")
+	}
+	l.Release(n1, time.Millisecond, false)
+	l.Release(n2, time.Millisecond, false)
+	if got := l.Inflight(); got != 0 {
+		t.Fatalf("Inflight() = %d, want 0", got)
+	}
+	if got := l.Limit(); got != 4 {
+		t.Fatalf("Limit() = %d, want 4 after successes", got)
+	}
+}
+
+func TestAIMD(t *testing.T) {
+	a := NewAIMD(AIMDConfig{InitialLimit: 10, MaxLimit: 20, BackoffRatio: 0.5, Timeout: time.Second})
+	a.OnSample(time.Millisecond, 1, false)
+	if got := a.Limit(); got != 10 {
+		t.Fatalf("Limit() = %d, want 10 when the limit is not used", got)
+	}
+	a.OnSample(time.Millisecond, 10, false)
+	if got := a.Limit(); got != 11 {
+		t.Fatalf("Limit() = %d, want 11 after success", got)
+	}
+	a.OnSample(2*time.Second, 10, false)
+	if got := a.Limit(); got != 5 {
+		t.Fatalf("Limit() = %d, want 5 after timeout", got)
+	}
+}
+
+func TestVegas(t *testing.T) {
+	v := NewVegas(VegasConfig{InitialLimit: 20, MaxLimit: 100})
+	v.OnSample(10*time.Millisecond, 20, false)
+	for i := 0; i < 10; i++ {
+		v.OnSample(10*time.Millisecond, v.Limit(), false)
+	}
+	grown := v.Limit()
+	if grown <= 20 {
+		t.Fatalf("Limit() = %d, want > 20 without queueing", grown)
+	}
+	for i := 0; i < 10; i++ {
+		v.OnSample(100*time.Millisecond, v.Limit(), false)
+	}
+	if got := v.Limit(); got >= grown {
+		t.Fatalf("Limit() = %d, want < %d with queueing", got, grown)
+	}
+}
+
+func TestGradient(t *testing.T) {
+	g := NewGradient(GradientConfig{InitialLimit: 20, MaxLimit: 100})
+	for i := 0; i < 10; i++ {
+		g.OnSample(10*time.Millisecond, g.Limit(), false)
+	}
+	grown := g.Limit()
+	if grown <= 20 {
+		t.Fatalf("Limit() = %d, want > 20 with stable rtt", grown)
+	}
+	for i := 0; i < 10; i++ {
+		g.OnSample(0, g.Limit(), true)
+	}
+	if got := g.Limit(); got >= grown {
+		t.Fatalf("Limit() = %d, want < %d after drops", got, grown)
+	}
+}
@@ -0,0 +1,95 @@
+package concurrencylimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// VegasConfig is the config of the Vegas limit.
+type VegasConfig struct {
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+	// Alpha and Beta are the lower and upper bounds of the estimated queue size, as multiples of log10(limit).
+	Alpha float64
+	Beta  float64
+	// ProbeSamples is the number of samples after which the no-load rtt is measured again.
+	ProbeSamples int
+}
+
+// Vegas estimates the queue size of the server by comparing the rtt with the min rtt without load,
+// the limit grows when the queue is small and shrinks when the queue is large, like TCP Vegas.
+type Vegas struct {
+	mu        sync.Mutex
+	conf      VegasConfig
+	limit     float64
+	rttNoLoad time.Duration
+	samples   int
+}
+
+var _ Limit = (*Vegas)(nil)
+
+// NewVegas returns a Vegas limit.
+func NewVegas(conf VegasConfig) *Vegas {
+	fixLimits(&conf.InitialLimit, &conf.MinLimit, &conf.MaxLimit)
+	if conf.Alpha <= 0 {
+		conf.Alpha = 3
+	}
+	if conf.Beta <= conf.Alpha {
+		conf.Beta = conf.Alpha * 2
+	}
+	if conf.ProbeSamples <= 0 {
+		conf.ProbeSamples = 1000
+	}
+	return &Vegas{conf: conf, limit: float64(conf.InitialLimit)}
+}
+
+// NewVegasFactory returns a factory of the Vegas limit.
+func NewVegasFactory(conf VegasConfig) Factory {
+	return func() Limit {
+		return NewVegas(conf)
+	}
+}
+
+// Limit returns the current concurrency limit.
+func (v *Vegas) Limit() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return int(v.limit)
+}
+
+// OnSample adjusts the limit by the sample.
+func (v *Vegas) OnSample(rtt time.Duration, inflight int, dropped bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.samples++
+	if v.samples >= v.conf.ProbeSamples {
+		// the min rtt may be out of date, measure it again
+		v.samples = 0
+		v.rttNoLoad = 0
+	}
+	if rtt <= 0 {
+		return
+	}
+	if v.rttNoLoad == 0 || rtt < v.rttNoLoad {
+		v.rttNoLoad = rtt
+		return
+	}
+
+	log := math.Max(1, math.Log10(v.limit))
+	if dropped {
+		v.limit -= log
+	} else if float64(inflight)*2 >= v.limit {
+		queue := math.Ceil(v.limit * (1 - float64(v.rttNoLoad)/float64(rtt)))
+		switch {
+		case queue <= log:
+			v.limit += v.conf.Beta * log
+		case queue < v.conf.Alpha*log:
+			v.limit += log
+		case queue > v.conf.Beta*log:
+			v.limit -= log
+		}
+	}
+	v.limit = clamp(v.limit, float64(v.conf.MinLimit), float64(v.conf.MaxLimit))
+}
@@ -0,0 +1,36 @@
+package tars
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/concurrencylimit"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+)
+
+func TestConcurrencyLimitMiddleware(t *testing.T) {
+	cf := NewConcurrencyLimitMiddleware(concurrencylimit.NewAIMDFactory(concurrencylimit.AIMDConfig{InitialLimit: 1}))(
+		func(ctx context.Context, msg *Message, invoke Invoke, timeout time.Duration) error {
+			return invoke(ctx, msg, timeout)
+		})
+	newMsg := func() *Message {
+		return &Message{Req: &requestf.RequestPacket{SServantName: "App.Server.Obj", SFuncName: "test"}}
+	}
+	started, done := make(chan struct{}), make(chan struct{})
+	go cf(context.Background(), newMsg(), func(ctx context.Context, msg *Message, timeout time.Duration) error {
+		close(started)
+		<-done
+		return nil
+	}, time.Second)
+	<-started
+
+	err := cf(context.Background(), newMsg(), func(ctx context.Context, msg *Message, timeout time.Duration) error {
+		return nil
+	}, time.Second)
+	if code := GetErrorCode(err); code != basef.TARSCLIENTCONCURRENCYLIMITED {
+		t.Fatalf("GetErrorCode() = %d, want %d over the limit", code, basef.TARSCLIENTCONCURRENCYLIMITED)
+	}
+	close(done)
+}
@@ -14,6 +14,13 @@ type adapterConfig struct {
 	Protocol string
 	Obj      string
 	Threads  int
+	// HandleTimeout is the handler timeout of the servant, and FuncTimeouts overrides it for the functions.
+	HandleTimeout time.Duration
+	FuncTimeouts  map[string]time.Duration
+	// FuncPriorities is the priorities of the functions for the priority queues.
+	FuncPriorities map[string]int
+	// AuthType is endpoint.AuthTypeLocal if the connections must be authenticated first.
+	AuthType endpoint.AuthType
 }
 
 type serverConfig struct {
@@ -60,6 +67,24 @@ type serverConfig struct {
 	StatReportChannelBufLen int32
 	MaxPackageLength        int
 	GracedownTimeout        time.Duration
+	// the timeouts of the graceful shutdown phases, which are limited by GracedownTimeout in all
+	NotReadyTimeout   time.Duration
+	DeregisterTimeout time.Duration
+	PropagationDelay  time.Duration
+	StopAcceptTimeout time.Duration
+	DrainTimeout      time.Duration
+	DestroyTimeout    time.Duration
+	// CompressThreshold is the min length of the response payload to compress when the client accepts it.
+	CompressThreshold int
+	// PriorityQueue queues the requests by priority, and PriorityMaxSkip is the starvation protection of it.
+	PriorityQueue   bool
+	PriorityMaxSkip int
+	// MaxConns and MaxConnsPerIP limit the connections of every adapter in total and per client ip,
+	// and AcceptRate limits the connections accepted per second, with the burst of AcceptBurst.
+	MaxConns      int
+	MaxConnsPerIP int
+	AcceptRate    float64
+	AcceptBurst   int
 
 	// tls
 	CA           string
@@ -72,6 +97,9 @@ type serverConfig struct {
 	SampleType     string
 	SampleAddress  string
 	SampleEncoding string
+
+	// HealthAddress is the address of the http health endpoints /healthz and /readyz, empty to disable them.
+	HealthAddress string
 }
 
 type clientConfig struct {
@@ -92,7 +120,13 @@ type clientConfig struct {
 	ClientDialTimeout  time.Duration
 	ReqDefaultTimeout  int32
 	ObjQueueMax        int32
-	context            map[string]string
+	// Zone is the zone of the client, the endpoints in the same zone are preferred if it is set.
+	Zone string
+	// ZoneMinHealthyPercent is the min percent of healthy endpoints in the zone, otherwise all the zones are used.
+	ZoneMinHealthyPercent int
+	// Connections is the number of connections to each endpoint.
+	Connections int
+	context     map[string]string
 }
 
 // GetServerConfig Get server config
@@ -162,6 +196,14 @@ func newServerConfig() *serverConfig {
 		StatReportChannelBufLen: StatReportChannelBufLen,
 		MaxPackageLength:        MaxPackageLength,
 		GracedownTimeout:        tools.ParseTimeOut(GracedownTimeout),
+		NotReadyTimeout:         tools.ParseTimeOut(NotReadyTimeout),
+		DeregisterTimeout:       tools.ParseTimeOut(DeregisterTimeout),
+		PropagationDelay:        tools.ParseTimeOut(PropagationDelay),
+		StopAcceptTimeout:       tools.ParseTimeOut(StopAcceptTimeout),
+		DrainTimeout:            tools.ParseTimeOut(DrainTimeout),
+		DestroyTimeout:          tools.ParseTimeOut(DestroyTimeout),
+		CompressThreshold:       CompressThreshold,
+		PriorityMaxSkip:         PriorityMaxSkip,
 	}
 }
 
@@ -182,6 +224,8 @@ func newClientConfig() *clientConfig {
 		ClientDialTimeout:       tools.ParseTimeOut(ClientDialTimeout),
 		ReqDefaultTimeout:       ReqDefaultTimeout,
 		ObjQueueMax:             ObjQueueMax,
+		ZoneMinHealthyPercent:   ZoneMinHealthyPercent,
+		Connections:             ClientConnections,
 		context:                 make(map[string]string),
 	}
 	return conf
@@ -14,12 +14,15 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/TarsCloud/TarsGo/tars/circuitbreaker"
 	"github.com/TarsCloud/TarsGo/tars/protocol/res/endpointf"
 	"github.com/TarsCloud/TarsGo/tars/protocol/res/queryf"
 	"github.com/TarsCloud/TarsGo/tars/registry"
 	tarsregistry "github.com/TarsCloud/TarsGo/tars/registry/tars"
+	"github.com/TarsCloud/TarsGo/tars/selector"
 	"github.com/TarsCloud/TarsGo/tars/selector/consistenthash"
 	"github.com/TarsCloud/TarsGo/tars/selector/modhash"
+	"github.com/TarsCloud/TarsGo/tars/selector/p2c"
 	"github.com/TarsCloud/TarsGo/tars/selector/roundrobin"
 	"github.com/TarsCloud/TarsGo/tars/util/endpoint"
 	"github.com/TarsCloud/TarsGo/tars/util/gtime"
@@ -32,6 +35,9 @@ type EndpointManager interface {
 	preInvoke()
 	postInvoke()
 	addAliveEp(ep endpoint.Endpoint)
+	retryPolicy() (*RetryPolicy, *retryBudget)
+	hedgePolicy() (*HedgePolicy, *costStats)
+	recordCost(cost int64)
 }
 
 var (
@@ -177,13 +183,25 @@ type endpointManager struct {
 	checkAdapterList *sync.Map
 	checkAdapter     chan *AdapterProxy
 
-	weightType         endpoint.WeightType
-	activeEpRoundRobin *roundrobin.RoundRobin
-	activeEpConHash    *consistenthash.ConsistentHash
-	activeEpModHash    *modhash.ModHash
-	freshLock          *sync.Mutex
-	lastInvoke         int64
-	invokeNum          int32
+	weightType       endpoint.WeightType
+	zone             string
+	selectorName     string
+	selectorFactory  selector.Factory
+	activeEpSelector selector.Selector // round-robin by default
+	activeEpConHash  *consistenthash.ConsistentHash
+	activeEpModHash  *modhash.ModHash
+	freshLock        *sync.Mutex
+	lastInvoke       int64
+	invokeNum        int32
+
+	retry       *RetryPolicy
+	retryBudget *retryBudget
+	hedge       *HedgePolicy
+	costStats   *costStats
+	probe       *ProbePolicy
+	probing     *sync.Map
+
+	breakerFactory circuitbreaker.Factory
 }
 
 type EndpointManagerOption interface {
@@ -223,6 +241,80 @@ func WithSet(setDivision string) OptionFunc {
 	})
 }
 
+// WithRetryPolicy sets the retry policy of the servant proxy, which overrides the policy in client config.
+func WithRetryPolicy(p *RetryPolicy) OptionFunc {
+	return newOptionFunc(func(e *endpointManager) {
+		e.retry = p
+	}, func(s *string) {
+		if p != nil {
+			*s = *s + ":" + p.key()
+		}
+	})
+}
+
+// WithHedgePolicy sets the hedged request policy of the servant proxy, which overrides the policy in client config.
+func WithHedgePolicy(p *HedgePolicy) OptionFunc {
+	return newOptionFunc(func(e *endpointManager) {
+		e.hedge = p
+	}, func(s *string) {
+		if p != nil {
+			*s = *s + ":" + p.key()
+		}
+	})
+}
+
+// WithProbePolicy sets the active probing policy of the blocked endpoints, which overrides the policy in client config.
+func WithProbePolicy(p *ProbePolicy) OptionFunc {
+	return newOptionFunc(func(e *endpointManager) {
+		e.probe = p
+	}, func(s *string) {
+		if p != nil {
+			*s = *s + ":" + p.key()
+		}
+	})
+}
+
+// WithCircuitBreaker sets the circuit breaker factory of the servant proxy, which overrides the breaker in client config.
+// The key distinguishes the endpoint manager from the ones with other breakers.
+func WithCircuitBreaker(key string, f circuitbreaker.Factory) OptionFunc {
+	return newOptionFunc(func(e *endpointManager) {
+		e.breakerFactory = f
+	}, func(s *string) {
+		*s = *s + ":breaker:" + key
+	})
+}
+
+// WithSelector sets the name of the selector which chooses the endpoint for the requests without hash,
+// the name can be "p2c" or the one registered by selector.Register. It overrides the selector in client config.
+func WithSelector(name string) OptionFunc {
+	return newOptionFunc(func(e *endpointManager) {
+		e.selectorName = name
+	}, func(s *string) {
+		*s = *s + ":selector:" + name
+	})
+}
+
+// WithSelectorFactory sets the selector factory of the servant proxy, which overrides WithSelector and client config.
+// The key distinguishes the endpoint manager from the ones with other selectors.
+func WithSelectorFactory(key string, f selector.Factory) OptionFunc {
+	return newOptionFunc(func(e *endpointManager) {
+		e.selectorFactory = f
+	}, func(s *string) {
+		*s = *s + ":selector:" + key
+	})
+}
+
+// WithZone sets the zone of the client, which overrides the zone in client config.
+// The endpoints in the same zone are preferred, and other zones are used when the healthy endpoints
+// in the zone are less than ZoneMinHealthyPercent.
+func WithZone(zone string) OptionFunc {
+	return newOptionFunc(func(e *endpointManager) {
+		e.zone = zone
+	}, func(s *string) {
+		*s = *s + ":zone:" + zone
+	})
+}
+
 func newEndpointManager(objName string, comm *Communicator, opts ...EndpointManagerOption) *endpointManager {
 	if objName == "" {
 		return nil
@@ -233,14 +325,44 @@ func newEndpointManager(objName string, comm *Communicator, opts ...EndpointMana
 	e.epList = &sync.Map{}
 	e.epLock = &sync.Mutex{}
 	e.checkAdapterList = &sync.Map{}
+	e.probing = &sync.Map{}
 	e.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
 	for _, opt := range opts {
 		opt.apply(e)
 	}
+	if e.zone == "" {
+		e.zone = comm.Client.Zone
+	}
 	pos := strings.Index(objName, "@")
 	if pos > 0 {
-		// [direct]
 		e.objName = objName[0:pos]
+	} else {
+		e.objName = objName
+	}
+	if e.retry == nil {
+		e.retry = comm.app.clientObjRetryPolicy[e.objName]
+	}
+	if e.retry.enabled() {
+		e.retryBudget = newRetryBudget(e.retry.BudgetRatio, e.retry.BudgetMinPerSecond)
+	}
+	if e.selectorName == "" {
+		e.selectorName = comm.app.clientObjSelector[e.objName]
+	}
+	if e.breakerFactory == nil {
+		e.breakerFactory = comm.app.clientObjBreaker[e.objName]
+	}
+	if e.hedge == nil {
+		e.hedge = comm.app.clientObjHedgePolicy[e.objName]
+	}
+	if e.hedge.enabled() {
+		e.costStats = newCostStats()
+	}
+	if e.probe == nil {
+		e.probe = comm.app.clientObjProbePolicy[e.objName]
+	}
+	// the direct endpoints are added after the config above, which the selectors depend on
+	if pos > 0 {
+		// [direct]
 		endpoints := objName[pos+1:]
 		e.directProxy = true
 		ends := strings.Split(endpoints, ":")
@@ -252,7 +374,6 @@ func newEndpointManager(objName string, comm *Communicator, opts ...EndpointMana
 	} else {
 		// [proxy] TODO singleton
 		TLOG.Debug("proxy mode:", objName)
-		e.objName = objName
 		e.directProxy = false
 		if e.comm.opt.registrar == nil {
 			obj, _ := e.comm.GetProperty("locator")
@@ -301,11 +422,19 @@ func (e *endpointManager) checkStatus() {
 						break
 					}
 				}
-				e.epLock.Unlock()
-
-				e.activeEpRoundRobin.Remove(ep)
-				e.activeEpConHash.Remove(ep)
-				e.activeEpModHash.Remove(ep)
+				if e.zone != "" {
+					// the local zone may become unhealthy, route again
+					e.refreshSelectors(e.activeEp)
+					e.epLock.Unlock()
+				} else {
+					e.epLock.Unlock()
+					e.activeEpSelector.Remove(ep)
+					e.activeEpConHash.Remove(ep)
+					e.activeEpModHash.Remove(ep)
+				}
+				if e.probe.enabled() {
+					e.startProbe(ep, adp)
+				}
 			}
 
 			if needCheck {
@@ -322,15 +451,26 @@ func (e *endpointManager) checkStatus() {
 
 func (e *endpointManager) addAliveEp(ep endpoint.Endpoint) {
 	e.epLock.Lock()
+	for i := range e.activeEp {
+		if e.activeEp[i] == ep {
+			// added by the refresh of the endpoints
+			e.epLock.Unlock()
+			return
+		}
+	}
 	sortedEps := e.activeEp[:]
 	sortedEps = append(sortedEps, ep)
 	sort.Slice(sortedEps, func(i int, j int) bool {
 		return crc32.ChecksumIEEE([]byte(sortedEps[i].Key)) < crc32.ChecksumIEEE([]byte(sortedEps[j].Key))
 	})
 	e.activeEp = sortedEps
-	e.activeEpRoundRobin.Add(ep)
-	e.activeEpConHash.Add(ep)
-	e.activeEpModHash.Add(ep)
+	if e.zone != "" {
+		e.refreshSelectors(sortedEps)
+	} else {
+		e.activeEpSelector.Add(ep)
+		e.activeEpConHash.Add(ep)
+		e.activeEpModHash.Add(ep)
+	}
 	e.epLock.Unlock()
 }
 
@@ -346,51 +486,60 @@ func (e *endpointManager) SelectAdapterProxy(msg *Message) (*AdapterProxy, bool)
 	if !e.directProxy && len(e.activeEpf) == 0 {
 		return nil, false
 	}
-	select {
-	case adp := <-e.checkAdapter:
-		TLOG.Errorf("SelectAdapterProxy|check adapter, ep: %+v", adp.GetPoint())
-		e.checkAdapterList.Delete(endpoint.Tars2endpoint(*adp.GetPoint()).Key)
-		return adp, true
-	default:
-	}
-	var (
-		adp *AdapterProxy
-		ep  endpoint.Endpoint
-		err error
-	)
-	if msg.isHash && msg.hashType == ConsistentHash {
-		ep, err = e.activeEpConHash.Select(msg) // ConsistentHash
-	} else if msg.isHash && msg.hashType == ModHash {
-		ep, err = e.activeEpModHash.Select(msg) // ModHash
-	} else {
-		ep, err = e.activeEpRoundRobin.Select(msg) // RoundRobin
-	}
-	if err != nil {
-		TLOG.Errorf("SelectAdapterProxy|enableWeight: %v, isHash: %b, hashType: %s, hashCode: %d, err: %v", e.enableWeight(), msg.isHash, msg.hashType, msg.hashCode, err)
-		goto random
+	if len(msg.triedAdps) == 0 {
+		select {
+		case adp := <-e.checkAdapter:
+			TLOG.Errorf("SelectAdapterProxy|check adapter, ep: %+v", adp.GetPoint())
+			e.checkAdapterList.Delete(endpoint.Tars2endpoint(*adp.GetPoint()).Key)
+			return adp, true
+		default:
+		}
 	}
-	if v, ok := e.epList.Load(ep.Key); ok {
-		adp = v.(*AdapterProxy)
-	} else {
-		epf := endpoint.Endpoint2tars(ep)
-		adp = NewAdapterProxy(e.objName, &epf, e.comm)
-		e.epList.Store(ep.Key, adp)
+	var adp *AdapterProxy
+	// retry attempts prefer the adapter proxies which have not been tried
+	for i := 0; ; i++ {
+		ep, err := e.selectEndpoint(msg)
+		if err != nil {
+			TLOG.Errorf("SelectAdapterProxy|enableWeight: %v, isHash: %b, hashType: %s, hashCode: %d, err: %v", e.enableWeight(), msg.isHash, msg.hashType, msg.hashCode, err)
+			adp = nil
+			break
+		}
+		adp = e.loadAdapterProxy(endpoint.Endpoint2tars(ep), ep.Key)
+		if !msg.isTried(adp) || i >= len(eps) {
+			break
+		}
 	}
-random:
 	if adp == nil && !e.directProxy {
 		// not any node is alive, just select a random one.
 		randomEpf := e.activeEpf[e.rand.Intn(len(e.activeEpf))]
-		randomEp := endpoint.Tars2endpoint(randomEpf)
-		if v, ok := e.epList.Load(randomEp.Key); ok {
-			adp = v.(*AdapterProxy)
-		} else {
-			adp = NewAdapterProxy(e.objName, &randomEpf, e.comm)
-			e.epList.Store(randomEp.Key, adp)
-		}
+		adp = e.loadAdapterProxy(randomEpf, endpoint.Tars2endpoint(randomEpf).Key)
 	}
 	return adp, false
 }
 
+func (e *endpointManager) selectEndpoint(msg *Message) (endpoint.Endpoint, error) {
+	if msg.isHash && msg.hashType == ConsistentHash {
+		return e.activeEpConHash.Select(msg) // ConsistentHash
+	} else if msg.isHash && msg.hashType == ModHash {
+		return e.activeEpModHash.Select(msg) // ModHash
+	}
+	return e.activeEpSelector.Select(msg) // RoundRobin, P2C or the custom selector
+}
+
+// loadAdapterProxy returns the adapter proxy of the endpoint, and creates it if not exists.
+func (e *endpointManager) loadAdapterProxy(epf endpointf.EndpointF, key string) *AdapterProxy {
+	if v, ok := e.epList.Load(key); ok {
+		return v.(*AdapterProxy)
+	}
+	adp := NewAdapterProxy(e.objName, &epf, e.comm)
+	if e.breakerFactory != nil {
+		adp.breaker = e.breakerFactory()
+	}
+	adp.probing = e.probe.enabled()
+	e.epList.Store(key, adp)
+	return adp
+}
+
 func (e *endpointManager) doFresh() error {
 	if e.directProxy {
 		return nil
@@ -409,6 +558,20 @@ func (e *endpointManager) postInvoke() {
 	atomic.AddInt32(&e.invokeNum, -1)
 }
 
+func (e *endpointManager) retryPolicy() (*RetryPolicy, *retryBudget) {
+	return e.retry, e.retryBudget
+}
+
+func (e *endpointManager) hedgePolicy() (*HedgePolicy, *costStats) {
+	return e.hedge, e.costStats
+}
+
+func (e *endpointManager) recordCost(cost int64) {
+	if e.costStats != nil {
+		e.costStats.record(cost)
+	}
+}
+
 func (e *endpointManager) refreshEndpoints() error {
 	var (
 		activeEp, inactiveEp []endpointf.EndpointF
@@ -495,7 +658,7 @@ func (e *endpointManager) updateActiveEp(newEps []endpoint.Endpoint) {
 	for _, ep := range newEps {
 		if v, ok := e.epList.Load(ep.Key); ok {
 			adp := v.(*AdapterProxy)
-			if adp.status {
+			if adp.isActive() {
 				sortedEps = append(sortedEps, ep)
 			}
 		} else {
@@ -517,23 +680,84 @@ func (e *endpointManager) updateActiveEp(newEps []endpoint.Endpoint) {
 	sort.Slice(sortedEps, func(i int, j int) bool {
 		return crc32.ChecksumIEEE([]byte(sortedEps[i].Key)) < crc32.ChecksumIEEE([]byte(sortedEps[j].Key))
 	})
-	roundRobinSelector := roundrobin.New(e.enableWeight())
-	roundRobinSelector.Refresh(sortedEps)
-	conHashSelector := consistenthash.New(e.enableWeight(), consistenthash.KetamaHash)
-	conHashSelector.Refresh(sortedEps)
-	modHashSelector := modhash.New(e.enableWeight())
-	modHashSelector.Refresh(sortedEps)
 
 	e.epLock.Lock()
 	e.activeEp = sortedEps
-	e.activeEpRoundRobin = roundRobinSelector
-	e.activeEpConHash = conHashSelector
-	e.activeEpModHash = modHashSelector
+	e.refreshSelectors(sortedEps)
 	e.epLock.Unlock()
 
 	TLOG.Debugf("updateActiveEp|activeEp: %+v", sortedEps)
 }
 
+// refreshSelectors rebuilds the selectors with the healthy endpoints, epLock must be held.
+func (e *endpointManager) refreshSelectors(eps []endpoint.Endpoint) {
+	routeEps := e.zoneEndpoints(eps)
+	epSelector := e.newSelector()
+	epSelector.Refresh(routeEps)
+	conHashSelector := consistenthash.New(e.enableWeight(), consistenthash.KetamaHash)
+	conHashSelector.Refresh(routeEps)
+	modHashSelector := modhash.New(e.enableWeight())
+	modHashSelector.Refresh(routeEps)
+
+	e.activeEpSelector = epSelector
+	e.activeEpConHash = conHashSelector
+	e.activeEpModHash = modHashSelector
+}
+
+// zoneEndpoints returns the healthy endpoints in the local zone if there are enough of them,
+// otherwise returns all the healthy endpoints.
+func (e *endpointManager) zoneEndpoints(eps []endpoint.Endpoint) []endpoint.Endpoint {
+	if e.zone == "" {
+		return eps
+	}
+	var total int
+	for _, ef := range e.activeEpf {
+		if endpoint.Tars2endpoint(ef).Zone() == e.zone {
+			total++
+		}
+	}
+	local := make([]endpoint.Endpoint, 0, len(eps))
+	for _, ep := range eps {
+		if ep.Zone() == e.zone {
+			local = append(local, ep)
+		}
+	}
+	if total < len(local) {
+		// direct proxy has no activeEpf
+		total = len(local)
+	}
+	if len(local) == 0 || len(local)*100 < total*e.comm.Client.ZoneMinHealthyPercent {
+		TLOG.Debugf("zoneEndpoints|obj: %s, zone: %s, healthy: %d, total: %d, use all zones", e.objName, e.zone, len(local), total)
+		return eps
+	}
+	return local
+}
+
+// newSelector creates the selector for the requests without hash.
+func (e *endpointManager) newSelector() selector.Selector {
+	if e.selectorFactory != nil {
+		return e.selectorFactory(e.enableWeight())
+	}
+	if e.selectorName == selectorP2C || (e.selectorName == "" && e.weightType == endpoint.EDynamicWeight) {
+		return p2c.New(e.endpointLoad)
+	}
+	if e.selectorName != "" {
+		if f, ok := selector.Get(e.selectorName); ok {
+			return f(e.enableWeight())
+		}
+		TLOG.Errorf("selector %s of %s is not registered, use round-robin", e.selectorName, e.objName)
+	}
+	return roundrobin.New(e.enableWeight())
+}
+
+// endpointLoad returns the load of the endpoint for p2c selector.
+func (e *endpointManager) endpointLoad(ep endpoint.Endpoint) (int64, time.Duration) {
+	if v, ok := e.epList.Load(ep.Key); ok {
+		return v.(*AdapterProxy).load()
+	}
+	return 0, 0
+}
+
 func (e *endpointManager) enableWeight() bool {
 	return e.weightType == endpoint.EStaticWeight
 }
@@ -0,0 +1,138 @@
+package tars
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/endpointf"
+	"github.com/TarsCloud/TarsGo/tars/selector"
+	"github.com/TarsCloud/TarsGo/tars/selector/p2c"
+	"github.com/TarsCloud/TarsGo/tars/selector/roundrobin"
+	"github.com/TarsCloud/TarsGo/tars/util/endpoint"
+)
+
+// firstSelector always selects the first endpoint.
+type firstSelector struct {
+	nodes []endpoint.Endpoint
+}
+
+func (s *firstSelector) Select(msg selector.Message) (endpoint.Endpoint, error) {
+	return s.nodes[0], nil
+}
+
+func (s *firstSelector) Refresh(nodes []endpoint.Endpoint) {
+	s.nodes = nodes
+}
+
+func (s *firstSelector) Add(node endpoint.Endpoint) error {
+	return nil
+}
+
+func (s *firstSelector) Remove(node endpoint.Endpoint) error {
+	return nil
+}
+
+func TestEndpointManager_newSelector(t *testing.T) {
+	selector.Register("test-first", func(enableWeight bool) selector.Selector {
+		return &firstSelector{}
+	})
+	const obj = "App.Server.Obj"
+	const direct = obj + "@tcp -h 127.0.0.1 -p 10001:tcp -h 127.0.0.1 -p 10002"
+	tests := []struct {
+		name   string
+		config string
+		opts   []EndpointManagerOption
+		want   string
+	}{
+		{name: "default", want: "*roundrobin.RoundRobin"},
+		{name: "config", config: "test-first", want: "*tars.firstSelector"},
+		{name: "option", opts: []EndpointManagerOption{WithSelector("test-first")}, want: "*tars.firstSelector"},
+		{name: "option overrides config", config: "test-first", opts: []EndpointManagerOption{WithSelector(selectorP2C)}, want: "*p2c.P2C"},
+		{name: "factory overrides name", opts: []EndpointManagerOption{WithSelector(selectorP2C), WithSelectorFactory("first", func(bool) selector.Selector {
+			return &firstSelector{}
+		})}, want: "*tars.firstSelector"},
+		{name: "unknown falls back", opts: []EndpointManagerOption{WithSelector("unknown")}, want: "*roundrobin.RoundRobin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newApp()
+			if tt.config != "" {
+				app.clientObjSelector[obj] = tt.config
+			}
+			e := newEndpointManager(direct, newCommunicator(app, app.cltCfg), tt.opts...)
+			var got string
+			switch s := e.activeEpSelector.(type) {
+			case *roundrobin.RoundRobin:
+				got = "*roundrobin.RoundRobin"
+			case *p2c.P2C:
+				got = "*p2c.P2C"
+			case *firstSelector:
+				got = "*tars.firstSelector"
+				if len(s.nodes) != 2 {
+					t.Errorf("selector refreshed with %d endpoints, want 2", len(s.nodes))
+				}
+			}
+			if got != tt.want {
+				t.Errorf("selector = %T, want %s", e.activeEpSelector, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndpointManager_zoneEndpoints(t *testing.T) {
+	newEps := func(sets ...string) []endpoint.Endpoint {
+		eps := make([]endpoint.Endpoint, len(sets))
+		for i, set := range sets {
+			eps[i] = endpoint.Parse("tcp -h 127.0.0.1 -p " + strconv.Itoa(10001+i))
+			eps[i].SetId = set
+		}
+		return eps
+	}
+	// four endpoints in the zone sz registered, two in the zone sh
+	registered := newEps("app.sz.1", "app.sz.1", "app.sz.2", "app.sz.2", "app.sh.1", "app.sh.1")
+	tests := []struct {
+		name      string
+		zone      string
+		healthy   []endpoint.Endpoint
+		wantLocal bool
+	}{
+		{name: "healthy zone", zone: "sz", healthy: append(registered[1:4:4], registered[4:]...), wantLocal: true},
+		{name: "zone below the threshold", zone: "sz", healthy: registered[3:], wantLocal: false},
+		{name: "no endpoint in the zone", zone: "gz", healthy: registered, wantLocal: false},
+		{name: "no zone", zone: "", healthy: registered[3:], wantLocal: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &endpointManager{
+				objName: "App.Server.Obj",
+				zone:    tt.zone,
+				comm:    &Communicator{Client: &clientConfig{ZoneMinHealthyPercent: 50}},
+			}
+			e.activeEpf = make([]endpointf.EndpointF, len(registered))
+			for i, ep := range registered {
+				e.activeEpf[i] = endpoint.Endpoint2tars(ep)
+			}
+			got := e.zoneEndpoints(tt.healthy)
+			if !tt.wantLocal {
+				if len(got) != len(tt.healthy) {
+					t.Errorf("zoneEndpoints() returns %d endpoints, want all the %d healthy ones", len(got), len(tt.healthy))
+				}
+				return
+			}
+			for _, ep := range got {
+				if ep.Zone() != tt.zone {
+					t.Errorf("zoneEndpoints() returns %s in zone %s, want zone %s", ep.Key, ep.Zone(), tt.zone)
+				}
+			}
+			if len(got) != 3 {
+				t.Errorf("zoneEndpoints() returns %d endpoints, want the 3 in the zone", len(got))
+			}
+		})
+	}
+
+	// direct proxy counts the healthy endpoints only
+	e := &endpointManager{zone: "sz", comm: &Communicator{Client: &clientConfig{ZoneMinHealthyPercent: 50}}}
+	if got := e.zoneEndpoints(registered[3:]); len(got) != 1 {
+		t.Errorf("zoneEndpoints() of direct proxy returns %d endpoints, want 1", len(got))
+	}
+}
@@ -0,0 +1,126 @@
+package tars
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+	"github.com/TarsCloud/TarsGo/tars/util/conf"
+	"github.com/TarsCloud/TarsGo/tars/util/current"
+)
+
+// parseFuncTimeouts parses the handler timeouts of the functions in milliseconds from the domain,
+// in the form of <FuncName=100>.
+func parseFuncTimeouts(c *conf.Conf, path string) map[string]time.Duration {
+	var timeouts map[string]time.Duration
+	for funcName, v := range c.GetMap(path) {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms <= 0 {
+			TLOG.Errorf("invalid handler timeout of %s in %s: %s", funcName, path, v)
+			continue
+		}
+		if timeouts == nil {
+			timeouts = make(map[string]time.Duration)
+		}
+		timeouts[funcName] = time.Duration(ms) * time.Millisecond
+	}
+	return timeouts
+}
+
+// funcTimeout returns the handler timeout of the function, zero for no handler timeout.
+func (s *Protocol) funcTimeout(funcName string) time.Duration {
+	if timeout, ok := s.funcTimeouts[funcName]; ok {
+		return timeout
+	}
+	return s.handleTimeout
+}
+
+// dispatchWithTimeout dispatches the request and replies the timeout if the handler does not return in time.
+// The handler keeps running after the timeout with its own copies of the request, the response and
+// the tars current, and it should return when ctx is done.
+func (s *Protocol) dispatchWithTimeout(ctx context.Context, timeout time.Duration, req *requestf.RequestPacket, rsp *requestf.ResponsePacket) {
+	hctx, cancel := context.WithTimeout(current.DetachTarsCurrent(ctx), timeout)
+	defer cancel()
+	hreq, hrsp := detachRequest(req), *rsp
+	done := make(chan struct{})
+	go func() {
+		defer CheckPanic()
+		defer close(done)
+		s.dispatch(hctx, hreq, &hrsp)
+	}()
+	select {
+	case <-done:
+		*rsp = hrsp
+		return
+	case <-hctx.Done():
+	}
+
+	rsp.IRet = basef.TARSINVOKETIMEOUT
+	ip, _ := current.GetClientIPFromContext(ctx)
+	port, _ := current.GetClientPortFromContext(ctx)
+	switch ctx.Err() {
+	case context.Canceled:
+		rsp.SResultDesc = "request cancelled"
+		TLOG.Debugf("request cancelled while handling, obj:%s, func:%s, addr:(%s:%s), reqId:%d",
+			req.SServantName, req.SFuncName, ip, port, req.IRequestId)
+	case context.DeadlineExceeded:
+		// the timeout of the request is earlier than the handler timeout
+		rsp.SResultDesc = "server invoke timeout"
+		TLOG.Errorf("invoke timeout while handling, obj:%s, func:%s, timeout:%d, addr:(%s:%s), reqId:%d",
+			req.SServantName, req.SFuncName, req.ITimeout, ip, port, req.IRequestId)
+	default:
+		rsp.SResultDesc = "server handle timeout"
+		TLOG.Errorf("handle timeout, obj:%s, func:%s, timeout:%v, addr:(%s:%s), reqId:%d",
+			req.SServantName, req.SFuncName, timeout, ip, port, req.IRequestId)
+	}
+}
+
+// detachRequest copies the request for the handler which may keep running after the response is sent.
+func detachRequest(req *requestf.RequestPacket) *requestf.RequestPacket {
+	r := *req
+	r.Status = copyStatus(req.Status)
+	r.Context = copyStatus(req.Context)
+	return &r
+}
+
+func copyStatus(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+type handleCostReport struct {
+	queueWait *PropertyReport
+	exec      *PropertyReport
+}
+
+// reportHandleCost reports the time waiting in the queue until a worker picks the request up,
+// and the time executing it, in milliseconds. They are reported to the stat as the interfaces "<func>_queue_wait"
+// and "<func>_exec" of the masters "queue_from_server" and "exec_from_server", apart from the stat of the call,
+// and to the property as "<obj>.<func>_queue_wait" and "<obj>.<func>_exec".
+func (s *Protocol) reportHandleCost(req *requestf.RequestPacket, ret int32, queueWait, exec int64) {
+	ReportStatFromServer(req.SFuncName+"_queue_wait", "queue_from_server", ret, queueWait)
+	ReportStatFromServer(req.SFuncName+"_exec", "exec_from_server", ret, exec)
+	// the property reports need the property server
+	if s.app.ClientConfig().ValidateProperty() != nil {
+		return
+	}
+	key := req.SServantName + "." + req.SFuncName
+	v, ok := s.costReports.Load(key)
+	if !ok {
+		v, _ = s.costReports.LoadOrStore(key, &handleCostReport{
+			queueWait: CreatePropertyReport(key+"_queue_wait", NewAvg(), NewMax()),
+			exec:      CreatePropertyReport(key+"_exec", NewAvg(), NewMax()),
+		})
+	}
+	r := v.(*handleCostReport)
+	r.queueWait.Report(int(queueWait))
+	r.exec.Report(int(exec))
+}
@@ -0,0 +1,108 @@
+package tars
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+	"github.com/TarsCloud/TarsGo/tars/util/conf"
+)
+
+type sleepDispatcher struct{}
+
+func (d *sleepDispatcher) Dispatch(ctx context.Context, imp interface{}, req *requestf.RequestPacket, rsp *requestf.ResponsePacket, withContext bool) error {
+	select {
+	case <-time.After(100 * time.Millisecond):
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+func TestParseFuncTimeouts(t *testing.T) {
+	c := conf.New()
+	err := c.InitFromString(`<tars><application><server><App.Server.ObjAdapter><functimeout>
+Slow=200
+Bad=x
+</functimeout></App.Server.ObjAdapter></server></application></tars>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeouts := parseFuncTimeouts(c, "/tars/application/server/App.Server.ObjAdapter/functimeout")
+	if len(timeouts) != 1 || timeouts["Slow"] != 200*time.Millisecond {
+		t.Errorf("timeouts = %v, want Slow 200ms", timeouts)
+	}
+}
+
+func TestHandleTimeout(t *testing.T) {
+	server := NewTarsProtocol(&sleepDispatcher{}, nil, false)
+	server.app = newApp()
+	server.handleTimeout = time.Second
+	server.funcTimeouts = map[string]time.Duration{"Slow": 20 * time.Millisecond}
+	proto := &protocol.TarsProtocol{}
+
+	invoke := func(ctx context.Context, funcName string, timeout int32) (*requestf.ResponsePacket, time.Duration) {
+		pkg, err := proto.RequestPack(&requestf.RequestPacket{
+			IVersion:     basef.TARSVERSION,
+			IRequestId:   1,
+			SServantName: "App.Server.Obj",
+			SFuncName:    funcName,
+			ITimeout:     timeout,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		rsp, err := proto.ResponseUnpack(server.Invoke(ctx, pkg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rsp, time.Since(start)
+	}
+	ctx := context.Background()
+	if rsp, cost := invoke(ctx, "Slow", 0); rsp.IRet != basef.TARSINVOKETIMEOUT || rsp.SResultDesc != "server handle timeout" || cost > 80*time.Millisecond {
+		t.Errorf("Slow returns %d %q in %v, want the handle timeout", rsp.IRet, rsp.SResultDesc, cost)
+	}
+	if rsp, cost := invoke(ctx, "Normal", 0); rsp.IRet != 0 || cost < 100*time.Millisecond {
+		t.Errorf("Normal returns %d in %v, want success", rsp.IRet, cost)
+	}
+	if rsp, _ := invoke(ctx, "Normal", 20); rsp.IRet != basef.TARSINVOKETIMEOUT || rsp.SResultDesc != "server invoke timeout" {
+		t.Errorf("Normal with request timeout returns %d %q, want the invoke timeout", rsp.IRet, rsp.SResultDesc)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if rsp, _ := invoke(ctx, "Normal", 0); rsp.SResultDesc != "request cancelled" {
+		t.Errorf("Normal cancelled returns %d %q, want cancelled", rsp.IRet, rsp.SResultDesc)
+	}
+}
+
+func TestReportHandleCost(t *testing.T) {
+	app := newApp()
+	old := StatReport
+	StatReport = newStatFHelper(app)
+	defer func() { StatReport = old }()
+	server := NewTarsProtocol(&echoDispatcher{}, nil, false)
+	server.app = app
+
+	server.reportHandleCost(&requestf.RequestPacket{SServantName: "App.Server.Obj", SFuncName: "Echo"}, 0, 3, 5)
+	want := map[string]struct {
+		iface string
+		cost  int64
+	}{
+		"queue_from_server": {"Echo_queue_wait", 3},
+		"exec_from_server":  {"Echo_exec", 5},
+	}
+	for i := 0; i < len(want); i++ {
+		select {
+		case info := <-StatReport.chStatInfoFromServer:
+			w, ok := want[info.Head.MasterName]
+			if !ok || info.Head.InterfaceName != w.iface || info.Body.TotalRspTime != w.cost {
+				t.Errorf("unexpected stat %s.%s: %d", info.Head.MasterName, info.Head.InterfaceName, info.Body.TotalRspTime)
+			}
+		default:
+			t.Fatal("handle cost is not reported to the stat")
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package tars
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+)
+
+// HealthStatus is the serving status of a servant.
+type HealthStatus int32
+
+// HealthStatus enum
+const (
+	HealthUnknown HealthStatus = iota
+	HealthServing
+	HealthNotServing
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case HealthServing:
+		return "SERVING"
+	case HealthNotServing:
+		return "NOT_SERVING"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ParseHealthStatus parses the status returned by String.
+func ParseHealthStatus(s string) HealthStatus {
+	switch s {
+	case "SERVING":
+		return HealthServing
+	case "NOT_SERVING":
+		return HealthNotServing
+	default:
+		return HealthUnknown
+	}
+}
+
+const (
+	// StatusHealthKey is the status key of tars_ping to ask for the serving status of the servant,
+	// and the server replies the status in the response status with the same key.
+	// The servers which do not know it reply tars_ping without the key.
+	StatusHealthKey = "STATUS_HEALTH_KEY"
+	// AllServants means the health check is for all the servants.
+	AllServants = "*"
+)
+
+// HealthCheck checks a dependency of the servants, such as the database connectivity.
+type HealthCheck func(ctx context.Context) error
+
+type namedHealthCheck struct {
+	name  string
+	check HealthCheck
+}
+
+type healthRegistry struct {
+	lock    sync.RWMutex
+	checks  map[string][]namedHealthCheck
+	serving map[string]HealthStatus
+}
+
+func newHealthRegistry() *healthRegistry {
+	return &healthRegistry{
+		checks:  make(map[string][]namedHealthCheck),
+		serving: make(map[string]HealthStatus),
+	}
+}
+
+// RegisterHealthCheck registers the named check of the servant, AllServants for all the servants.
+// The servant is not serving if any of its checks fails.
+func RegisterHealthCheck(servant, name string, check HealthCheck) {
+	defaultApp.RegisterHealthCheck(servant, name, check)
+}
+
+// RegisterHealthCheck registers the named check of the servant, AllServants for all the servants.
+func (a *application) RegisterHealthCheck(servant, name string, check HealthCheck) {
+	h := a.health
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.checks[servant] = append(h.checks[servant], namedHealthCheck{name: name, check: check})
+}
+
+// SetServingStatus sets the serving status of the servant, AllServants for all the servants.
+// HealthNotServing marks the servant not serving regardless of the checks, others clear the setting.
+func SetServingStatus(servant string, status HealthStatus) {
+	defaultApp.SetServingStatus(servant, status)
+}
+
+// SetServingStatus sets the serving status of the servant, AllServants for all the servants.
+func (a *application) SetServingStatus(servant string, status HealthStatus) {
+	h := a.health
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if status == HealthNotServing {
+		h.serving[servant] = status
+	} else {
+		delete(h.serving, servant)
+	}
+}
+
+// CheckHealth returns the serving status of the servant, and the reasons if it is not serving.
+func CheckHealth(ctx context.Context, servant string) (HealthStatus, []string) {
+	return defaultApp.CheckHealth(ctx, servant)
+}
+
+// CheckHealth returns the serving status of the servant, and the reasons if it is not serving.
+func (a *application) CheckHealth(ctx context.Context, servant string) (HealthStatus, []string) {
+	if !a.IsReady() {
+		return HealthNotServing, []string{"server is not ready"}
+	}
+	h := a.health
+	h.lock.RLock()
+	_, notServing := h.serving[servant]
+	_, allNotServing := h.serving[AllServants]
+	checks := append([]namedHealthCheck(nil), h.checks[AllServants]...)
+	if servant != AllServants {
+		checks = append(checks, h.checks[servant]...)
+	}
+	h.lock.RUnlock()
+	if notServing || allNotServing {
+		return HealthNotServing, []string{"set not serving"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	var reasons []string
+	for _, c := range checks {
+		if err := c.check(ctx); err != nil {
+			reasons = append(reasons, fmt.Sprintf("%s: %v", c.name, err))
+		}
+	}
+	if len(reasons) > 0 {
+		return HealthNotServing, reasons
+	}
+	return HealthServing, nil
+}
+
+// servants returns the tars and http servants except the admin servant.
+func (a *application) servants() []string {
+	var servants []string
+	for _, obj := range a.objRunList {
+		if obj != "AdminObj" {
+			servants = append(servants, obj)
+		}
+	}
+	sort.Strings(servants)
+	return servants
+}
+
+// healthHandler serves /healthz for the liveness and /readyz for the readiness of the servants,
+// /readyz?servant=App.Server.Obj checks only the servant.
+func (a *application) healthHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		for obj, s := range a.goSvrs {
+			if s.IsZombie(a.svrCfg.ZombieTimeout) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprintf(w, "%s is zombie\n", obj)
+				return
+			}
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		servants := a.servants()
+		if servant := r.URL.Query().Get("servant"); servant != "" {
+			servants = nil
+			for _, obj := range a.servants() {
+				if obj == servant {
+					servants = []string{obj}
+				}
+			}
+			if servants == nil {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		var sb strings.Builder
+		code := http.StatusOK
+		for _, obj := range servants {
+			status, reasons := a.CheckHealth(r.Context(), obj)
+			if status != HealthServing {
+				code = http.StatusServiceUnavailable
+			}
+			sb.WriteString(obj + " " + status.String())
+			if len(reasons) > 0 {
+				sb.WriteString(" " + strings.Join(reasons, "; "))
+			}
+			sb.WriteString("\n")
+		}
+		w.WriteHeader(code)
+		fmt.Fprint(w, sb.String())
+	})
+	return mux
+}
+
+// startHealthServer serves the health endpoints on the health address if it is set.
+func (a *application) startHealthServer() {
+	addr := a.svrCfg.HealthAddress
+	if addr == "" {
+		return
+	}
+	a.healthSvr = &http.Server{Addr: addr, Handler: a.healthHandler(), ReadTimeout: a.svrCfg.ReadTimeout}
+	TLOG.Infof("health server start on %s", addr)
+	go func() {
+		if err := a.healthSvr.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			TLOG.Errorf("health server on %s stop: %v", addr, err)
+		}
+	}()
+}
+
+func (a *application) stopHealthServer() {
+	if a.healthSvr == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	a.healthSvr.Shutdown(ctx)
+}
+
+// replyHealth replies the serving status of the servant to tars_ping with StatusHealthKey.
+func (s *Protocol) replyHealth(ctx context.Context, req *requestf.RequestPacket, rsp *requestf.ResponsePacket) {
+	status, reasons := s.app.CheckHealth(ctx, req.SServantName)
+	rsp.Status = map[string]string{StatusHealthKey: status.String()}
+	if len(reasons) > 0 {
+		rsp.SResultDesc = strings.Join(reasons, "; ")
+	}
+}
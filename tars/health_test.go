@@ -0,0 +1,103 @@
+package tars
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+)
+
+func TestCheckHealth(t *testing.T) {
+	app := newApp()
+	if status, _ := app.CheckHealth(context.Background(), "App.Server.Obj"); status != HealthNotServing {
+		t.Errorf("status before ready = %s, want NOT_SERVING", status)
+	}
+	app.ready = 1
+	if status, _ := app.CheckHealth(context.Background(), "App.Server.Obj"); status != HealthServing {
+		t.Errorf("status = %s, want SERVING", status)
+	}
+
+	var dbErr error
+	app.RegisterHealthCheck(AllServants, "db", func(ctx context.Context) error { return dbErr })
+	dbErr = errors.New("connection refused")
+	status, reasons := app.CheckHealth(context.Background(), "App.Server.Obj")
+	if status != HealthNotServing || len(reasons) != 1 || reasons[0] != "db: connection refused" {
+		t.Errorf("status = %s %v, want NOT_SERVING by db", status, reasons)
+	}
+	dbErr = nil
+
+	app.SetServingStatus("App.Server.Obj", HealthNotServing)
+	if status, _ = app.CheckHealth(context.Background(), "App.Server.Obj"); status != HealthNotServing {
+		t.Errorf("status = %s, want NOT_SERVING by setting", status)
+	}
+	if status, _ = app.CheckHealth(context.Background(), "App.Server.OtherObj"); status != HealthServing {
+		t.Errorf("status of other servant = %s, want SERVING", status)
+	}
+	app.SetServingStatus("App.Server.Obj", HealthServing)
+	if status, _ = app.CheckHealth(context.Background(), "App.Server.Obj"); status != HealthServing {
+		t.Errorf("status = %s, want SERVING after clearing", status)
+	}
+}
+
+func TestPingHealth(t *testing.T) {
+	app := newApp()
+	app.ready = 1
+	app.SetServingStatus("App.Server.Obj", HealthNotServing)
+	server := NewTarsProtocol(&echoDispatcher{}, nil, false)
+	server.app = app
+	proto := &protocol.TarsProtocol{}
+
+	ping := func(status map[string]string) *requestf.ResponsePacket {
+		pkg, err := proto.RequestPack(&requestf.RequestPacket{
+			IVersion:     basef.TARSVERSION,
+			IRequestId:   1,
+			SServantName: "App.Server.Obj",
+			SFuncName:    "tars_ping",
+			Status:       status,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp, err := proto.ResponseUnpack(server.Invoke(context.Background(), pkg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rsp
+	}
+	if rsp := ping(nil); rsp.IRet != 0 || len(rsp.Status) != 0 {
+		t.Errorf("plain ping response: %+v", rsp)
+	}
+	if rsp := ping(map[string]string{StatusHealthKey: ""}); ParseHealthStatus(rsp.Status[StatusHealthKey]) != HealthNotServing {
+		t.Errorf("health ping response: %+v", rsp)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	app := newApp()
+	app.objRunList = []string{"App.Server.AObj", "App.Server.BObj", "AdminObj"}
+	app.ready = 1
+	app.SetServingStatus("App.Server.BObj", HealthNotServing)
+	h := app.healthHandler()
+
+	for _, tt := range []struct {
+		path string
+		code int
+	}{
+		{"/healthz", http.StatusOK},
+		{"/readyz", http.StatusServiceUnavailable},
+		{"/readyz?servant=App.Server.AObj", http.StatusOK},
+		{"/readyz?servant=App.Server.BObj", http.StatusServiceUnavailable},
+		{"/readyz?servant=AdminObj", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("GET %s = %d %s, want %d", tt.path, w.Code, w.Body.String(), tt.code)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package tars
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/util/conf"
+	"github.com/TarsCloud/TarsGo/tars/util/current"
+)
+
+var errHedgeLost = errors.New("hedged request canceled, another request has returned")
+
+// HedgePolicy is the hedged request policy of a servant proxy.
+// When a call has not returned after the delay, a duplicate request is sent to another endpoint,
+// and the first response is used. Only idempotent methods should be hedged.
+type HedgePolicy struct {
+	// Methods are the methods which can be hedged, "*" means all the methods.
+	Methods []string
+	// Delay is the fixed wait time before sending a hedged request,
+	// zero means using the Percentile of the recent call costs.
+	Delay time.Duration
+	// Percentile of the recent call costs, such as 0.95 for p95.
+	Percentile float64
+	// MinSamples is the number of recent call costs needed by the Percentile delay.
+	MinSamples int
+	// MaxHedges is the max number of hedged requests of a call.
+	MaxHedges int
+}
+
+// NewHedgePolicy returns a hedge policy of the methods with default values.
+func NewHedgePolicy(methods ...string) *HedgePolicy {
+	return &HedgePolicy{
+		Methods:    methods,
+		Percentile: hedgePercentile,
+		MinSamples: hedgeMinSamples,
+		MaxHedges:  1,
+	}
+}
+
+func (p *HedgePolicy) enabled() bool {
+	return p != nil && p.MaxHedges > 0 && len(p.Methods) > 0
+}
+
+func (p *HedgePolicy) key() string {
+	return fmt.Sprintf("hedge:%v:%v:%v:%d:%d", p.Methods, p.Delay, p.Percentile, p.MinSamples, p.MaxHedges)
+}
+
+func (p *HedgePolicy) hedgeable(method string) bool {
+	for _, m := range p.Methods {
+		if m == "*" || m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the wait time before sending hedged request, zero means not hedging.
+func (p *HedgePolicy) delay(stats *costStats) time.Duration {
+	if p.Delay > 0 {
+		return p.Delay
+	}
+	if stats == nil {
+		return 0
+	}
+	cost, ok := stats.percentile(p.Percentile, p.MinSamples)
+	if !ok {
+		return 0
+	}
+	if cost <= 0 {
+		cost = 1
+	}
+	return time.Duration(cost) * time.Millisecond
+}
+
+// costStats records the recent call costs (in ms) of an obj.
+type costStats struct {
+	mu     sync.Mutex
+	costs  []int64
+	pos    int
+	full   bool
+	dirty  int
+	cacheP float64
+	cacheV int64
+}
+
+func newCostStats() *costStats {
+	return &costStats{costs: make([]int64, hedgeCostWindow)}
+}
+
+func (c *costStats) record(cost int64) {
+	c.mu.Lock()
+	c.costs[c.pos] = cost
+	c.pos++
+	if c.pos == len(c.costs) {
+		c.pos = 0
+		c.full = true
+	}
+	c.dirty++
+	c.mu.Unlock()
+}
+
+// percentile returns the p percentile of recent costs, and false if the samples are not enough.
+func (c *costStats) percentile(p float64, minSamples int) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.pos
+	if c.full {
+		n = len(c.costs)
+	}
+	if n == 0 || n < minSamples {
+		return 0, false
+	}
+	// avoid sorting for every call
+	if c.cacheP == p && c.dirty < hedgeCostRecompute {
+		return c.cacheV, true
+	}
+	sorted := make([]int64, n)
+	copy(sorted, c.costs[:n])
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	idx := int(float64(n) * p)
+	if idx >= n {
+		idx = n - 1
+	}
+	c.cacheP, c.cacheV, c.dirty = p, sorted[idx], 0
+	return c.cacheV, true
+}
+
+type hedgeResult struct {
+	msg *Message
+	err error
+}
+
+// hedgeCopy copies the message for a hedged request with a new request id.
+func (m *Message) hedgeCopy(reqID int32) *Message {
+	req := *m.Req
+	req.IRequestId = reqID
+	return &Message{
+		Req:       &req,
+		Resp:      m.Resp,
+		Ser:       m.Ser,
+		BeginTime: m.BeginTime,
+		hashCode:  m.hashCode,
+		hashType:  m.hashType,
+		isHash:    m.isHash,
+	}
+}
+
+// invokeWithHedge invokes the request, and sends hedged requests to other adapter proxies
+// if the call has not returned after the hedge delay.
+func (s *ServantProxy) invokeWithHedge(ctx context.Context, msg *Message, timeout time.Duration) error {
+	policy, stats := s.manager.hedgePolicy()
+	if !policy.enabled() || msg.isHash || msg.Req.CPacketType == basef.TARSONEWAY || !policy.hedgeable(msg.Req.SFuncName) {
+		return s.doInvoke(ctx, msg, timeout)
+	}
+	delay := policy.delay(stats)
+	if delay <= 0 || delay >= timeout {
+		return s.doInvoke(ctx, msg, timeout)
+	}
+
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan hedgeResult, policy.MaxHedges+1)
+	attempts := make([]*Message, 0, policy.MaxHedges+1)
+	tried := append([]*AdapterProxy(nil), msg.triedAdps...)
+	launch := func() bool {
+		reqID := msg.Req.IRequestId
+		if len(attempts) > 0 {
+			// do not amplify the load when the invoke queue is filling up
+			if atomic.LoadInt32(&s.queueLen) >= s.comm.Client.ObjQueueMax {
+				return false
+			}
+			reqID = s.genRequestID()
+		}
+		m := msg.hedgeCopy(reqID)
+		m.triedAdps = tried
+		adp, needCheck := s.manager.SelectAdapterProxy(m)
+		if adp == nil || (len(attempts) > 0 && m.isTried(adp)) {
+			return false
+		}
+		tried = append(tried, adp)
+		attempts = append(attempts, m)
+		go func() {
+			results <- hedgeResult{msg: m, err: s.invokeAdapter(hedgeCtx, m, adp, needCheck)}
+		}()
+		return true
+	}
+	if !launch() {
+		return s.doInvoke(ctx, msg, timeout)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	pending := 1
+	var res hedgeResult
+	for {
+		select {
+		case <-timer.C:
+			if len(attempts) <= policy.MaxHedges && hedgeCtx.Err() == nil && launch() {
+				TLOG.Debugf("send hedged request, obj: %s, func: %s, delay: %v, reqid: %d", s.name, msg.Req.SFuncName, delay, attempts[len(attempts)-1].Req.IRequestId)
+				pending++
+				timer.Reset(delay)
+			}
+			continue
+		case res = <-results:
+			pending--
+		}
+		if res.err == nil || pending == 0 {
+			break
+		}
+	}
+
+	// drop the other requests
+	for _, m := range attempts {
+		if m != res.msg {
+			atomic.StoreInt32(&m.hedgeLost, 1)
+		}
+	}
+	msg.Adp = res.msg.Adp
+	msg.Resp = res.msg.Resp
+	msg.Status = res.msg.Status
+	msg.triedAdps = tried
+	if msg.Adp != nil {
+		ep := msg.Adp.GetPoint()
+		current.SetServerIPWithContext(ctx, ep.Host)
+		current.SetServerPortWithContext(ctx, fmt.Sprintf("%v", ep.Port))
+	}
+	return res.err
+}
+
+// parseHedgePolicy parses the hedge policy of the obj from the client config domain,
+// returns nil if hedging is not enabled.
+func parseHedgePolicy(c *conf.Conf, path string) *HedgePolicy {
+	methods := splitConfList(c.GetString(path + "<hedge-methods>"))
+	if len(methods) == 0 {
+		return nil
+	}
+	p := NewHedgePolicy(methods...)
+	p.Delay = time.Duration(c.GetIntWithDef(path+"<hedge-delay>", 0)) * time.Millisecond
+	p.Percentile = c.GetFloatWithDef(path+"<hedge-percentile>", hedgePercentile)
+	p.MinSamples = c.GetIntWithDef(path+"<hedge-min-samples>", hedgeMinSamples)
+	p.MaxHedges = c.GetIntWithDef(path+"<hedge-max>", 1)
+	return p
+}
@@ -0,0 +1,140 @@
+package tars
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+	"github.com/TarsCloud/TarsGo/tars/util/endpoint"
+)
+
+func TestCostStats_percentile(t *testing.T) {
+	c := newCostStats()
+	if _, ok := c.percentile(0.95, 1); ok {
+		t.Fatal("percentile() ok = true, want false without samples")
+	}
+	for i := int64(1); i <= 100; i++ {
+		c.record(i)
+	}
+	if got, ok := c.percentile(0.95, 20); !ok || got != 96 {
+		t.Errorf("percentile() = %v, %v, want 96, true", got, ok)
+	}
+	// ring buffer overwrites the oldest costs
+	for i := 0; i < 100; i++ {
+		c.record(5)
+	}
+	if got, _ := c.percentile(0.95, 20); got != 5 {
+		t.Errorf("percentile() = %v, want 5", got)
+	}
+}
+
+func TestHedgePolicy_delay(t *testing.T) {
+	p := NewHedgePolicy("get")
+	if got := p.delay(nil); got != 0 {
+		t.Errorf("delay() = %v, want 0 without stats", got)
+	}
+	c := newCostStats()
+	for i := 0; i < hedgeMinSamples; i++ {
+		c.record(30)
+	}
+	if got := p.delay(c); got != 30*time.Millisecond {
+		t.Errorf("delay() = %v, want 30ms", got)
+	}
+	p.Delay = 10 * time.Millisecond
+	if got := p.delay(c); got != 10*time.Millisecond {
+		t.Errorf("delay() = %v, want fixed 10ms", got)
+	}
+	if !p.hedgeable("get") || p.hedgeable("set") {
+		t.Error("hedgeable() only accepts the configured methods")
+	}
+}
+
+// hedgeManager selects the adapter proxies in order, the first one for the first request.
+type hedgeManager struct {
+	EndpointManager
+	adps   []*AdapterProxy
+	policy *HedgePolicy
+}
+
+func (m *hedgeManager) SelectAdapterProxy(msg *Message) (*AdapterProxy, bool) {
+	for _, adp := range m.adps {
+		if !msg.isTried(adp) {
+			return adp, false
+		}
+	}
+	return nil, false
+}
+
+func (m *hedgeManager) hedgePolicy() (*HedgePolicy, *costStats) {
+	return m.policy, nil
+}
+
+func TestInvokeWithHedge(t *testing.T) {
+	app := newApp()
+	comm := newCommunicator(app, app.cltCfg)
+	s := &ServantProxy{name: "App.Server.Obj", comm: comm, version: basef.TARSVERSION, proto: &protocol.TarsProtocol{}}
+	newAdapter := func(d dispatch) *AdapterProxy {
+		server := NewTarsProtocol(d, nil, false)
+		server.app = app
+		address := startServer(t, server)
+		point := endpoint.Endpoint2tars(endpoint.Parse("tcp -h 127.0.0.1 -p " + strconv.Itoa(address.Port)))
+		adp := NewAdapterProxy("App.Server.Obj", &point, comm)
+		adp.servantProxy = s
+		t.Cleanup(adp.Close)
+		return adp
+	}
+	slow := newAdapter(&sleepDispatcher{})
+	fast := newAdapter(&echoDispatcher{})
+	s.manager = &hedgeManager{adps: []*AdapterProxy{slow, fast}, policy: &HedgePolicy{Methods: []string{"Echo"}, Delay: 20 * time.Millisecond, MaxHedges: 1}}
+
+	msg := &Message{Req: &requestf.RequestPacket{
+		IVersion:     basef.TARSVERSION,
+		CPacketType:  basef.TARSNORMAL,
+		IRequestId:   s.genRequestID(),
+		SServantName: "App.Server.Obj",
+		SFuncName:    "Echo",
+		SBuffer:      []int8{1, 2, 3},
+	}}
+	start := time.Now()
+	if err := s.invokeWithHedge(context.Background(), msg, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if cost := time.Since(start); cost >= 100*time.Millisecond {
+		t.Errorf("hedged call cost %v, want less than the slow handler", cost)
+	}
+	if msg.Adp != fast {
+		t.Fatal("response is not from the hedged adapter")
+	}
+	if msg.Resp == nil || len(msg.Resp.SBuffer) != 3 {
+		t.Fatalf("response %+v, want the echo of the hedged request", msg.Resp)
+	}
+
+	// the slow request is dropped without failing the adapter
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&slow.inflight) != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&slow.inflight); n != 0 {
+		t.Fatalf("slow adapter has %d requests in flight", n)
+	}
+	if n := atomic.LoadInt32(&slow.failCount); n != 0 {
+		t.Errorf("slow adapter failCount = %d, want 0", n)
+	}
+
+	// the loser ends with errHedgeLost
+	ctx, cancel := context.WithCancel(context.Background())
+	loser := msg.hedgeCopy(s.genRequestID())
+	atomic.StoreInt32(&loser.hedgeLost, 1)
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := s.invokeAdapter(ctx, loser, slow, false); err != errHedgeLost {
+		t.Errorf("invokeAdapter() of the loser = %v, want errHedgeLost", err)
+	}
+	if n := atomic.LoadInt32(&slow.failCount); n != 0 {
+		t.Errorf("slow adapter failCount = %d after the loser, want 0", n)
+	}
+}
@@ -0,0 +1,59 @@
+package tars
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// runHooks runs the hooks of the stage in order, each hook is limited by the hook timeout.
+// It returns the first error, and the rest hooks are not run if abort is true.
+func (a *application) runHooks(ctx context.Context, stage hookStage, abort bool) error {
+	var err error
+	for i, hook := range a.opt.hooks[stage] {
+		start := time.Now()
+		if hookErr := runHook(ctx, hook, a.opt.hookTimeout); hookErr != nil {
+			TLOG.Errorf("%s hook No.%d failed, cost: %v, err: %v", stage, i, time.Since(start), hookErr)
+			if err == nil {
+				err = fmt.Errorf("%s hook No.%d: %w", stage, i, hookErr)
+			}
+			if abort {
+				return err
+			}
+			continue
+		}
+		TLOG.Infof("%s hook No.%d done, cost: %v", stage, i, time.Since(start))
+	}
+	return err
+}
+
+// runHook runs the hook, and returns the error of ctx if the hook does not return in time.
+func runHook(ctx context.Context, hook Hook, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- callHook(ctx, hook)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// callHook calls the hook, the panic of the hook is returned as an error instead of exiting the process.
+func callHook(ctx context.Context, hook Hook) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			TLOG.Errorf("hook panic: %v\n%s", r, debug.Stack())
+			err = fmt.Errorf("hook panic: %v", r)
+		}
+	}()
+	return hook(ctx)
+}
@@ -0,0 +1,100 @@
+package tars
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunHooks(t *testing.T) {
+	app := newApp()
+	var order []int
+	errHook := errors.New("hook error")
+	opts := []Option{
+		HookTimeout(10 * time.Millisecond),
+		BeforeStart(func(ctx context.Context) error {
+			order = append(order, 1)
+			return nil
+		}),
+		BeforeStart(func(ctx context.Context) error {
+			order = append(order, 2)
+			return errHook
+		}),
+		BeforeStart(func(ctx context.Context) error {
+			order = append(order, 3)
+			return nil
+		}),
+		BeforeStop(func(ctx context.Context) error {
+			// the hook ignoring ctx is abandoned after the timeout
+			time.Sleep(time.Second)
+			return nil
+		}),
+		BeforeStop(func(ctx context.Context) error {
+			order = append(order, 4)
+			return nil
+		}),
+	}
+	for _, opt := range opts {
+		opt(app.opt)
+	}
+
+	if err := app.runHooks(context.Background(), hookBeforeStart, true); !errors.Is(err, errHook) {
+		t.Errorf("BeforeStart hooks error: %v, want %v", err, errHook)
+	}
+	start := time.Now()
+	if err := app.runHooks(context.Background(), hookBeforeStop, false); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("BeforeStop hooks error: %v, want timeout", err)
+	}
+	if cost := time.Since(start); cost > 500*time.Millisecond {
+		t.Errorf("BeforeStop hooks cost %v, want the hook timeout", cost)
+	}
+	if len(order) != 3 || order[0] != 1 || order[1] != 2 || order[2] != 4 {
+		t.Errorf("hooks run in %v, want [1 2 4]", order)
+	}
+}
+
+func TestRunHookPanic(t *testing.T) {
+	err := runHook(context.Background(), func(ctx context.Context) error {
+		panic("hook failed")
+	}, time.Second)
+	if err == nil || !strings.Contains(err.Error(), "hook failed") {
+		t.Errorf("panic hook error: %v", err)
+	}
+}
+
+func TestGraceShutdownOnce(t *testing.T) {
+	app := newApp()
+	app.svrCfg.GracedownTimeout = time.Second
+	app.svrCfg.DeregisterTimeout = 10 * time.Millisecond
+	app.svrCfg.PropagationDelay = 10 * time.Millisecond
+	var stops int32
+	BeforeStop(func(ctx context.Context) error {
+		atomic.AddInt32(&stops, 1)
+		return nil
+	})(app.opt)
+	app.AddShutdownHook(ShutdownDestroy, func(ctx context.Context) error {
+		panic("destroy failed")
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app.graceShutdown()
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&stops); n != 1 {
+		t.Errorf("BeforeStop hooks run %d times, want once", n)
+	}
+	select {
+	case <-app.shutdown:
+	default:
+		t.Error("application is not torn down")
+	}
+}
@@ -31,6 +31,11 @@ type Message struct {
 	hashCode uint32
 	hashType HashType
 	isHash   bool
+
+	// adapter proxies which have been tried by the former attempts
+	triedAdps []*AdapterProxy
+	// hedgeLost is set when another hedged request of the call has returned first
+	hedgeLost int32
 }
 
 // Init define the beginTime
@@ -66,3 +71,12 @@ func (m *Message) HashType() selector.HashType {
 func (m *Message) IsHash() bool {
 	return m.isHash
 }
+
+func (m *Message) isTried(adp *AdapterProxy) bool {
+	for _, v := range m.triedAdps {
+		if v == adp {
+			return true
+		}
+	}
+	return false
+}
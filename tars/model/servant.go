@@ -28,3 +28,20 @@ type Protocol interface {
 	ResponseUnpack([]byte) (*requestf.ResponsePacket, error)
 	ParsePackage([]byte) (int, int)
 }
+
+// Stream is a message stream of a stream call, which is multiplexed on the servant connection.
+type Stream interface {
+	// Context returns the context of the stream, which is done when the stream is finished.
+	Context() context.Context
+	// SendMsg sends a message, it blocks if the peer has not granted enough window.
+	SendMsg(buf []byte) error
+	// RecvMsg receives a message, it returns io.EOF when the peer has finished sending.
+	RecvMsg() ([]byte, error)
+	// CloseSend tells the peer that no more message will be sent.
+	CloseSend() error
+}
+
+// StreamServant is the servant which supports stream calls.
+type StreamServant interface {
+	NewStream(ctx context.Context, sFuncName string, status map[string]string, context map[string]string) (Stream, error)
+}
@@ -1,11 +1,18 @@
 package tars
 
-import "github.com/TarsCloud/TarsGo/tars/registry"
+import (
+	"context"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/registry"
+)
 
 type Option func(o *options)
 
 type options struct {
-	registrar registry.Registrar
+	registrar   registry.Registrar
+	hooks       [hookStageNum][]Hook
+	hookTimeout time.Duration
 }
 
 // Registrar returns an Option to use the Registrar
@@ -14,3 +21,59 @@ func Registrar(r registry.Registrar) Option {
 		o.registrar = r
 	}
 }
+
+// Hook is a lifecycle hook of the application, ctx is done when the hook times out.
+type Hook func(ctx context.Context) error
+
+type hookStage int
+
+const (
+	hookBeforeStart hookStage = iota
+	hookAfterStart
+	hookBeforeStop
+	hookAfterStop
+	hookStageNum
+)
+
+var hookStageNames = [hookStageNum]string{"BeforeStart", "AfterStart", "BeforeStop", "AfterStop"}
+
+func (s hookStage) String() string {
+	return hookStageNames[s]
+}
+
+// BeforeStart returns an Option to run the hook before the listeners open, such as warming caches.
+// The error of the hook aborts the startup.
+func BeforeStart(hook Hook) Option {
+	return func(o *options) {
+		o.hooks[hookBeforeStart] = append(o.hooks[hookBeforeStart], hook)
+	}
+}
+
+// AfterStart returns an Option to run the hook after the listeners open, such as registering with external systems.
+// The error of the hook shuts down the application.
+func AfterStart(hook Hook) Option {
+	return func(o *options) {
+		o.hooks[hookAfterStart] = append(o.hooks[hookAfterStart], hook)
+	}
+}
+
+// BeforeStop returns an Option to run the hook when the graceful shutdown begins, such as flushing buffers.
+func BeforeStop(hook Hook) Option {
+	return func(o *options) {
+		o.hooks[hookBeforeStop] = append(o.hooks[hookBeforeStop], hook)
+	}
+}
+
+// AfterStop returns an Option to run the hook after the application stops.
+func AfterStop(hook Hook) Option {
+	return func(o *options) {
+		o.hooks[hookAfterStop] = append(o.hooks[hookAfterStop], hook)
+	}
+}
+
+// HookTimeout returns an Option to set the timeout of every lifecycle hook.
+func HookTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.hookTimeout = timeout
+	}
+}
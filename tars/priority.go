@@ -0,0 +1,179 @@
+package tars
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol/codec"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+	"github.com/TarsCloud/TarsGo/tars/transport"
+	"github.com/TarsCloud/TarsGo/tars/util/conf"
+	"github.com/TarsCloud/TarsGo/tars/util/gpool"
+)
+
+var _ transport.PriorityProtocol = (*Protocol)(nil)
+
+// priorityNum is the number of the priorities from PriorityLow to PriorityHigh.
+const priorityNum = PriorityHigh + 1
+
+var priorityNames = [priorityNum]string{"low", "normal", "high"}
+
+// PriorityClassifier classifies the request before it is queued, ok is false to leave it to the
+// per-function config and the request context.
+type PriorityClassifier func(req *requestf.RequestPacket) (priority int, ok bool)
+
+// SetPriorityClassifier sets the classifier of the requests for the priority queues.
+func SetPriorityClassifier(c PriorityClassifier) {
+	defaultApp.SetPriorityClassifier(c)
+}
+
+// SetPriorityClassifier sets the classifier of the requests for the priority queues.
+func (a *application) SetPriorityClassifier(c PriorityClassifier) {
+	a.priorityClassifier = c
+}
+
+// parsePriority parses the priority by its name or number.
+func parsePriority(s string) (int, bool) {
+	for i, name := range priorityNames {
+		if strings.EqualFold(s, name) {
+			return i, true
+		}
+	}
+	if p, err := strconv.Atoi(s); err == nil && p >= PriorityLow && p <= PriorityHigh {
+		return p, true
+	}
+	return PriorityNormal, false
+}
+
+// parseFuncPriorities parses the priorities of the functions from the domain, in the form of <FuncName=high>.
+func parseFuncPriorities(c *conf.Conf, path string) map[string]int {
+	var priorities map[string]int
+	for funcName, v := range c.GetMap(path) {
+		p, ok := parsePriority(v)
+		if !ok {
+			TLOG.Errorf("invalid priority of %s in %s: %s", funcName, path, v)
+			continue
+		}
+		if priorities == nil {
+			priorities = make(map[string]int)
+		}
+		priorities[funcName] = p
+	}
+	return priorities
+}
+
+// Priority returns the priority of the request for the priority queues, which is decided by the priority
+// classifier, the per-function config, and then PriorityKey of the request context in order.
+// tars_ping is PriorityHigh by default, so that the health checks are not queued behind the business.
+// Only the function name and the context are read unless there is a classifier.
+func (s *Protocol) Priority(pkg []byte) int {
+	if c := s.app.priorityClassifier; c != nil {
+		// the classifier may look into any field of the request
+		req := requestf.RequestPacket{}
+		if err := req.ReadFrom(codec.NewRefReader(pkg[4:])); err != nil {
+			return PriorityNormal
+		}
+		if p, ok := c(&req); ok {
+			return p
+		}
+		if p, ok := s.funcPriority(req.SFuncName); ok {
+			return p
+		}
+		return requestPriority(&req)
+	}
+	var funcName string
+	is := codec.NewRefReader(pkg[4:])
+	if err := is.ReadString(&funcName, 6, true); err != nil {
+		return PriorityNormal
+	}
+	if p, ok := s.funcPriority(funcName); ok {
+		return p
+	}
+	v, err := readContextValue(is, PriorityKey)
+	if err != nil || v == "" {
+		return PriorityNormal
+	}
+	if p, err := strconv.Atoi(v); err == nil {
+		return p
+	}
+	return PriorityNormal
+}
+
+// funcPriority returns the priority of the function by the config.
+func (s *Protocol) funcPriority(funcName string) (int, bool) {
+	if p, ok := s.funcPriorities[funcName]; ok {
+		return p, true
+	}
+	if funcName == "tars_ping" {
+		return PriorityHigh, true
+	}
+	return PriorityNormal, false
+}
+
+// readContextValue reads the value of the key from the context of the request, the reader is after
+// the function name, and the body in between is skipped.
+func readContextValue(is *codec.Reader, key string) (string, error) {
+	if _, err := is.SkipTo(codec.MAP, 9, true); err != nil {
+		return "", err
+	}
+	var length int32
+	if err := is.ReadInt32(&length, 0, true); err != nil {
+		return "", err
+	}
+	var k, v string
+	for i := int32(0); i < length; i++ {
+		if err := is.ReadString(&k, 0, true); err != nil {
+			return "", err
+		}
+		if err := is.ReadString(&v, 1, true); err != nil {
+			return "", err
+		}
+		if k == key {
+			return v, nil
+		}
+	}
+	return "", nil
+}
+
+// reportQueueStats reports the statistics of the priority queues of the tars servers.
+func (a *application) reportQueueStats() {
+	// the property reports need the property server
+	if a.ClientConfig().ValidateProperty() != nil {
+		return
+	}
+	for obj, s := range a.goSvrs {
+		if stats := s.QueueStats(); stats != nil {
+			a.queueReporter.report(obj, stats)
+		}
+	}
+}
+
+// queueReporter reports the length and the average wait of the priority queues of the servers
+// as the properties "<obj>_queue_<priority>_len" and "<obj>_queue_<priority>_wait" in milliseconds.
+type queueReporter struct {
+	last sync.Map // obj -> []gpool.QueueStats
+}
+
+func (r *queueReporter) report(obj string, stats []gpool.QueueStats) {
+	var last []gpool.QueueStats
+	if v, ok := r.last.Load(obj); ok {
+		last = v.([]gpool.QueueStats)
+	}
+	r.last.Store(obj, stats)
+	for i, st := range stats {
+		if i >= priorityNum {
+			break
+		}
+		key := obj + "_queue_" + priorityNames[i]
+		ReportMax(key+"_len", st.Len)
+		dispatched, wait := st.Dispatched, st.Wait
+		if i < len(last) {
+			dispatched -= last[i].Dispatched
+			wait -= last[i].Wait
+		}
+		if dispatched > 0 {
+			ReportAvg(key+"_wait", int(wait.Milliseconds()/int64(dispatched)))
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package tars
+
+import (
+	"testing"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+)
+
+func TestProtocolPriority(t *testing.T) {
+	server := NewTarsProtocol(&echoDispatcher{}, nil, false)
+	server.app = newApp()
+	server.funcPriorities = map[string]int{"Batch": PriorityLow}
+	proto := &protocol.TarsProtocol{}
+	priority := func(funcName string, ctx map[string]string) int {
+		pkg, err := proto.RequestPack(&requestf.RequestPacket{
+			IVersion:     basef.TARSVERSION,
+			IRequestId:   1,
+			SServantName: "App.Server.Obj",
+			SFuncName:    funcName,
+			Context:      ctx,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return server.Priority(pkg)
+	}
+
+	high := map[string]string{PriorityKey: "2"}
+	for _, tt := range []struct {
+		funcName string
+		ctx      map[string]string
+		want     int
+	}{
+		{"Echo", nil, PriorityNormal},
+		{"Echo", high, PriorityHigh},
+		{"Echo", map[string]string{"trace": "1", PriorityKey: "0"}, PriorityLow},
+		{"Echo", map[string]string{PriorityKey: "x"}, PriorityNormal},
+		{"Batch", high, PriorityLow},
+		{"tars_ping", nil, PriorityHigh},
+	} {
+		if got := priority(tt.funcName, tt.ctx); got != tt.want {
+			t.Errorf("priority of %s %v = %d, want %d", tt.funcName, tt.ctx, got, tt.want)
+		}
+	}
+
+	server.app.SetPriorityClassifier(func(req *requestf.RequestPacket) (int, bool) {
+		return PriorityHigh, req.SFuncName == "Batch"
+	})
+	if got := priority("Batch", nil); got != PriorityHigh {
+		t.Errorf("priority by classifier = %d, want %d", got, PriorityHigh)
+	}
+	if p, ok := parsePriority("HIGH"); !ok || p != PriorityHigh {
+		t.Errorf("parsePriority(HIGH) = %d %v", p, ok)
+	}
+}
@@ -0,0 +1,125 @@
+package tars
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/util/conf"
+	"github.com/TarsCloud/TarsGo/tars/util/endpoint"
+)
+
+// ProbePolicy is the active probing policy of the blocked endpoints.
+// The endpoint is probed by tars_ping in the background every Interval, and it is selected again
+// only after Successes probes succeed in a row, instead of being checked by the requests.
+type ProbePolicy struct {
+	// Interval is the interval between the probes.
+	Interval time.Duration
+	// Timeout is the timeout of a probe.
+	Timeout time.Duration
+	// Successes is the number of successful probes in a row to add the endpoint back.
+	Successes int
+	// Health asks for the serving status of the servant, and the endpoint not serving fails the probe.
+	Health bool
+}
+
+// NewProbePolicy returns a probe policy with default values.
+func NewProbePolicy() *ProbePolicy {
+	return &ProbePolicy{
+		Interval:  probeInterval,
+		Timeout:   probeTimeout,
+		Successes: probeSuccesses,
+	}
+}
+
+func (p *ProbePolicy) enabled() bool {
+	return p != nil && p.Interval > 0
+}
+
+func (p *ProbePolicy) key() string {
+	return fmt.Sprintf("probe:%v:%v:%d:%t", p.Interval, p.Timeout, p.Successes, p.Health)
+}
+
+// parseProbePolicy parses the probe policy of the obj from the client config domain,
+// returns nil if neither probe-interval nor health-check is configured.
+func parseProbePolicy(c *conf.Conf, path string) *ProbePolicy {
+	interval := c.GetInt(path + "<probe-interval>")
+	health := c.GetBoolWithDef(path+"<health-check>", false)
+	if interval <= 0 && !health {
+		return nil
+	}
+	p := NewProbePolicy()
+	if interval > 0 {
+		p.Interval = time.Duration(interval) * time.Millisecond
+	}
+	p.Timeout = time.Duration(c.GetIntWithDef(path+"<probe-timeout>", int(probeTimeout/time.Millisecond))) * time.Millisecond
+	p.Successes = c.GetIntWithDef(path+"<probe-successes>", probeSuccesses)
+	p.Health = health
+	return p
+}
+
+// startProbe probes the blocked endpoint in the background, unless it is being probed.
+func (e *endpointManager) startProbe(ep endpoint.Endpoint, adp *AdapterProxy) {
+	if _, loaded := e.probing.LoadOrStore(ep.Key, adp); loaded {
+		return
+	}
+	go e.probeEndpoint(ep, adp)
+}
+
+// probeEndpoint probes the endpoint until it passes the probes, then closes its breaker and adds it back.
+// It stops when the endpoint is no longer active in the registry.
+func (e *endpointManager) probeEndpoint(ep endpoint.Endpoint, adp *AdapterProxy) {
+	defer e.probing.Delete(ep.Key)
+	defer CheckPanic()
+	p := e.probe
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	successes := 0
+	for range ticker.C {
+		if adp.closed || !e.isActiveEpf(ep) {
+			return
+		}
+		if err := adp.probe(p); err != nil {
+			TLOG.Debugf("probe %s of %s failed after %d successes: %v", ep.Key, e.objName, successes, err)
+			successes = 0
+			continue
+		}
+		if successes++; successes < p.Successes {
+			continue
+		}
+		adp.reset()
+		e.addAliveEp(ep)
+		TLOG.Infof("probe %s of %s passed, add it back", ep.Key, e.objName)
+		return
+	}
+}
+
+func (e *endpointManager) isActiveEpf(ep endpoint.Endpoint) bool {
+	e.epLock.Lock()
+	defer e.epLock.Unlock()
+	if e.directProxy {
+		return true
+	}
+	for _, ef := range e.activeEpf {
+		if endpoint.Tars2endpoint(ef).Key == ep.Key {
+			return true
+		}
+	}
+	return false
+}
+
+// errProbeNoProxy is returned by probing the endpoint which has never been used by a servant proxy,
+// the protocol to ping it is unknown. The endpoint is only blocked by the failures of the requests,
+// so it does not happen in practice.
+var errProbeNoProxy = errors.New("endpoint not used by any servant proxy")
+
+// probe reconnects the endpoint and sends tars_ping by the protocol of the servant proxy.
+func (c *AdapterProxy) probe(p *ProbePolicy) error {
+	if c.servantProxy == nil {
+		return errProbeNoProxy
+	}
+	if err := c.tarsClient.ReConnect(); err != nil {
+		return err
+	}
+	return c.ping(p.Timeout, p.Health)
+}
@@ -0,0 +1,70 @@
+package tars
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/util/endpoint"
+)
+
+func TestProbeEndpoint(t *testing.T) {
+	app := newApp()
+	app.ready = 1
+	app.SetServingStatus("App.Server.Obj", HealthNotServing)
+	server := NewTarsProtocol(&echoDispatcher{}, nil, false)
+	server.app = app
+	address := startServer(t, server)
+
+	comm := newCommunicator(app, app.cltCfg)
+	policy := &ProbePolicy{Interval: 10 * time.Millisecond, Timeout: time.Second, Successes: 3, Health: true}
+	e := newEndpointManager("App.Server.Obj@tcp -h 127.0.0.1 -p "+strconv.Itoa(address.Port), comm, WithProbePolicy(policy))
+	ep := e.activeEp[0]
+	adp := e.loadAdapterProxy(endpoint.Endpoint2tars(ep), ep.Key)
+	defer adp.Close()
+	if !adp.probing {
+		t.Fatal("adapter is not probed by the endpoint manager")
+	}
+	if err := adp.probe(policy); err != errProbeNoProxy {
+		t.Fatalf("probe without servant proxy = %v, want errProbeNoProxy", err)
+	}
+	adp.servantProxy = &ServantProxy{name: "App.Server.Obj", version: basef.TARSVERSION, proto: &protocol.TarsProtocol{}}
+
+	activeEps := func() int {
+		e.epLock.Lock()
+		defer e.epLock.Unlock()
+		return len(e.activeEp)
+	}
+
+	// the endpoint is blocked, and not added back while the servant is not serving
+	e.epLock.Lock()
+	e.activeEp = nil
+	e.epLock.Unlock()
+	e.startProbe(ep, adp)
+	time.Sleep(100 * time.Millisecond)
+	if activeEps() != 0 {
+		t.Fatal("endpoint not serving is added back")
+	}
+	app.SetServingStatus("App.Server.Obj", HealthServing)
+	for i := 0; i < 100 && activeEps() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if activeEps() != 1 || !adp.isActive() {
+		t.Fatalf("endpoint is not added back after the probes, active: %d", activeEps())
+	}
+	if _, ok := e.probing.Load(ep.Key); ok {
+		t.Error("prober is not stopped")
+	}
+
+	// the probes are not counted by the circuit breaker
+	b := &countBreaker{}
+	adp.breaker = b
+	if err := adp.probe(policy); err != nil {
+		t.Fatal(err)
+	}
+	if sends, successes, failures := b.counts(); sends != 0 || successes != 0 || failures != 0 {
+		t.Errorf("probe is counted by the breaker, sends: %d, successes: %d, failures: %d", sends, successes, failures)
+	}
+}
@@ -64,6 +64,8 @@ type Buffer struct {
 type Reader struct {
 	ref []byte
 	buf *bytes.Reader
+	// noCopy means the slices read refer to ref instead of copies
+	noCopy bool
 }
 
 //go:nosplit
@@ -397,6 +399,17 @@ func (b *Reader) Next(n int) []byte {
 	return b.ref[beg:end]
 }
 
+// nextRef returns the next n bytes which refer to the data of the reader.
+func (b *Reader) nextRef(n int32) ([]byte, error) {
+	if int(n) > b.buf.Len() {
+		b.Skip(b.buf.Len())
+		return nil, io.EOF
+	}
+	bs := b.Next(int(n))
+	// the appending to the slice must not overwrite the data after it
+	return bs[:n:n], nil
+}
+
 // Skip the next n byte.
 //
 //go:nosplit
@@ -579,6 +592,11 @@ func (b *Reader) ReadSliceInt8(data *[]int8, len int32, require bool) error {
 		return nil
 	}
 
+	if b.noCopy {
+		var err error
+		*(*[]byte)(unsafe.Pointer(data)), err = b.nextRef(len)
+		return err
+	}
 	*data = make([]int8, len)
 	_, err := b.buf.Read(*(*[]uint8)(unsafe.Pointer(data)))
 	if err != nil {
@@ -593,6 +611,11 @@ func (b *Reader) ReadSliceUint8(data *[]uint8, len int32, require bool) error {
 		return nil
 	}
 
+	if b.noCopy {
+		var err error
+		*data, err = b.nextRef(len)
+		return err
+	}
 	*data = make([]uint8, len)
 	_, err := b.buf.Read(*data)
 	if err != nil {
@@ -603,6 +626,11 @@ func (b *Reader) ReadSliceUint8(data *[]uint8, len int32, require bool) error {
 
 // ReadBytes reads []byte for the given length and the require or optional sign.
 func (b *Reader) ReadBytes(data *[]byte, len int32, require bool) error {
+	if b.noCopy && len > 0 {
+		var err error
+		*data, err = b.nextRef(len)
+		return err
+	}
 	*data = make([]byte, len)
 	_, err := b.buf.Read(*data)
 	return err
@@ -884,6 +912,12 @@ func NewReader(data []byte) *Reader {
 	return &Reader{buf: bytes.NewReader(data), ref: data}
 }
 
+// NewRefReader returns *Reader whose []int8 and []byte read refer to the data instead of copies,
+// so the data must not be modified while they are in use.
+func NewRefReader(data []byte) *Reader {
+	return &Reader{buf: bytes.NewReader(data), ref: data, noCopy: true}
+}
+
 // NewBuffer returns *Buffer
 func NewBuffer(args ...*bytes.Buffer) *Buffer {
 	buf := &bytes.Buffer{}
@@ -645,3 +645,65 @@ func TestReader_SkipToNoCheck(t *testing.T) {
 		t.Errorf("SkipToNoCheck error. wantType;%v, gotType:%v \n", FLOAT, gotType)
 	}
 }
+
+func TestBuffer_Release(t *testing.T) {
+	b := AcquireBuffer()
+	if err := b.WriteString("hello", 0); err != nil {
+		t.Fatal(err)
+	}
+	b.Release()
+	if got := AcquireBuffer(); got.Len() != 0 {
+		t.Errorf("acquired buffer is not empty, len %d", got.Len())
+	}
+}
+
+func TestNewRefReader(t *testing.T) {
+	data := []byte{1, 2, 3, 4, 5}
+	rb := NewRefReader(data)
+	var i8 []int8
+	if err := rb.ReadSliceInt8(&i8, 2, true); err != nil {
+		t.Fatal(err)
+	}
+	var u8 []uint8
+	if err := rb.ReadSliceUint8(&u8, 2, true); err != nil {
+		t.Fatal(err)
+	}
+	data[0], data[2] = 10, 30
+	if i8[0] != 10 || u8[0] != 30 {
+		t.Errorf("slices do not refer to the data: %v %v", i8, u8)
+	}
+	// appending must not overwrite the data after the slice
+	_ = append(i8, 100)
+	if data[2] != 30 {
+		t.Errorf("data is overwritten: %v", data)
+	}
+	var bs []byte
+	if err := rb.ReadBytes(&bs, 2, true); err == nil {
+		t.Errorf("read beyond the data: %v", bs)
+	}
+}
+
+// BenchmarkBuffer benchmarks the allocations of the new and the pooled buffers.
+func BenchmarkBuffer(b *testing.B) {
+	payload := make([]int8, 1024)
+	write := func(buf *Buffer) {
+		for i := 0; i < 4; i++ {
+			_ = buf.WriteString("hahahahahahahahahahahahahahahahahahahaha", byte(i))
+		}
+		_ = buf.WriteSliceInt8(payload)
+	}
+	b.Run("New", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			write(NewBuffer())
+		}
+	})
+	b.Run("Pool", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf := AcquireBuffer()
+			write(buf)
+			buf.Release()
+		}
+	})
+}
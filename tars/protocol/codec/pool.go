@@ -0,0 +1,30 @@
+package codec
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBufferSize is the max capacity of the buffer put back to the pool, the larger ones are left to gc.
+const maxPooledBufferSize = 1 << 20
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return &Buffer{buf: &bytes.Buffer{}}
+	},
+}
+
+// AcquireBuffer returns an empty buffer from the pool, which should be released after its bytes are used.
+func AcquireBuffer() *Buffer {
+	return bufferPool.Get().(*Buffer)
+}
+
+// Release resets the buffer and puts it back to the pool,
+// the bytes returned by ToBytes must not be used after it.
+func (b *Buffer) Release() {
+	if b.buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	b.buf.Reset()
+	bufferPool.Put(b)
+}
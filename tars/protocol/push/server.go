@@ -1,13 +1,12 @@
 package push
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"fmt"
 	"net"
 
 	"github.com/TarsCloud/TarsGo/tars"
+	"github.com/TarsCloud/TarsGo/tars/protocol"
 	"github.com/TarsCloud/TarsGo/tars/protocol/codec"
 	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
 	"github.com/TarsCloud/TarsGo/tars/transport"
@@ -77,12 +76,7 @@ func (s *serverProtocol) Invoke(ctx context.Context, reqBytes []byte) []byte {
 
 func response2Bytes(rsp *requestf.ResponsePacket) []byte {
 	os := codec.NewBuffer()
+	protocol.ReservePackageLength(os)
 	rsp.WriteTo(os)
-	bs := os.ToBytes()
-	sbuf := bytes.NewBuffer(nil)
-	sbuf.Write(make([]byte, 4))
-	sbuf.Write(bs)
-	len := sbuf.Len()
-	binary.BigEndian.PutUint32(sbuf.Bytes(), uint32(len))
-	return sbuf.Bytes()
+	return protocol.PackageBytes(os)
 }
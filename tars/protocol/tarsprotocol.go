@@ -14,6 +14,11 @@ func SetMaxPackageLength(len int) {
 	maxPackageLength = len
 }
 
+// GetMaxPackageLength returns the max length of tars packet
+func GetMaxPackageLength() int {
+	return maxPackageLength
+}
+
 func TarsRequest(rev []byte) (int, int) {
 	if len(rev) < 4 {
 		return 0, PackageLess
@@ -28,28 +33,45 @@ func TarsRequest(rev []byte) (int, int) {
 	return iHeaderLen, PackageFull
 }
 
+// packageLengthSize is the size of the length at the head of the package.
+const packageLengthSize = 4
+
+var packageLengthPlaceholder = make([]byte, packageLengthSize)
+
+// ReservePackageLength writes the placeholder of the package length, which is filled by PackageBytes.
+func ReservePackageLength(os *codec.Buffer) error {
+	return os.WriteBytes(packageLengthPlaceholder)
+}
+
+// PackageBytes fills the package length in place, and returns the package in the buffer.
+func PackageBytes(os *codec.Buffer) []byte {
+	bs := os.ToBytes()
+	binary.BigEndian.PutUint32(bs, uint32(len(bs)))
+	return bs
+}
+
 type TarsProtocol struct{}
 
 func (p *TarsProtocol) RequestPack(req *requestf.RequestPacket) ([]byte, error) {
-	os := codec.NewBuffer()
-	err := os.WriteSliceInt8(make([]int8, 4))
-	if err != nil {
+	os := codec.AcquireBuffer()
+	defer os.Release()
+	if err := ReservePackageLength(os); err != nil {
 		return nil, err
 	}
-	err = req.WriteTo(os)
-	if err != nil {
+	if err := req.WriteTo(os); err != nil {
 		return nil, err
 	}
-	bs := os.ToBytes()
-	l := len(bs)
-	binary.BigEndian.PutUint32(bs, uint32(l))
-	return bs, nil
-
+	// the package may be queued and resent by the transport, so it is copied out of the pooled buffer
+	bs := PackageBytes(os)
+	pkg := make([]byte, len(bs))
+	copy(pkg, bs)
+	return pkg, nil
 }
 
+// ResponseUnpack decodes the response, whose SBuffer refers to the package.
 func (p *TarsProtocol) ResponseUnpack(pkg []byte) (*requestf.ResponsePacket, error) {
 	packet := &requestf.ResponsePacket{}
-	err := packet.ReadFrom(codec.NewReader(pkg[4:]))
+	err := packet.ReadFrom(codec.NewRefReader(pkg[4:]))
 	return packet, err
 }
 
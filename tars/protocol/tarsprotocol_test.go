@@ -153,3 +153,88 @@ func TestTarsProtocol_ResponseUnpack(t *testing.T) {
 
 	assert.Equal(t, got, resp, "Failed to test ResponseUnpack")
 }
+
+func benchmarkPackets() (*requestf.RequestPacket, *requestf.ResponsePacket) {
+	payload := make([]int8, 1024)
+	req := &requestf.RequestPacket{
+		IVersion:     basef.TARSVERSION,
+		IRequestId:   3,
+		SServantName: "unittest.BenchServer.BenchObj",
+		SFuncName:    "Bench",
+		SBuffer:      payload,
+		ITimeout:     3000,
+		Context:      map[string]string{"hello": "tars"},
+	}
+	rsp := &requestf.ResponsePacket{
+		IVersion:   basef.TARSVERSION,
+		IRequestId: 3,
+		SBuffer:    payload,
+		Context:    map[string]string{"hello": "tars"},
+	}
+	return req, rsp
+}
+
+// BenchmarkRequestPack benchmarks packing the request into the pooled buffer.
+func BenchmarkRequestPack(b *testing.B) {
+	req, _ := benchmarkPackets()
+	p := &TarsProtocol{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.RequestPack(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkResponsePack compares copying the response after the length with filling the length in place.
+func BenchmarkResponsePack(b *testing.B) {
+	_, rsp := benchmarkPackets()
+	b.Run("Copy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			os := codec.NewBuffer()
+			_ = rsp.WriteTo(os)
+			sbuf := bytes.NewBuffer(nil)
+			sbuf.Write(make([]byte, 4))
+			sbuf.Write(os.ToBytes())
+			binary.BigEndian.PutUint32(sbuf.Bytes(), uint32(sbuf.Len()))
+		}
+	})
+	b.Run("InPlace", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			os := codec.AcquireBuffer()
+			_ = ReservePackageLength(os)
+			_ = rsp.WriteTo(os)
+			_ = PackageBytes(os)
+			os.Release()
+		}
+	})
+}
+
+// BenchmarkResponseUnpack compares copying the SBuffer with referring to the package.
+func BenchmarkResponseUnpack(b *testing.B) {
+	_, rsp := benchmarkPackets()
+	os := codec.NewBuffer()
+	_ = ReservePackageLength(os)
+	_ = rsp.WriteTo(os)
+	pkg := PackageBytes(os)
+	b.Run("Copy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			packet := &requestf.ResponsePacket{}
+			if err := packet.ReadFrom(codec.NewReader(pkg[4:])); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Ref", func(b *testing.B) {
+		p := &TarsProtocol{}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := p.ResponseUnpack(pkg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
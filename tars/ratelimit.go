@@ -0,0 +1,110 @@
+package tars
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+	"github.com/TarsCloud/TarsGo/tars/ratelimit"
+	"github.com/TarsCloud/TarsGo/tars/util/conf"
+	"github.com/TarsCloud/TarsGo/tars/util/current"
+)
+
+// Priority of the requests, the requests with lower priority are shed first when the server is overloaded.
+const (
+	PriorityLow = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// PriorityKey is the request context key of the priority, the default priority is PriorityNormal.
+const PriorityKey = "tars-priority"
+
+// RateLimitConfig is the config of the server rate limit middleware.
+type RateLimitConfig struct {
+	// CallerKey is the request context key which identifies the caller, the client ip is used if it is empty or missing.
+	CallerKey string
+	// ShedRatio is the usage of the server queue from which the low priority requests are shed,
+	// and the normal priority ones are shed from the middle of ShedRatio and 1. Zero means no shedding.
+	ShedRatio float64
+}
+
+// SetQuota adds or updates the quota of the server rate limit, the quota with zero rate is removed.
+func SetQuota(q ratelimit.Quota) {
+	defaultApp.quotas.Set(q)
+}
+
+// GetQuotas returns all the quotas of the server rate limit.
+func GetQuotas() []ratelimit.Quota {
+	return defaultApp.quotas.List()
+}
+
+// NewRateLimitMiddleware returns the server filter middleware which limits the requests by the quotas
+// per servant, per function and per caller, and sheds the requests by priority when the server queue is filling up.
+// The quotas are loaded from the server config domain "quota", and can be changed by the admin command tars.setquota.
+// The rejected requests get TARSSERVERRATELIMITED and the shed ones get TARSSERVEROVERLOAD.
+func NewRateLimitMiddleware(cfg RateLimitConfig) ServerFilterMiddleware {
+	quotas := defaultApp.quotas
+	return func(next ServerFilter) ServerFilter {
+		return func(ctx context.Context, d Dispatch, f interface{},
+			req *requestf.RequestPacket, resp *requestf.ResponsePacket, withContext bool) (err error) {
+			if cfg.ShedRatio > 0 && shouldShed(ctx, req, cfg.ShedRatio) {
+				return Errorf(basef.TARSSERVEROVERLOAD, "server overload, request shed, obj: %s, func: %s", req.SServantName, req.SFuncName)
+			}
+			caller := req.Context[cfg.CallerKey]
+			if cfg.CallerKey == "" || caller == "" {
+				caller, _ = current.GetClientIPFromContext(ctx)
+			}
+			if !quotas.Allow(req.SServantName, req.SFuncName, caller) {
+				return Errorf(basef.TARSSERVERRATELIMITED, "rate limited, obj: %s, func: %s, caller: %s", req.SServantName, req.SFuncName, caller)
+			}
+			return next(ctx, d, f, req, resp, withContext)
+		}
+	}
+}
+
+// shouldShed decides whether to shed the request by its priority and the usage of the server queue.
+func shouldShed(ctx context.Context, req *requestf.RequestPacket, shedRatio float64) bool {
+	queueLen, queueCap, ok := current.GetQueueLenFromContext(ctx)
+	if !ok || queueCap <= 0 {
+		return false
+	}
+	usage := float64(queueLen) / float64(queueCap)
+	// the priority which the request is queued by
+	priority, ok := current.GetPriorityFromContext(ctx)
+	if !ok {
+		priority = requestPriority(req)
+	}
+	switch priority {
+	case PriorityLow:
+		return usage >= shedRatio
+	case PriorityNormal:
+		return usage >= (shedRatio+1)/2
+	default:
+		return false
+	}
+}
+
+func requestPriority(req *requestf.RequestPacket) int {
+	if v, ok := req.Context[PriorityKey]; ok {
+		if p, err := strconv.Atoi(v); err == nil {
+			return p
+		}
+	}
+	return PriorityNormal
+}
+
+// parseQuotas parses the quotas from the lines of the domain, in the form of "servant func caller rate [burst]".
+func parseQuotas(c *conf.Conf, path string) []ratelimit.Quota {
+	var quotas []ratelimit.Quota
+	for _, line := range c.GetDomainLine(path) {
+		q, err := ratelimit.ParseQuota(line)
+		if err != nil {
+			TLOG.Errorf("parse quota error: %v", err)
+			continue
+		}
+		quotas = append(quotas, q)
+	}
+	return quotas
+}
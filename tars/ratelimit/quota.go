@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Any matches all the functions or all the callers, which share one bucket.
+const Any = "*"
+
+// Quota is the rate limit of the requests to a servant, a function of it, from a caller, or their combination.
+type Quota struct {
+	Servant string
+	// Func is the function name, Any for all the functions.
+	Func string
+	// Caller is the client ip or the caller key in the request context, Any for all the callers.
+	Caller string
+	// Rate is the number of requests per second.
+	Rate float64
+	// Burst is the max number of requests at once, default is ceil(Rate).
+	Burst int
+}
+
+// String returns the quota in the form of "servant func caller rate burst".
+func (q Quota) String() string {
+	return fmt.Sprintf("%s %s %s %s %d", q.Servant, q.Func, q.Caller, strconv.FormatFloat(q.Rate, 'f', -1, 64), q.Burst)
+}
+
+// ParseQuota parses the quota in the form of "servant func caller rate [burst]".
+func ParseQuota(s string) (Quota, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 4 && len(fields) != 5 {
+		return Quota{}, fmt.Errorf("invalid quota %q, want: servant func caller rate [burst]", s)
+	}
+	q := Quota{Servant: fields[0], Func: fields[1], Caller: fields[2]}
+	var err error
+	if q.Rate, err = strconv.ParseFloat(fields[3], 64); err != nil || q.Rate < 0 {
+		return Quota{}, fmt.Errorf("invalid rate of quota %q", s)
+	}
+	if len(fields) == 5 {
+		if q.Burst, err = strconv.Atoi(fields[4]); err != nil || q.Burst < 0 {
+			return Quota{}, fmt.Errorf("invalid burst of quota %q", s)
+		}
+	}
+	return q, nil
+}
+
+type quotaKey struct {
+	servant string
+	fn      string
+	caller  string
+}
+
+type quotaBucket struct {
+	quota  Quota
+	bucket *TokenBucket
+}
+
+// Quotas is the table of the quotas, which can be changed at runtime.
+type Quotas struct {
+	mu      sync.RWMutex
+	buckets map[quotaKey]*quotaBucket
+}
+
+// NewQuotas returns an empty quota table.
+func NewQuotas() *Quotas {
+	return &Quotas{buckets: make(map[quotaKey]*quotaBucket)}
+}
+
+// Set adds or updates the quota, the quota with zero rate is removed.
+func (t *Quotas) Set(q Quota) {
+	key := quotaKey{servant: q.Servant, fn: q.Func, caller: q.Caller}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if q.Rate <= 0 {
+		delete(t.buckets, key)
+		return
+	}
+	if b, ok := t.buckets[key]; ok {
+		b.quota = q
+		b.bucket.SetRate(q.Rate, q.Burst)
+		return
+	}
+	t.buckets[key] = &quotaBucket{quota: q, bucket: NewTokenBucket(q.Rate, q.Burst)}
+}
+
+// Reset replaces all the quotas.
+func (t *Quotas) Reset(quotas []Quota) {
+	t.mu.Lock()
+	t.buckets = make(map[quotaKey]*quotaBucket)
+	t.mu.Unlock()
+	for _, q := range quotas {
+		t.Set(q)
+	}
+}
+
+// List returns all the quotas in order.
+func (t *Quotas) List() []Quota {
+	t.mu.RLock()
+	quotas := make([]Quota, 0, len(t.buckets))
+	for _, b := range t.buckets {
+		quotas = append(quotas, b.quota)
+	}
+	t.mu.RUnlock()
+	sort.Slice(quotas, func(i, j int) bool {
+		return quotas[i].String() < quotas[j].String()
+	})
+	return quotas
+}
+
+// Allow checks all the quotas matching the request, returns false if any of them is exceeded.
+// The request rejected takes no token from the quotas.
+func (t *Quotas) Allow(servant, fn, caller string) bool {
+	keys := [...]quotaKey{
+		{servant: servant, fn: Any, caller: Any},
+		{servant: servant, fn: fn, caller: Any},
+		{servant: servant, fn: Any, caller: caller},
+		{servant: servant, fn: fn, caller: caller},
+	}
+	now := time.Now()
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(t.buckets) == 0 {
+		return true
+	}
+	taken := make([]*TokenBucket, 0, len(keys))
+	for _, key := range keys {
+		b, ok := t.buckets[key]
+		if !ok {
+			continue
+		}
+		if !b.bucket.Allow(now) {
+			// give back the tokens taken from the quotas shared with other requests
+			for _, tb := range taken {
+				tb.refund()
+			}
+			return false
+		}
+		taken = append(taken, b.bucket)
+	}
+	return true
+}
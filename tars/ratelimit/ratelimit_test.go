@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := NewTokenBucket(10, 2)
+	now := time.Now()
+	if !b.Allow(now) || !b.Allow(now) {
+		t.Fatal("Allow() = false, want true within burst")
+	}
+	if b.Allow(now) {
+		t.Fatal("Allow() = true, want false over burst")
+	}
+	if !b.Allow(now.Add(100 * time.Millisecond)) {
+		t.Fatal("Allow() = false, want true after refill")
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	q, err := ParseQuota("App.Server.Obj add * 1 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	quotas := NewQuotas()
+	quotas.Set(q)
+	if !quotas.Allow("App.Server.Obj", "add", "127.0.0.1") {
+		t.Fatal("Allow() = false, want true within quota")
+	}
+	if quotas.Allow("App.Server.Obj", "add", "127.0.0.2") {
+		t.Fatal("Allow() = true, want false over quota shared by callers")
+	}
+	if !quotas.Allow("App.Server.Obj", "sub", "127.0.0.1") {
+		t.Fatal("Allow() = false, want true for other functions")
+	}
+
+	// the request rejected by the caller quota takes no token of the servant quota
+	all, _ := ParseQuota("App.Server.Obj * * 1 2")
+	quotas.Set(all)
+	caller, _ := ParseQuota("App.Server.Obj * 127.0.0.3 1 1")
+	quotas.Set(caller)
+	if !quotas.Allow("App.Server.Obj", "mul", "127.0.0.3") {
+		t.Fatal("Allow() = false, want true within quota")
+	}
+	if quotas.Allow("App.Server.Obj", "mul", "127.0.0.3") {
+		t.Fatal("Allow() = true, want false over caller quota")
+	}
+	if !quotas.Allow("App.Server.Obj", "mul", "127.0.0.4") {
+		t.Fatal("Allow() = false, want true as the rejected request is refunded")
+	}
+	all.Rate, caller.Rate = 0, 0
+	quotas.Set(all)
+	quotas.Set(caller)
+
+	q.Rate = 0
+	quotas.Set(q)
+	if len(quotas.List()) != 0 {
+		t.Fatal("quota with zero rate is not removed")
+	}
+	if _, err := ParseQuota("App.Server.Obj add 1"); err == nil {
+		t.Fatal("ParseQuota() error = nil, want error")
+	}
+}
@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a token bucket which is filled at the rate per second, and holds at most burst tokens.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket returns a full token bucket, the burst is ceil(rate) if it is not positive.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	b := &TokenBucket{last: time.Now()}
+	b.SetRate(rate, burst)
+	b.tokens = b.burst
+	return b
+}
+
+// SetRate changes the rate and the burst, the tokens are kept.
+func (b *TokenBucket) SetRate(rate float64, burst int) {
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate = rate
+	b.burst = float64(burst)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// Allow takes a token at the time, returns false if there is no token.
+func (b *TokenBucket) Allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refund gives back a token taken by Allow.
+func (b *TokenBucket) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
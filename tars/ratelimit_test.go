@@ -0,0 +1,43 @@
+package tars
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+	"github.com/TarsCloud/TarsGo/tars/ratelimit"
+	"github.com/TarsCloud/TarsGo/tars/util/current"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	SetQuota(ratelimit.Quota{Servant: "App.Server.RateLimitObj", Func: "add", Caller: ratelimit.Any, Rate: 1})
+	defer SetQuota(ratelimit.Quota{Servant: "App.Server.RateLimitObj", Func: "add", Caller: ratelimit.Any})
+	sf := NewRateLimitMiddleware(RateLimitConfig{ShedRatio: 0.5})(
+		func(ctx context.Context, d Dispatch, f interface{}, req *requestf.RequestPacket, resp *requestf.ResponsePacket, withContext bool) error {
+			return nil
+		})
+	invoke := func(ctx context.Context, priority string) error {
+		req := &requestf.RequestPacket{SServantName: "App.Server.RateLimitObj", SFuncName: "add",
+			Context: map[string]string{PriorityKey: priority}}
+		return sf(ctx, nil, nil, req, &requestf.ResponsePacket{}, false)
+	}
+
+	ctx := current.ContextWithTarsCurrent(context.Background())
+	current.SetClientIPWithContext(ctx, "127.0.0.1")
+	if err := invoke(ctx, "1"); err != nil {
+		t.Fatalf("invoke() error = %v, want nil within quota", err)
+	}
+	if code := GetErrorCode(invoke(ctx, "1")); code != basef.TARSSERVERRATELIMITED {
+		t.Fatalf("GetErrorCode() = %d, want %d over quota", code, basef.TARSSERVERRATELIMITED)
+	}
+
+	// queue is 60% full, only the low priority requests are shed
+	current.SetQueueLenWithContext(ctx, 6, 10)
+	if code := GetErrorCode(invoke(ctx, "0")); code != basef.TARSSERVEROVERLOAD {
+		t.Fatalf("GetErrorCode() = %d, want %d for low priority", code, basef.TARSSERVEROVERLOAD)
+	}
+	if code := GetErrorCode(invoke(ctx, "2")); code == basef.TARSSERVEROVERLOAD {
+		t.Fatal("high priority request is shed")
+	}
+}
@@ -29,10 +29,11 @@ func (a *application) registryAdapters(ctx context.Context) {
 	}
 }
 
-func (a *application) deregisterAdapters(ctx context.Context) {
+func (a *application) deregisterAdapters(ctx context.Context) error {
 	if a.opt.registrar == nil {
-		return
+		return nil
 	}
+	var err error
 	svrCfg := GetServerConfig()
 	for _, adapter := range svrCfg.Adapters {
 		servant := &registry.ServantInstance{
@@ -45,8 +46,10 @@ func (a *application) deregisterAdapters(ctx context.Context) {
 			Protocol:    adapter.Protocol,
 			Endpoint:    endpoint.Endpoint2tars(adapter.Endpoint),
 		}
-		if err := a.opt.registrar.Deregister(ctx, servant); err != nil {
-			TLOG.Errorf("deregister: %+v error: %+v", servant, err)
+		if dErr := a.opt.registrar.Deregister(ctx, servant); dErr != nil {
+			TLOG.Errorf("deregister: %+v error: %+v", servant, dErr)
+			err = dErr
 		}
 	}
+	return err
 }
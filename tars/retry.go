@@ -0,0 +1,243 @@
+package tars
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/util/conf"
+)
+
+// DefaultRetryableCodes are the error codes which mean the request has not been processed by the server,
+// so that it is safe to retry any method.
+var DefaultRetryableCodes = []int32{
+	basef.TARSSENDREQUESTERR,
+	basef.TARSSERVERQUEUETIMEOUT,
+	basef.TARSSERVEROVERLOAD,
+}
+
+// RetryPolicy is the client side retry policy of a servant proxy.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of attempts including the first one, retry is disabled if less than 2.
+	MaxAttempts int
+	// Backoff is the wait time before the first retry, and it doubles every retry until MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// PerAttemptTimeout is the timeout of every attempt, zero means the attempt uses the whole call timeout.
+	PerAttemptTimeout time.Duration
+	// RetryableCodes are the error codes which can be retried for all the methods.
+	RetryableCodes []int32
+	// IdempotentMethods can also be retried on any framework error (negative code), such as invoke timeout.
+	// "*" means all the methods are idempotent.
+	IdempotentMethods []string
+	// BudgetRatio is the max ratio of retries to requests of the obj, such as 0.1 for one retry every ten requests.
+	BudgetRatio float64
+	// BudgetMinPerSecond is the number of retries per second which are always allowed.
+	BudgetMinPerSecond int
+}
+
+// NewRetryPolicy returns a retry policy with max attempts and default values.
+func NewRetryPolicy(maxAttempts int) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:        maxAttempts,
+		Backoff:            retryBackoff,
+		MaxBackoff:         retryMaxBackoff,
+		RetryableCodes:     DefaultRetryableCodes,
+		BudgetRatio:        retryBudgetRatio,
+		BudgetMinPerSecond: retryBudgetMinPerSecond,
+	}
+}
+
+func (p *RetryPolicy) enabled() bool {
+	return p != nil && p.MaxAttempts > 1
+}
+
+func (p *RetryPolicy) key() string {
+	return fmt.Sprintf("retry:%d:%v:%v:%v:%v:%v:%v:%d", p.MaxAttempts, p.Backoff, p.MaxBackoff, p.PerAttemptTimeout,
+		p.RetryableCodes, p.IdempotentMethods, p.BudgetRatio, p.BudgetMinPerSecond)
+}
+
+func (p *RetryPolicy) isIdempotent(method string) bool {
+	for _, m := range p.IdempotentMethods {
+		if m == "*" || m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// retryable checks whether a failed call of method with the error code can be retried.
+func (p *RetryPolicy) retryable(method string, code int32) bool {
+	if code == basef.TARSSERVERSUCCESS {
+		return false
+	}
+	for _, c := range p.RetryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	// business error code is positive, never retry it.
+	return code < 0 && p.isIdempotent(method)
+}
+
+// backoff returns the wait time before the n-th retry, n starts from 1.
+func (p *RetryPolicy) backoff(n int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < n && d > 0; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// retryBudget limits the retries of an obj, so that retries can not amplify an outage.
+// Every request deposits ratio token, and every retry withdraws one token.
+type retryBudget struct {
+	mu           sync.Mutex
+	ratio        float64
+	minPerSecond int
+	tokens       float64
+	second       int64
+	minUsed      int
+}
+
+func newRetryBudget(ratio float64, minPerSecond int) *retryBudget {
+	return &retryBudget{ratio: ratio, minPerSecond: minPerSecond}
+}
+
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	b.tokens += b.ratio
+	if max := b.ratio * retryBudgetWindow; b.tokens > max {
+		b.tokens = max
+	}
+	b.mu.Unlock()
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens >= 1 {
+		b.tokens--
+		return true
+	}
+	now := time.Now().Unix()
+	if now != b.second {
+		b.second = now
+		b.minUsed = 0
+	}
+	if b.minUsed < b.minPerSecond {
+		b.minUsed++
+		return true
+	}
+	return false
+}
+
+// msgErrorCode returns the tars error code of the failed message.
+func msgErrorCode(msg *Message, err error) int32 {
+	if msg.Status != basef.TARSSERVERSUCCESS {
+		return msg.Status
+	}
+	if msg.Resp != nil && msg.Resp.IRet != 0 {
+		return msg.Resp.IRet
+	}
+	return GetErrorCode(err)
+}
+
+// invokeWithRetry invokes the request and retries the failed call on another adapter proxy according to the retry policy.
+func (s *ServantProxy) invokeWithRetry(ctx context.Context, msg *Message, timeout time.Duration) error {
+	policy, budget := s.manager.retryPolicy()
+	if !policy.enabled() || msg.Req.CPacketType == basef.TARSONEWAY {
+		return s.invokeWithHedge(ctx, msg, timeout)
+	}
+	budget.deposit()
+	resp := msg.Resp
+	for attempt := 1; ; attempt++ {
+		err := s.doAttempt(ctx, msg, timeout, policy)
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+		code := msgErrorCode(msg, err)
+		if !policy.retryable(msg.Req.SFuncName, code) {
+			return err
+		}
+		if !budget.withdraw() {
+			TLOG.Debugf("retry budget exhausted, obj: %s, func: %s", s.name, msg.Req.SFuncName)
+			return err
+		}
+		if wait := policy.backoff(attempt); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(wait):
+			}
+		}
+		TLOG.Debugf("retry invoke, obj: %s, func: %s, attempt: %d, code: %d, err: %v", s.name, msg.Req.SFuncName, attempt+1, code, err)
+		if msg.Adp != nil {
+			msg.triedAdps = append(msg.triedAdps, msg.Adp)
+		}
+		msg.Req.IRequestId = s.genRequestID()
+		msg.Resp = resp
+		msg.Status = basef.TARSSERVERSUCCESS
+	}
+}
+
+func (s *ServantProxy) doAttempt(ctx context.Context, msg *Message, timeout time.Duration, policy *RetryPolicy) error {
+	if policy.PerAttemptTimeout <= 0 || policy.PerAttemptTimeout >= timeout {
+		return s.invokeWithHedge(ctx, msg, timeout)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, policy.PerAttemptTimeout)
+	defer cancel()
+	return s.invokeWithHedge(attemptCtx, msg, policy.PerAttemptTimeout)
+}
+
+// parseRetryPolicy parses the retry policy of the obj from the client config domain,
+// returns nil if retry is not enabled.
+func parseRetryPolicy(c *conf.Conf, path string) *RetryPolicy {
+	maxAttempts := c.GetIntWithDef(path+"<retry-max-attempts>", 0)
+	if maxAttempts <= 1 {
+		return nil
+	}
+	p := NewRetryPolicy(maxAttempts)
+	p.Backoff = time.Duration(c.GetIntWithDef(path+"<retry-backoff>", int(retryBackoff/time.Millisecond))) * time.Millisecond
+	p.MaxBackoff = time.Duration(c.GetIntWithDef(path+"<retry-max-backoff>", int(retryMaxBackoff/time.Millisecond))) * time.Millisecond
+	p.PerAttemptTimeout = time.Duration(c.GetIntWithDef(path+"<retry-per-attempt-timeout>", 0)) * time.Millisecond
+	p.BudgetRatio = c.GetFloatWithDef(path+"<retry-budget-ratio>", retryBudgetRatio)
+	p.BudgetMinPerSecond = c.GetIntWithDef(path+"<retry-budget-min>", retryBudgetMinPerSecond)
+	if codes := splitConfList(c.GetString(path + "<retry-codes>")); len(codes) > 0 {
+		p.RetryableCodes = nil
+		for _, code := range codes {
+			v, err := strconv.ParseInt(code, 10, 32)
+			if err != nil {
+				TLOG.Errorf("parse retry code %s of %s error: %v", code, path, err)
+				continue
+			}
+			p.RetryableCodes = append(p.RetryableCodes, int32(v))
+		}
+	}
+	p.IdempotentMethods = splitConfList(c.GetString(path + "<retry-idempotent-methods>"))
+	return p
+}
+
+// splitConfList splits the comma separated config value, and drops the empty items.
+func splitConfList(v string) []string {
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,60 @@
+package tars
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+)
+
+func TestRetryPolicy_retryable(t *testing.T) {
+	p := NewRetryPolicy(3)
+	p.IdempotentMethods = []string{"get"}
+	testCases := []struct {
+		name   string
+		method string
+		code   int32
+		want   bool
+	}{
+		{name: "success", method: "set", code: basef.TARSSERVERSUCCESS, want: false},
+		{name: "send error", method: "set", code: basef.TARSSENDREQUESTERR, want: true},
+		{name: "queue timeout", method: "set", code: basef.TARSSERVERQUEUETIMEOUT, want: true},
+		{name: "invoke timeout", method: "set", code: basef.TARSINVOKETIMEOUT, want: false},
+		{name: "idempotent invoke timeout", method: "get", code: basef.TARSINVOKETIMEOUT, want: true},
+		{name: "idempotent business error", method: "get", code: 1, want: false},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.retryable(tt.method, tt.code); got != tt.want {
+				t.Errorf("retryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := &RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	b := newRetryBudget(0.5, 1)
+	b.deposit()
+	// 0.5 token, only the min retry per second is allowed
+	if !b.withdraw() {
+		t.Fatal("withdraw() = false, want min retry allowed")
+	}
+	if b.withdraw() {
+		t.Fatal("withdraw() = true, want budget exhausted")
+	}
+	b.deposit()
+	b.deposit()
+	if !b.withdraw() {
+		t.Fatal("withdraw() = false, want one token")
+	}
+}
@@ -0,0 +1,115 @@
+package p2c
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/selector"
+	"github.com/TarsCloud/TarsGo/tars/util/endpoint"
+)
+
+// LoadFunc returns the load of an endpoint, which is the number of in-flight requests
+// and the moving average of the response time.
+type LoadFunc func(ep endpoint.Endpoint) (inflight int64, latency time.Duration)
+
+// P2C is the "power of two choices" selector, which picks two random endpoints
+// and selects the one with the lower load.
+type P2C struct {
+	sync.RWMutex
+	load      LoadFunc
+	mapValues map[string]struct{}
+	endpoints []endpoint.Endpoint
+	mu        sync.Mutex
+	rand      *rand.Rand
+}
+
+var _ selector.Selector = (*P2C)(nil)
+
+func New(load LoadFunc) *P2C {
+	return &P2C{
+		load:      load,
+		mapValues: make(map[string]struct{}),
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (p *P2C) Select(_ selector.Message) (endpoint.Endpoint, error) {
+	p.RLock()
+	defer p.RUnlock()
+	var ep endpoint.Endpoint
+	n := len(p.endpoints)
+	if n == 0 {
+		return ep, errors.New("p2c: no such endpoint.Endpoint")
+	}
+	if n == 1 {
+		return p.endpoints[0], nil
+	}
+	p.mu.Lock()
+	a := p.rand.Intn(n)
+	b := p.rand.Intn(n - 1)
+	p.mu.Unlock()
+	if b >= a {
+		b++
+	}
+	if p.score(p.endpoints[b]) < p.score(p.endpoints[a]) {
+		return p.endpoints[b], nil
+	}
+	return p.endpoints[a], nil
+}
+
+// score is the expected wait time of a new request on the endpoint.
+func (p *P2C) score(ep endpoint.Endpoint) float64 {
+	if p.load == nil {
+		return 0
+	}
+	inflight, latency := p.load(ep)
+	if latency <= 0 {
+		// no response yet, treat it as a fast endpoint to get the latency
+		latency = time.Millisecond
+	}
+	return float64(latency) * float64(inflight+1)
+}
+
+func (p *P2C) Refresh(eps []endpoint.Endpoint) {
+	p.Lock()
+	defer p.Unlock()
+	p.mapValues = make(map[string]struct{}, len(eps))
+	p.endpoints = make([]endpoint.Endpoint, 0, len(eps))
+	for _, ep := range eps {
+		p.addLocked(ep)
+	}
+}
+
+func (p *P2C) Add(ep endpoint.Endpoint) error {
+	p.Lock()
+	defer p.Unlock()
+	return p.addLocked(ep)
+}
+
+func (p *P2C) addLocked(ep endpoint.Endpoint) error {
+	if _, ok := p.mapValues[ep.Key]; ok {
+		return fmt.Errorf("p2c: endpoint %+v already exists", ep)
+	}
+	p.endpoints = append(p.endpoints, ep)
+	p.mapValues[ep.Key] = struct{}{}
+	return nil
+}
+
+func (p *P2C) Remove(ep endpoint.Endpoint) error {
+	p.Lock()
+	defer p.Unlock()
+	if _, ok := p.mapValues[ep.Key]; !ok {
+		return fmt.Errorf("p2c: endpoint %+v already removed", ep)
+	}
+	delete(p.mapValues, ep.Key)
+	for i, n := range p.endpoints {
+		if n.Key == ep.Key {
+			p.endpoints = append(p.endpoints[:i], p.endpoints[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
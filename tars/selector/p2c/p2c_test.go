@@ -0,0 +1,42 @@
+package p2c
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/util/endpoint"
+)
+
+func TestP2C_Select(t *testing.T) {
+	eps := []endpoint.Endpoint{
+		endpoint.Parse("tcp -h 127.0.0.1 -p 10001"),
+		endpoint.Parse("tcp -h 127.0.0.1 -p 10002"),
+	}
+	// the second endpoint is slow and busy
+	load := func(ep endpoint.Endpoint) (int64, time.Duration) {
+		if ep.Key == eps[1].Key {
+			return 10, 100 * time.Millisecond
+		}
+		return 0, time.Millisecond
+	}
+	p := New(load)
+	p.Refresh(eps)
+	for i := 0; i < 100; i++ {
+		ep, err := p.Select(nil)
+		if err != nil {
+			t.Fatalf("Select() error: %v", err)
+		}
+		if ep.Key != eps[0].Key {
+			t.Fatalf("Select() = %v, want the less loaded %v", ep.Key, eps[0].Key)
+		}
+	}
+	if err := p.Remove(eps[0]); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	if ep, _ := p.Select(nil); ep.Key != eps[1].Key {
+		t.Errorf("Select() = %v, want the only endpoint %v", ep.Key, eps[1].Key)
+	}
+	if err := p.Add(eps[1]); err == nil {
+		t.Error("Add() want error for existing endpoint")
+	}
+}
@@ -0,0 +1,27 @@
+package selector
+
+import "sync"
+
+// Factory creates a Selector of the servant proxy, enableWeight is true if all the endpoints have static weight.
+type Factory func(enableWeight bool) Selector
+
+var (
+	factoryMu sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+// Register registers the selector factory with the name,
+// so that it can be used by tars.WithSelector or the <selector> of client config.
+func Register(name string, f Factory) {
+	factoryMu.Lock()
+	defer factoryMu.Unlock()
+	factories[name] = f
+}
+
+// Get returns the selector factory registered with the name.
+func Get(name string) (Factory, bool) {
+	factoryMu.RLock()
+	defer factoryMu.RUnlock()
+	f, ok := factories[name]
+	return f, ok
+}
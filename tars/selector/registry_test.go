@@ -0,0 +1,53 @@
+package selector
+
+import (
+	"testing"
+
+	"github.com/TarsCloud/TarsGo/tars/util/endpoint"
+)
+
+type firstSelector struct {
+	enableWeight bool
+	nodes        []endpoint.Endpoint
+}
+
+func (s *firstSelector) Select(msg Message) (endpoint.Endpoint, error) {
+	return s.nodes[0], nil
+}
+
+func (s *firstSelector) Refresh(nodes []endpoint.Endpoint) {
+	s.nodes = nodes
+}
+
+func (s *firstSelector) Add(node endpoint.Endpoint) error {
+	return nil
+}
+
+func (s *firstSelector) Remove(node endpoint.Endpoint) error {
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	if _, ok := Get("first"); ok {
+		t.Fatal("Get() ok = true before registered")
+	}
+	Register("first", func(enableWeight bool) Selector {
+		return &firstSelector{enableWeight: enableWeight}
+	})
+	f, ok := Get("first")
+	if !ok {
+		t.Fatal("Get() ok = false after registered")
+	}
+	if s, ok := f(true).(*firstSelector); !ok || !s.enableWeight {
+		t.Errorf("factory returns %#v, want the registered selector with weight", s)
+	}
+
+	// the later one replaces the former
+	Register("first", func(enableWeight bool) Selector {
+		return &firstSelector{}
+	})
+	f, _ = Get("first")
+	if s := f(true).(*firstSelector); s.enableWeight {
+		t.Error("factory is not replaced by the later Register")
+	}
+}
@@ -37,6 +37,7 @@ type ServantProxy struct {
 	version  int16
 	proto    model.Protocol
 	queueLen int32
+	compress *CompressPolicy
 
 	pushCallback func([]byte)
 }
@@ -63,6 +64,7 @@ func newServantProxy(comm *Communicator, objName string, opts ...EndpointManager
 	if pos > 0 {
 		s.name = s.name[pos+3:]
 	}
+	s.compress = comm.app.clientObjCompress[s.name]
 
 	// init manager
 	s.manager = GetManager(comm, objName, opts...)
@@ -84,6 +86,11 @@ func (s *ServantProxy) TarsSetVersion(iVersion int16) {
 	s.version = iVersion
 }
 
+// TarsSetCompress sets the payload compression, nil to disable it.
+func (s *ServantProxy) TarsSetCompress(p *CompressPolicy) {
+	s.compress = p
+}
+
 // TarsSetProtocol tars set model protocol
 func (s *ServantProxy) TarsSetProtocol(proto model.Protocol) {
 	s.proto = proto
@@ -143,6 +150,14 @@ func (s *ServantProxy) TarsInvoke(ctx context.Context, cType byte,
 		msgType |= basef.TARSMESSAGETYPETRACE
 	}
 
+	// 声明可以接收的压缩算法, 服务端回传其支持的算法后才压缩请求
+	if s.compress.enabled() && s.version == basef.TARSVERSION {
+		if status == nil {
+			status = make(map[string]string)
+		}
+		status[StatusAcceptCompressKey] = strings.Join(s.compress.Algorithms, ",")
+	}
+
 	req := requestf.RequestPacket{
 		IVersion:     s.version,
 		CPacketType:  int8(cType),
@@ -183,22 +198,22 @@ func (s *ServantProxy) TarsInvoke(ctx context.Context, cType byte,
 	s.manager.preInvoke()
 	app := s.comm.app
 	if app.allFilters.cf != nil {
-		err = app.allFilters.cf(ctx, msg, s.doInvoke, timeout)
+		err = app.allFilters.cf(ctx, msg, s.invokeWithRetry, timeout)
 	} else if cf := app.getMiddlewareClientFilter(); cf != nil {
-		err = cf(ctx, msg, s.doInvoke, timeout)
+		err = cf(ctx, msg, s.invokeWithRetry, timeout)
 	} else {
 		// execute pre client filters
 		for i, v := range app.allFilters.preCfs {
-			err = v(ctx, msg, s.doInvoke, timeout)
+			err = v(ctx, msg, s.invokeWithRetry, timeout)
 			if err != nil {
 				TLOG.Errorf("Pre filter error, no: %v, err: %v", i, err.Error())
 			}
 		}
 		// execute rpc
-		err = s.doInvoke(ctx, msg, timeout)
+		err = s.invokeWithRetry(ctx, msg, timeout)
 		// execute post client filters
 		for i, v := range app.allFilters.postCfs {
-			filterErr := v(ctx, msg, s.doInvoke, timeout)
+			filterErr := v(ctx, msg, s.invokeWithRetry, timeout)
 			if filterErr != nil {
 				TLOG.Errorf("Post filter error, no: %v, err: %v", i, filterErr.Error())
 			}
@@ -220,6 +235,7 @@ func (s *ServantProxy) TarsInvoke(ctx context.Context, cType byte,
 	}
 	msg.End()
 	*resp = *msg.Resp
+	s.manager.recordCost(msg.Cost())
 	ReportStat(msg, StatFailed, StatSuccess, StatSuccess)
 	return err
 }
@@ -229,14 +245,23 @@ func (s *ServantProxy) doInvoke(ctx context.Context, msg *Message, timeout time.
 	if adp == nil {
 		return errors.New("no adapter Proxy selected:" + msg.Req.SServantName)
 	}
-	if s.queueLen > adp.comm.Client.ObjQueueMax {
-		return errors.New("invoke queue is full:" + msg.Req.SServantName)
-	}
 	ep := adp.GetPoint()
 	current.SetServerIPWithContext(ctx, ep.Host)
 	current.SetServerPortWithContext(ctx, fmt.Sprintf("%v", ep.Port))
+	return s.invokeAdapter(ctx, msg, adp, needCheck)
+}
+
+// invokeAdapter sends the request to the selected adapter proxy and waits for the response.
+func (s *ServantProxy) invokeAdapter(ctx context.Context, msg *Message, adp *AdapterProxy, needCheck bool) error {
+	if s.queueLen > adp.comm.Client.ObjQueueMax {
+		return errors.New("invoke queue is full:" + msg.Req.SServantName)
+	}
 	msg.Adp = adp
-	adp.servantProxy = s
+	// the adapter proxy is shared by the servant proxies of the obj, it is only set when changed,
+	// so that the responses being received do not race with the requests of the same proxy.
+	if adp.servantProxy != s {
+		adp.servantProxy = s
+	}
 
 	if s.pushCallback != nil {
 		// auto keep alive for push client
@@ -244,26 +269,37 @@ func (s *ServantProxy) doInvoke(ctx context.Context, msg *Message, timeout time.
 		adp.pushCallback = s.pushCallback
 	}
 
+	start := time.Now()
 	atomic.AddInt32(&s.queueLen, 1)
+	atomic.AddInt32(&adp.inflight, 1)
 	readCh := make(chan *requestf.ResponsePacket)
 	adp.resp.Store(msg.Req.IRequestId, readCh)
 	defer func() {
 		CheckPanic()
 		atomic.AddInt32(&s.queueLen, -1)
+		atomic.AddInt32(&adp.inflight, -1)
 		adp.resp.Delete(msg.Req.IRequestId)
 	}()
-	if err := adp.Send(msg.Req); err != nil {
-		adp.failAdd()
+	conn, err := adp.send(s.compressRequest(msg.Req, adp))
+	if err != nil {
+		msg.Status = basef.TARSSENDREQUESTERR
+		adp.failAdd(time.Since(start))
 		return err
 	}
 	if msg.Req.CPacketType == basef.TARSONEWAY {
-		adp.successAdd()
+		adp.successAdd(time.Since(start))
 		return nil
 	}
 	select {
 	case <-ctx.Done():
+		// tell the server to stop handling the request
+		go adp.sendCancel(msg.Req, conn)
+		if atomic.LoadInt32(&msg.hedgeLost) == 1 {
+			// the other hedged request has won, it is not a failure of the adapter.
+			return errHedgeLost
+		}
 		msg.Status = basef.TARSINVOKETIMEOUT
-		adp.failAdd()
+		adp.failAdd(time.Since(start))
 		msg.End()
 		return fmt.Errorf("request timeout, begin time:%d, cost:%d, obj:%s, func:%s, addr:(%s:%d), reqid:%d",
 			msg.BeginTime, msg.Cost(), msg.Req.SServantName, msg.Req.SFuncName, adp.point.Host, adp.point.Port, msg.Req.IRequestId)
@@ -275,7 +311,7 @@ func (s *ServantProxy) doInvoke(ctx context.Context, msg *Message, timeout time.
 				s.manager.addAliveEp(ep)
 			}()
 		}
-		adp.successAdd()
+		adp.successAdd(time.Since(start))
 		if msg.Resp != nil {
 			if msg.Status != basef.TARSSERVERSUCCESS || msg.Resp.IRet != 0 {
 				if msg.Resp.SResultDesc == "" {
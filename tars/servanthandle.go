@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/TarsCloud/TarsGo/tars/transport"
+	"github.com/TarsCloud/TarsGo/tars/util/endpoint"
 )
 
 // AddServant add dispatch and interface for object.
@@ -62,6 +63,15 @@ func (a *application) addServantCommon(v dispatch, f interface{}, obj string, wi
 
 	jp := NewTarsProtocol(v, f, withContext)
 	jp.app = a
+	jp.obj = obj
+	cfg.ConnFilter = a.connFilter(obj)
+	for _, adapter := range a.svrCfg.Adapters {
+		if adapter.Obj == obj {
+			jp.handleTimeout, jp.funcTimeouts = adapter.HandleTimeout, adapter.FuncTimeouts
+			jp.funcPriorities = adapter.FuncPriorities
+			jp.auth = adapter.AuthType == endpoint.AuthTypeLocal
+		}
+	}
 	s := transport.NewTarsServer(jp, cfg)
 	a.goSvrs[obj] = s
 }
@@ -52,6 +52,10 @@ const (
 	ClientDialTimeout = 3000
 	// ObjQueueMax obj queue max number
 	ObjQueueMax int32 = 100000
+	// ZoneMinHealthyPercent min percent of healthy endpoints in the local zone before failing over to other zones
+	ZoneMinHealthyPercent = 50
+	// ClientConnections number of connections to each endpoint
+	ClientConnections = 1
 
 	// log
 	defaultRotateN      = 10
@@ -94,7 +98,22 @@ const (
 	// adapter proxy keepAlive with server ,default value is 0 means close keepAlive. milliseconds
 	keepAliveInterval int = 0
 
-	// try interval after every 30s
+	// retry backoff starts from 10ms and doubles every retry until 200ms.
+	retryBackoff    = 10 * time.Millisecond
+	retryMaxBackoff = 200 * time.Millisecond
+	// retry budget allows 10% retries of requests, and 10 retries per second at least.
+	retryBudgetRatio        float64 = 0.1
+	retryBudgetMinPerSecond int     = 10
+	// retry budget tokens accumulate up to ratio * window.
+	retryBudgetWindow float64 = 1000
+
+	// hedged request is sent after the p95 of the recent 100 call costs, and at least 20 samples are needed.
+	hedgePercentile    float64 = 0.95
+	hedgeMinSamples    int     = 20
+	hedgeCostWindow    int     = 100
+	hedgeCostRecompute int     = 16
+
+	// default circuit breaker, try interval after every 30s
 	tryTimeInterval int64 = 30
 	// failN & failInterval shows how many times fail in the failInterval second,the server will be blocked.
 	fainN        int32 = 5
@@ -106,6 +125,33 @@ const (
 	overN     int32   = 2
 	failRatio float32 = 0.5
 
+	// the blocked endpoint is probed every 5s with 1s timeout, and added back after 3 successes in a row.
+	probeInterval  = 5 * time.Second
+	probeTimeout   = time.Second
+	probeSuccesses = 3
+
+	// the access denied notifications are reported at most 1 per second, with the burst of 10.
+	aclNotifyRate  = 1
+	aclNotifyBurst = 10
+
+	// healthCheckTimeout is the timeout of the health checks of a servant.
+	healthCheckTimeout = time.Second
+
+	// streamRecvWindow is the number of messages which can be received by a stream before granting more.
+	streamRecvWindow int32 = 64
+
+	// selectorP2C is the client config value of <selector> to choose the least loaded endpoint.
+	selectorP2C = "p2c"
+	// load of adapter proxy for p2c selector, a failure is counted as a response of 1s at least.
+	loadEWMAAlpha   float64 = 0.3
+	loadFailPenalty         = time.Second
+
+	// sliding window circuit breaker opens if the error ratio of 10s is over 0.5 with 20 requests at least.
+	breakerWindow      int     = 10000
+	breakerBuckets     int     = 10
+	breakerMinRequests int     = 20
+	breakerErrorRatio  float64 = 0.5
+
 	// tcp network config
 
 	// TCPReadBuffer tcp read buffer length
@@ -117,7 +163,22 @@ const (
 
 	// GracedownTimeout set timeout (milliseconds) for grace shutdown
 	GracedownTimeout = 60000
+	// the timeouts (milliseconds) of the grace shutdown phases
+	NotReadyTimeout   = 1000
+	DeregisterTimeout = 3000
+	// PropagationDelay is the wait (milliseconds) after deregistering, for the clients to refresh the endpoints
+	PropagationDelay  = 0
+	StopAcceptTimeout = 1000
+	DrainTimeout      = 60000
+	DestroyTimeout    = 10000
+
+	// LifecycleHookTimeout set timeout (milliseconds) for every lifecycle hook
+	LifecycleHookTimeout = 10000
 
 	// MaxPackageLength maximum length of the request
 	MaxPackageLength = 10485760
+	// CompressThreshold min length of the payload to compress
+	CompressThreshold = 1024
+	// PriorityMaxSkip number of the requests of higher priorities handled before a waiting one of lower priority
+	PriorityMaxSkip = 16
 )
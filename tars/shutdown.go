@@ -0,0 +1,232 @@
+package tars
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/transport"
+)
+
+// ShutdownPhase is a phase of the graceful shutdown, the phases run in order.
+type ShutdownPhase int32
+
+const (
+	// ShutdownNone means the server is not shutting down.
+	ShutdownNone ShutdownPhase = iota
+	// ShutdownNotReady marks the server not ready, and stops the keep alive to the node.
+	ShutdownNotReady
+	// ShutdownDeregister deregisters the adapters from the registrar.
+	ShutdownDeregister
+	// ShutdownPropagate waits for the propagation delay, so that the clients stop choosing the server.
+	ShutdownPropagate
+	// ShutdownStopAccept closes the listeners and notifies the clients to reconnect.
+	ShutdownStopAccept
+	// ShutdownDrain waits for the in-flight requests and closes the connections.
+	ShutdownDrain
+	// ShutdownDestroy runs the Destroy of the servants.
+	ShutdownDestroy
+
+	shutdownPhaseNum
+)
+
+var shutdownPhaseNames = [shutdownPhaseNum]string{"none", "notready", "deregister", "propagate", "stopaccept", "drain", "destroy"}
+
+func (p ShutdownPhase) String() string {
+	if p < 0 || p >= shutdownPhaseNum {
+		return "unknown"
+	}
+	return shutdownPhaseNames[p]
+}
+
+// ShutdownHook is called in a phase of the graceful shutdown, ctx is done when the phase times out.
+type ShutdownHook func(ctx context.Context) error
+
+// AddShutdownHook adds the hook to the phase of the graceful shutdown.
+// The hooks run after the work of the framework in the phase, except that the hooks of ShutdownPropagate
+// run while waiting for the propagation delay.
+func AddShutdownHook(phase ShutdownPhase, hook ShutdownHook) {
+	defaultApp.AddShutdownHook(phase, hook)
+}
+
+// AddShutdownHook adds the hook to the phase of the graceful shutdown.
+func (a *application) AddShutdownHook(phase ShutdownPhase, hook ShutdownHook) {
+	if phase <= ShutdownNone || phase >= shutdownPhaseNum {
+		TLOG.Errorf("add hook to unknown shutdown phase %d", phase)
+		return
+	}
+	a.shutdownHooksMu.Lock()
+	defer a.shutdownHooksMu.Unlock()
+	a.shutdownHooks[phase] = append(a.shutdownHooks[phase], hook)
+}
+
+// GetShutdownPhase returns the current phase of the graceful shutdown.
+func GetShutdownPhase() ShutdownPhase {
+	return defaultApp.GetShutdownPhase()
+}
+
+// GetShutdownPhase returns the current phase of the graceful shutdown.
+func (a *application) GetShutdownPhase() ShutdownPhase {
+	return ShutdownPhase(atomic.LoadInt32(&a.shutdownPhase))
+}
+
+// IsReady returns whether the server is serving and not shutting down.
+func IsReady() bool {
+	return defaultApp.IsReady()
+}
+
+// IsReady returns whether the server is serving and not shutting down.
+func (a *application) IsReady() bool {
+	return atomic.LoadInt32(&a.ready) == 1
+}
+
+func (a *application) shutdownTimeout(phase ShutdownPhase) time.Duration {
+	switch phase {
+	case ShutdownNotReady:
+		return a.svrCfg.NotReadyTimeout
+	case ShutdownDeregister:
+		return a.svrCfg.DeregisterTimeout
+	case ShutdownPropagate:
+		return a.svrCfg.PropagationDelay
+	case ShutdownStopAccept:
+		return a.svrCfg.StopAcceptTimeout
+	case ShutdownDrain:
+		return a.svrCfg.DrainTimeout
+	case ShutdownDestroy:
+		return a.svrCfg.DestroyTimeout
+	}
+	return 0
+}
+
+// runShutdown runs all the phases of the graceful shutdown, each phase is limited by its own timeout and ctx.
+func (a *application) runShutdown(ctx context.Context) {
+	for phase := ShutdownNotReady; phase < shutdownPhaseNum; phase++ {
+		a.runShutdownPhase(ctx, phase)
+	}
+}
+
+func (a *application) runShutdownPhase(ctx context.Context, phase ShutdownPhase) {
+	timeout := a.shutdownTimeout(phase)
+	atomic.StoreInt32(&a.shutdownPhase, int32(phase))
+	TLOG.Infof("shutdown phase %s start, timeout: %v", phase, timeout)
+	start := time.Now()
+
+	var err error
+	if phase == ShutdownPropagate {
+		// the hooks run while waiting for the delay
+		delay := time.NewTimer(timeout)
+		defer delay.Stop()
+		err = a.runShutdownHooks(ctx, phase)
+		select {
+		case <-delay.C:
+		case <-ctx.Done():
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		err = a.doShutdownPhase(ctx, phase)
+		if hookErr := a.runShutdownHooks(ctx, phase); err == nil {
+			err = hookErr
+		}
+	}
+	if err != nil {
+		TLOG.Errorf("shutdown phase %s failed, cost: %v, err: %v", phase, time.Since(start), err)
+	} else {
+		TLOG.Infof("shutdown phase %s done, cost: %v", phase, time.Since(start))
+	}
+}
+
+func (a *application) runShutdownHooks(ctx context.Context, phase ShutdownPhase) error {
+	a.shutdownHooksMu.RLock()
+	hooks := a.shutdownHooks[phase]
+	a.shutdownHooksMu.RUnlock()
+	var err error
+	for i, hook := range hooks {
+		if hookErr := callHook(ctx, Hook(hook)); hookErr != nil {
+			TLOG.Errorf("shutdown hook No.%d of phase %s error: %v", i, phase, hookErr)
+			err = hookErr
+		}
+	}
+	return err
+}
+
+func (a *application) doShutdownPhase(ctx context.Context, phase ShutdownPhase) error {
+	switch phase {
+	case ShutdownNotReady:
+		atomic.StoreInt32(&a.ready, 0)
+		atomic.StoreInt32(&a.isShutdowning, 1)
+	case ShutdownDeregister:
+		return a.deregisterAdapters(ctx)
+	case ShutdownStopAccept:
+		for _, obj := range a.objRunList {
+			if s, ok := a.goSvrs[obj]; ok {
+				s.StopAccept()
+			}
+		}
+	case ShutdownDrain:
+		return a.drainServers(ctx)
+	case ShutdownDestroy:
+		return a.destroyObjs(ctx)
+	}
+	return nil
+}
+
+// drainServers waits for the in-flight requests of the tars servers, and shuts down the http servers.
+func (a *application) drainServers(ctx context.Context) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(a.objRunList))
+	for _, obj := range a.objRunList {
+		if s, ok := a.httpSvrs[obj]; ok {
+			wg.Add(1)
+			go func(s *http.Server, obj string) {
+				defer wg.Done()
+				if err := s.Shutdown(ctx); err != nil {
+					TLOG.Errorf("grace shutdown http %s failed, err: %v", obj, err)
+					errs <- err
+				} else {
+					TLOG.Infof("grace shutdown http %s success", obj)
+				}
+			}(s, obj)
+		}
+		if s, ok := a.goSvrs[obj]; ok {
+			wg.Add(1)
+			go func(s *transport.TarsServer, obj string) {
+				defer wg.Done()
+				if err := s.Drain(ctx); err != nil {
+					TLOG.Errorf("grace shutdown tars %s failed, num invoke: %d, err: %v", obj, s.NumInvoke(), err)
+					errs <- err
+				} else {
+					TLOG.Infof("grace shutdown tars %s success", obj)
+				}
+			}(s, obj)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// destroyObjs runs the Destroy of the servants, and returns the error of ctx if they are not done in time.
+func (a *application) destroyObjs(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, obj := range a.destroyableObjs {
+		wg.Add(1)
+		go func(obj destroyableImp) {
+			defer wg.Done()
+			obj.Destroy()
+		}(obj)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
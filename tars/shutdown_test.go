@@ -0,0 +1,70 @@
+package tars
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunShutdown(t *testing.T) {
+	app := newApp()
+	app.svrCfg.DeregisterTimeout = 10 * time.Millisecond
+	app.svrCfg.PropagationDelay = 20 * time.Millisecond
+	app.ready = 1
+
+	var phases []ShutdownPhase
+	for phase := ShutdownNotReady; phase < shutdownPhaseNum; phase++ {
+		phase := phase
+		app.AddShutdownHook(phase, func(ctx context.Context) error {
+			if app.GetShutdownPhase() != phase {
+				t.Errorf("hook of %s runs in phase %s", phase, app.GetShutdownPhase())
+			}
+			phases = append(phases, phase)
+			return nil
+		})
+	}
+	var deregisterErr error
+	app.AddShutdownHook(ShutdownDeregister, func(ctx context.Context) error {
+		<-ctx.Done()
+		deregisterErr = ctx.Err()
+		return deregisterErr
+	})
+	app.AddShutdownHook(ShutdownNotReady, func(ctx context.Context) error {
+		if app.IsReady() {
+			t.Error("server is ready in the not ready phase")
+		}
+		return nil
+	})
+
+	start := time.Now()
+	app.runShutdown(context.Background())
+	if cost := time.Since(start); cost < 30*time.Millisecond || cost > time.Second {
+		t.Errorf("shutdown cost %v, want the deregister timeout and the propagation delay", cost)
+	}
+	if deregisterErr != context.DeadlineExceeded {
+		t.Errorf("deregister hook error: %v, want the phase timeout", deregisterErr)
+	}
+	if len(phases) != int(shutdownPhaseNum-1) {
+		t.Fatalf("hooks run in %v", phases)
+	}
+	for i, phase := range phases {
+		if phase != ShutdownPhase(i+1) {
+			t.Errorf("phase No.%d is %s", i, phase)
+		}
+	}
+}
+
+func TestAddShutdownHookWhileShutdown(t *testing.T) {
+	app := newApp()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			app.AddShutdownHook(ShutdownDestroy, func(ctx context.Context) error { return nil })
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		app.runShutdownHooks(context.Background(), ShutdownDestroy)
+	}
+	<-done
+}
@@ -0,0 +1,570 @@
+package tars
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/model"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
+	"github.com/TarsCloud/TarsGo/tars/protocol/res/requestf"
+	"github.com/TarsCloud/TarsGo/tars/util/current"
+	"github.com/TarsCloud/TarsGo/tars/util/tools"
+)
+
+// Stream frames are the normal tars packets with the request id of the stream,
+// and the frame type in the status map. The data frames are ordered by the sequence,
+// because the packets of a connection are handled concurrently.
+const (
+	streamFrameKey  = "TARS_STREAM"
+	streamSeqKey    = "TARS_STREAM_SEQ"
+	streamWindowKey = "TARS_STREAM_WINDOW"
+
+	streamOpen   = "open"   // the first frame from client, with the func name, status and context of the call
+	streamData   = "data"   // a message
+	streamEnd    = "end"    // no more message, the end frame from server carries the result of the call
+	streamWindow = "window" // grants more messages to the peer
+	streamReset  = "reset"  // aborts the stream
+)
+
+var (
+	errStreamWindow = errors.New("stream window exceeded")
+	errStreamClosed = errors.New("stream send closed")
+)
+
+var (
+	_ model.Stream        = (*clientStream)(nil)
+	_ model.Stream        = (*serverStream)(nil)
+	_ model.StreamServant = (*ServantProxy)(nil)
+)
+
+type streamDispatch interface {
+	DispatchStream(context.Context, interface{}, string, model.Stream, bool) error
+}
+
+type streamFrame struct {
+	kind   string
+	seq    int32
+	window int32
+	data   []byte
+	ret    int32
+	desc   string
+}
+
+func parseStreamFrame(status map[string]string, buf []int8) *streamFrame {
+	f := &streamFrame{kind: status[streamFrameKey], data: tools.Int8ToByte(buf)}
+	if v, err := strconv.ParseInt(status[streamSeqKey], 10, 32); err == nil {
+		f.seq = int32(v)
+	}
+	if v, err := strconv.ParseInt(status[streamWindowKey], 10, 32); err == nil {
+		f.window = int32(v)
+	}
+	return f
+}
+
+// status returns the status map of the frame, and copies the call status for the open frame.
+func (f *streamFrame) status(call map[string]string) map[string]string {
+	status := make(map[string]string, len(call)+3)
+	for k, v := range call {
+		status[k] = v
+	}
+	status[streamFrameKey] = f.kind
+	switch f.kind {
+	case streamWindow:
+		status[streamWindowKey] = strconv.Itoa(int(f.window))
+	case streamReset:
+	default:
+		status[streamSeqKey] = strconv.Itoa(int(f.seq))
+	}
+	if f.kind == streamOpen {
+		status[streamWindowKey] = strconv.Itoa(int(f.window))
+	}
+	return status
+}
+
+// streamQuota is the number of messages which can be sent before the peer grants more.
+type streamQuota struct {
+	mu      sync.Mutex
+	n       int32
+	ready   chan struct{}
+	granted chan struct{} // closed when the first window is granted, which means the open frame is handled
+}
+
+func (q *streamQuota) add(n int32) {
+	q.mu.Lock()
+	q.n += n
+	select {
+	case <-q.granted:
+	default:
+		close(q.granted)
+	}
+	q.mu.Unlock()
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *streamQuota) acquire(ctx context.Context, done <-chan struct{}) error {
+	for {
+		q.mu.Lock()
+		if q.n > 0 {
+			q.n--
+			q.mu.Unlock()
+			return nil
+		}
+		q.mu.Unlock()
+		select {
+		case <-q.ready:
+		case <-done:
+			return io.EOF
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// stream is the common part of the client and server streams.
+type stream struct {
+	id     int32
+	method string
+	ctx    context.Context
+	cancel context.CancelFunc
+	write  func(f *streamFrame) error
+	quota  streamQuota
+
+	sendMu     sync.Mutex
+	sendSeq    int32
+	sendClosed bool
+
+	recvMu   sync.Mutex
+	nextSeq  int32
+	pending  map[int32]*streamFrame
+	recvCh   chan *streamFrame
+	consumed int32
+	recvErr  error
+	remote   chan struct{} // closed when the end frame is received by client
+	resetErr error
+
+	finished   int32
+	finishOnce sync.Once
+	onFinish   func()
+}
+
+func newStream(ctx context.Context, id int32, method string) *stream {
+	st := &stream{
+		id:      id,
+		method:  method,
+		pending: make(map[int32]*streamFrame),
+		recvCh:  make(chan *streamFrame, streamRecvWindow+1),
+	}
+	st.ctx, st.cancel = context.WithCancel(ctx)
+	st.quota.ready = make(chan struct{}, 1)
+	st.quota.granted = make(chan struct{})
+	return st
+}
+
+// Context returns the context of the stream.
+func (st *stream) Context() context.Context {
+	return st.ctx
+}
+
+// order returns the frames which can be handled in sequence after receiving f.
+func (st *stream) order(f *streamFrame) []*streamFrame {
+	st.recvMu.Lock()
+	defer st.recvMu.Unlock()
+	if f.seq != st.nextSeq {
+		if f.seq > st.nextSeq {
+			st.pending[f.seq] = f
+		}
+		return nil
+	}
+	frames := []*streamFrame{f}
+	st.nextSeq++
+	for {
+		next, ok := st.pending[st.nextSeq]
+		if !ok {
+			break
+		}
+		delete(st.pending, st.nextSeq)
+		frames = append(frames, next)
+		st.nextSeq++
+	}
+	return frames
+}
+
+// push puts the ordered data or end frame to the receive queue.
+func (st *stream) push(f *streamFrame) {
+	if f.kind == streamEnd && st.remote != nil {
+		select {
+		case <-st.remote:
+		default:
+			close(st.remote)
+		}
+	}
+	select {
+	case st.recvCh <- f:
+	default:
+		// the peer does not respect the window
+		st.abort(basef.TARSSERVERUNKNOWNERR, errStreamWindow.Error())
+	}
+}
+
+// onFrame handles the frame from the peer, and returns the ordered frames which are not handled.
+func (st *stream) onFrame(f *streamFrame) []*streamFrame {
+	switch f.kind {
+	case streamWindow:
+		st.quota.add(f.window)
+		return nil
+	case streamReset:
+		st.recvMu.Lock()
+		st.resetErr = &Error{Code: f.ret, Message: f.desc}
+		st.recvMu.Unlock()
+		st.finish()
+		return nil
+	}
+	var rest []*streamFrame
+	for _, of := range st.order(f) {
+		if of.kind == streamData || of.kind == streamEnd {
+			st.push(of)
+		} else {
+			rest = append(rest, of)
+		}
+	}
+	return rest
+}
+
+func (st *stream) sendFrame(f *streamFrame) error {
+	st.sendMu.Lock()
+	defer st.sendMu.Unlock()
+	if st.sendClosed {
+		return errStreamClosed
+	}
+	if f.kind == streamEnd {
+		st.sendClosed = true
+	}
+	f.seq = st.sendSeq
+	st.sendSeq++
+	return st.write(f)
+}
+
+// SendMsg sends a message to the peer, it returns io.EOF if the peer has finished the stream.
+func (st *stream) SendMsg(buf []byte) error {
+	if err := st.quota.acquire(st.ctx, st.remote); err != nil {
+		return err
+	}
+	return st.sendFrame(&streamFrame{kind: streamData, data: buf})
+}
+
+// RecvMsg receives a message from the peer, it returns io.EOF if the peer has finished sending.
+func (st *stream) RecvMsg() ([]byte, error) {
+	st.recvMu.Lock()
+	err := st.recvErr
+	st.recvMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case f := <-st.recvCh:
+		if f.kind == streamEnd {
+			err = io.EOF
+			if f.ret != basef.TARSSERVERSUCCESS {
+				err = &Error{Code: f.ret, Message: f.desc}
+			}
+			st.recvMu.Lock()
+			st.recvErr = err
+			st.recvMu.Unlock()
+			return nil, err
+		}
+		st.consumed++
+		if st.consumed >= streamRecvWindow/2 {
+			if err = st.write(&streamFrame{kind: streamWindow, window: st.consumed}); err != nil {
+				return nil, err
+			}
+			st.consumed = 0
+		}
+		return f.data, nil
+	case <-st.ctx.Done():
+		st.recvMu.Lock()
+		defer st.recvMu.Unlock()
+		if st.resetErr != nil {
+			return nil, st.resetErr
+		}
+		return nil, st.ctx.Err()
+	}
+}
+
+// abort resets the stream, and tells the peer.
+func (st *stream) abort(code int32, desc string) {
+	if atomic.LoadInt32(&st.finished) == 0 {
+		st.write(&streamFrame{kind: streamReset, ret: code, desc: desc})
+	}
+	st.recvMu.Lock()
+	if st.resetErr == nil {
+		st.resetErr = &Error{Code: code, Message: desc}
+	}
+	st.recvMu.Unlock()
+	st.finish()
+}
+
+func (st *stream) finish() {
+	st.finishOnce.Do(func() {
+		atomic.StoreInt32(&st.finished, 1)
+		st.cancel()
+		if st.onFinish != nil {
+			st.onFinish()
+		}
+	})
+}
+
+// clientStream is the stream of the client, which is finished after receiving the end frame.
+type clientStream struct {
+	*stream
+}
+
+// CloseSend tells the server that no more message will be sent.
+func (cs *clientStream) CloseSend() error {
+	// the frames are sent after the server has handled the open frame
+	select {
+	case <-cs.quota.granted:
+	case <-cs.ctx.Done():
+		return cs.ctx.Err()
+	}
+	return cs.sendFrame(&streamFrame{kind: streamEnd})
+}
+
+// RecvMsg receives a message from the server, it returns io.EOF if the call is finished successfully.
+func (cs *clientStream) RecvMsg() ([]byte, error) {
+	buf, err := cs.stream.RecvMsg()
+	if err != nil && cs.ctx.Err() == nil {
+		// the call is finished
+		cs.finish()
+	}
+	return buf, err
+}
+
+// NewStream starts a stream call of the func on an adapter proxy.
+// The stream is finished when RecvMsg returns an error, or the ctx is done,
+// so the ctx should be canceled if the stream is dropped before that.
+func (s *ServantProxy) NewStream(ctx context.Context, sFuncName string, status map[string]string, reqContext map[string]string) (model.Stream, error) {
+	if reqContext == nil {
+		reqContext = make(map[string]string)
+	}
+	if ctxContext, ok := current.GetRequestContext(ctx); ok {
+		for k, v := range ctxContext {
+			reqContext[k] = v
+		}
+	}
+	req := requestf.RequestPacket{
+		IVersion:     s.version,
+		CPacketType:  basef.TARSNORMAL,
+		IRequestId:   s.genRequestID(),
+		SServantName: s.name,
+		SFuncName:    sFuncName,
+		Status:       status,
+		Context:      reqContext,
+	}
+	msg := &Message{Req: &req, Ser: s}
+	adp, _ := s.manager.SelectAdapterProxy(msg)
+	if adp == nil {
+		return nil, errors.New("no adapter Proxy selected:" + s.name)
+	}
+	// only set when changed like invokeAdapter
+	if adp.servantProxy != s {
+		adp.servantProxy = s
+	}
+	cs := &clientStream{stream: newStream(ctx, req.IRequestId, sFuncName)}
+	cs.remote = make(chan struct{})
+	cs.write = func(f *streamFrame) error {
+		frame := req
+		frame.SBuffer = tools.ByteToInt8(f.data)
+		if f.kind == streamOpen {
+			frame.Status = f.status(status)
+		} else {
+			frame.Status = f.status(nil)
+			frame.Context = nil
+		}
+		return adp.sendFrame(&frame)
+	}
+	cs.onFinish = func() {
+		adp.streams.Delete(cs.id)
+	}
+	adp.streams.Store(cs.id, cs)
+	if err := cs.sendFrame(&streamFrame{kind: streamOpen, window: streamRecvWindow}); err != nil {
+		cs.finish()
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			// the ctx of the caller is done before the call is finished
+			cs.abort(basef.TARSINVOKETIMEOUT, "stream canceled by client")
+		case <-cs.ctx.Done():
+		}
+	}()
+	return cs, nil
+}
+
+// onStreamFrame dispatches the stream frame from server to the stream.
+func (c *AdapterProxy) onStreamFrame(packet *requestf.ResponsePacket) {
+	v, ok := c.streams.Load(packet.IRequestId)
+	if !ok {
+		TLOG.Debugf("stream has been finished, RequestId:%v", packet.IRequestId)
+		return
+	}
+	f := parseStreamFrame(packet.Status, packet.SBuffer)
+	f.ret, f.desc = packet.IRet, packet.SResultDesc
+	v.(*clientStream).onFrame(f)
+}
+
+// closeStreams aborts all the streams of the adapter proxy.
+func (c *AdapterProxy) closeStreams() {
+	c.streams.Range(func(_, v interface{}) bool {
+		v.(*clientStream).abort(basef.TARSPROXYCONNECTERR, "adapter proxy closed")
+		return true
+	})
+}
+
+type serverStreamKey struct {
+	conn net.Conn
+	id   int32
+}
+
+// serverStream is the stream of the server, which is finished when the servant returns.
+type serverStream struct {
+	*stream
+}
+
+// CloseSend does nothing, the stream is closed when the servant returns.
+func (ss *serverStream) CloseSend() error {
+	return nil
+}
+
+// invokeStream handles the stream frame from client, the frames are not responded.
+func (s *Protocol) invokeStream(ctx context.Context, req *requestf.RequestPacket) {
+	current.SetPacketTypeFromContext(ctx, basef.TARSONEWAY)
+	conn, udpAddr, ok := current.GetRawConn(ctx)
+	if !ok || udpAddr != nil {
+		TLOG.Errorf("stream is only supported by tcp, obj:%s, func:%s", req.SServantName, req.SFuncName)
+		return
+	}
+	if !s.allowed(ctx, req) {
+		s.resetStream(ctx, conn, req, basef.TARSSERVERACCESSDENIED, "access denied")
+		return
+	}
+	if !s.authenticated(ctx) {
+		TLOG.Errorf("stream on the connection not authenticated, obj:%s, func:%s", req.SServantName, req.SFuncName)
+		s.resetStream(ctx, conn, req, basef.TARSSERVERACCESSDENIED, "not authenticated")
+		return
+	}
+	f := parseStreamFrame(req.Status, req.SBuffer)
+	key := serverStreamKey{conn: conn, id: req.IRequestId}
+	v, ok := s.streams.Load(key)
+	if !ok {
+		// client sends other frames after the open frame is handled, so they are for finished streams
+		if f.kind != streamOpen {
+			return
+		}
+		v, _ = s.streams.LoadOrStore(key, s.newServerStream(ctx, conn, req))
+	}
+	ss := v.(*serverStream)
+	for _, of := range ss.onFrame(f) {
+		if of.kind == streamOpen {
+			ss.quota.add(of.window)
+			go s.serveStream(ss, req)
+		}
+	}
+}
+
+// resetStream aborts the stream of the frame rejected, so that the client fails fast
+// instead of waiting for the window. The frames of the finished streams are ignored.
+func (s *Protocol) resetStream(ctx context.Context, conn net.Conn, req *requestf.RequestPacket, code int32, desc string) {
+	if v, ok := s.streams.Load(serverStreamKey{conn: conn, id: req.IRequestId}); ok {
+		v.(*serverStream).abort(code, desc)
+		return
+	}
+	if parseStreamFrame(req.Status, req.SBuffer).kind == streamOpen {
+		s.newServerStream(ctx, conn, req).abort(code, desc)
+	}
+}
+
+// detachedContext keeps the values of the packet context, but not the handle timeout.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (s *Protocol) newServerStream(ctx context.Context, conn net.Conn, req *requestf.RequestPacket) *serverStream {
+	ss := &serverStream{stream: newStream(detachedContext{ctx}, req.IRequestId, req.SFuncName)}
+	version := req.IVersion
+	ss.write = func(f *streamFrame) error {
+		rsp := &requestf.ResponsePacket{
+			IVersion:    version,
+			CPacketType: basef.TARSNORMAL,
+			IRequestId:  ss.id,
+			IRet:        f.ret,
+			SResultDesc: f.desc,
+			SBuffer:     tools.ByteToInt8(f.data),
+			Status:      f.status(nil),
+		}
+		_, err := conn.Write(s.rsp2Byte(rsp))
+		return err
+	}
+	key := serverStreamKey{conn: conn, id: req.IRequestId}
+	ss.onFinish = func() {
+		s.streams.Delete(key)
+	}
+	return ss
+}
+
+// serveStream calls the stream method of the servant, and sends the result in the end frame.
+func (s *Protocol) serveStream(ss *serverStream, req *requestf.RequestPacket) {
+	defer CheckPanic()
+	defer ss.finish()
+	begin := time.Now()
+	if s.withContext {
+		current.SetRequestStatus(ss.ctx, req.Status)
+		current.SetRequestContext(ss.ctx, req.Context)
+	}
+	if err := ss.write(&streamFrame{kind: streamWindow, window: streamRecvWindow}); err != nil {
+		TLOG.Errorf("stream grant window error, func:%s, reqId:%d, err: %v", req.SFuncName, req.IRequestId, err)
+		return
+	}
+
+	var err error
+	if sd, ok := s.dispatcher.(streamDispatch); ok {
+		err = sd.DispatchStream(ss.ctx, s.serverImp, req.SFuncName, ss, s.withContext)
+	} else {
+		err = Errorf(basef.TARSSERVERNOFUNCERR, "no stream func: %s", req.SFuncName)
+	}
+	end := &streamFrame{kind: streamEnd}
+	if err != nil {
+		TLOG.Errorf("Stream RequestID:%d, Found err: %v", req.IRequestId, err)
+		end.ret, end.desc = GetErrorCode(err), err.Error()
+	}
+	if ss.ctx.Err() == nil {
+		if err = ss.sendFrame(end); err != nil {
+			TLOG.Errorf("stream send end error, func:%s, reqId:%d, err: %v", req.SFuncName, req.IRequestId, err)
+		}
+	}
+	ReportStatFromServer(req.SFuncName, "stat_from_server", end.ret, time.Since(begin).Milliseconds())
+}
+
+// closeStreams aborts the streams of the connection.
+func (s *Protocol) closeStreams(conn net.Conn) {
+	s.streams.Range(func(k, v interface{}) bool {
+		if k.(serverStreamKey).conn == conn {
+			// the connection is closed, no need to tell client
+			v.(*serverStream).finish()
+		}
+		return true
+	})
+}
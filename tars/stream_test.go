@@ -0,0 +1,91 @@
+package tars
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newStreamPair returns the client and server streams which deliver frames to each other in random order.
+func newStreamPair(ctx context.Context) (*clientStream, *serverStream) {
+	cs := &clientStream{stream: newStream(ctx, 1, "test")}
+	cs.remote = make(chan struct{})
+	ss := &serverStream{stream: newStream(ctx, 1, "test")}
+	deliver := func(to *stream, opened func()) func(f *streamFrame) error {
+		return func(f *streamFrame) error {
+			frame := *f
+			go func() {
+				for _, of := range to.onFrame(&frame) {
+					if of.kind == streamOpen {
+						to.quota.add(of.window)
+						opened()
+					}
+				}
+			}()
+			return nil
+		}
+	}
+	cs.write = deliver(ss.stream, func() {
+		ss.write(&streamFrame{kind: streamWindow, window: streamRecvWindow})
+	})
+	ss.write = deliver(cs.stream, nil)
+	return cs, ss
+}
+
+func TestStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cs, ss := newStreamPair(ctx)
+	if err := cs.sendFrame(&streamFrame{kind: streamOpen, window: streamRecvWindow}); err != nil {
+		t.Fatal(err)
+	}
+
+	// echo server, more messages than the window
+	n := int(streamRecvWindow) * 3
+	go func() {
+		for {
+			buf, err := ss.RecvMsg()
+			if err == io.EOF {
+				ss.sendFrame(&streamFrame{kind: streamEnd})
+				return
+			}
+			if err != nil {
+				ss.sendFrame(&streamFrame{kind: streamEnd, ret: 1, desc: err.Error()})
+				return
+			}
+			if err = ss.SendMsg(buf); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		for i := 0; i < n; i++ {
+			if err := cs.SendMsg([]byte(strconv.Itoa(i))); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		cs.CloseSend()
+	}()
+
+	for i := 0; ; i++ {
+		buf, err := cs.RecvMsg()
+		if err == io.EOF {
+			if i != n {
+				t.Fatalf("received %d messages, want %d", i, n)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != strconv.Itoa(i) {
+			t.Fatalf("message %d = %s, out of order", i, buf)
+		}
+	}
+	if cs.ctx.Err() == nil {
+		t.Fatal("client stream is not finished")
+	}
+}
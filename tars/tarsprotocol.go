@@ -1,9 +1,8 @@
 package tars
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
+	"sync"
 	"time"
 
 	"github.com/TarsCloud/TarsGo/tars/protocol"
@@ -20,9 +19,18 @@ type dispatch interface {
 // Protocol is struct for dispatch with tars protocol.
 type Protocol struct {
 	app         *application
+	obj         string
 	dispatcher  dispatch
 	serverImp   interface{}
 	withContext bool
+	streams     sync.Map
+
+	handleTimeout  time.Duration            // the handler timeout of the servant
+	funcTimeouts   map[string]time.Duration // the handler timeouts of the functions
+	funcPriorities map[string]int           // the priorities of the functions for the priority queues
+	costReports    sync.Map
+	auth           bool     // the connections must be authenticated first
+	authed         sync.Map // the authenticated connections
 }
 
 const (
@@ -41,16 +49,27 @@ func (s *Protocol) Invoke(ctx context.Context, req []byte) (rsp []byte) {
 	defer CheckPanic()
 	reqPackage := requestf.RequestPacket{}
 	rspPackage := requestf.ResponsePacket{}
-	is := codec.NewReader(req[4:])
+	// the request package is not reused by the transport, so the SBuffer refers to it
+	is := codec.NewRefReader(req[4:])
 	reqPackage.ReadFrom(is)
+	if _, ok := reqPackage.Status[streamFrameKey]; ok {
+		s.invokeStream(ctx, &reqPackage)
+		return nil
+	}
+	if reqPackage.CPacketType == basef.TARSONEWAY && reqPackage.SFuncName == cancelFuncName {
+		// the cancel frame is handled by the transport, just ignore it here
+		current.SetPacketTypeFromContext(ctx, basef.TARSONEWAY)
+		return nil
+	}
 
+	// now is the time a worker picks the request up
+	now := time.Now().UnixNano() / 1e6
 	recvPkgTs, ok := current.GetRecvPkgTsFromContext(ctx)
 	if !ok {
-		recvPkgTs = time.Now().UnixNano() / 1e6
+		recvPkgTs = now
 	}
 
 	// timeout delivery
-	now := time.Now().UnixNano() / 1e6
 	if reqPackage.ITimeout > 0 {
 		sub := now - recvPkgTs // coroutine scheduling time difference
 		timeout := int64(reqPackage.ITimeout) - sub
@@ -81,11 +100,13 @@ func (s *Protocol) Invoke(ctx context.Context, req []byte) (rsp []byte) {
 		defer func() {
 			endTime := time.Now().UnixNano() / 1e6
 			ReportStatFromServer(reqPackage.SFuncName, "one_way_client", rspPackage.IRet, endTime-recvPkgTs)
+			s.reportHandleCost(&reqPackage, rspPackage.IRet, now-recvPkgTs, endTime-now)
 		}()
 	} else if reqPackage.CPacketType == basef.TARSNORMAL {
 		defer func() {
 			endTime := time.Now().UnixNano() / 1e6
 			ReportStatFromServer(reqPackage.SFuncName, "stat_from_server", rspPackage.IRet, endTime-recvPkgTs)
+			s.reportHandleCost(&reqPackage, rspPackage.IRet, now-recvPkgTs, endTime-now)
 		}()
 	}
 	// timeout or tars_ping or error
@@ -98,62 +119,98 @@ func (s *Protocol) Invoke(ctx context.Context, req []byte) (rsp []byte) {
 		rspPackage.SResultDesc = "server invoke timeout"
 		ip, _ := current.GetClientIPFromContext(ctx)
 		port, _ := current.GetClientPortFromContext(ctx)
+		if ctx.Err() == context.Canceled {
+			rspPackage.SResultDesc = "request cancelled"
+			TLOG.Debugf("request cancelled in queue, obj:%s, func:%s, addr:(%s:%s), reqId:%d",
+				reqPackage.SServantName, reqPackage.SFuncName, ip, port, reqPackage.IRequestId)
+			break
+		}
 		TLOG.Errorf("handle queue timeout, obj:%s, func:%s, recv time:%d, now:%d, timeout:%d, cost:%d,  addr:(%s:%s), reqId:%d, err: %v",
 			reqPackage.SServantName, reqPackage.SFuncName, recvPkgTs, now, reqPackage.ITimeout, now-recvPkgTs, ip, port, reqPackage.IRequestId, ctx.Err())
 	default:
-		if reqPackage.SFuncName != "tars_ping" { // not tars_ping, normal business call branch
-			if s.withContext {
-				if ok = current.SetRequestStatus(ctx, reqPackage.Status); !ok {
-					TLOG.Error("Set request status in context fail!")
-				}
-				if ok = current.SetRequestContext(ctx, reqPackage.Context); !ok {
-					TLOG.Error("Set request context in context fail!")
-				}
-			}
-			var err error
-			if s.app.allFilters.sf != nil {
-				err = s.app.allFilters.sf(ctx, s.dispatcher.Dispatch, s.serverImp, &reqPackage, &rspPackage, s.withContext)
-			} else if sf := s.app.getMiddlewareServerFilter(); sf != nil {
-				err = sf(ctx, s.dispatcher.Dispatch, s.serverImp, &reqPackage, &rspPackage, s.withContext)
+		if !s.allowed(ctx, &reqPackage) {
+			rspPackage.IRet = basef.TARSSERVERACCESSDENIED
+			rspPackage.SResultDesc = "access denied"
+		} else if reqPackage.SFuncName == authFuncName {
+			s.authenticate(ctx, &reqPackage, &rspPackage)
+		} else if !s.authenticated(ctx) {
+			rspPackage.IRet = basef.TARSSERVERACCESSDENIED
+			rspPackage.SResultDesc = "not authenticated"
+		} else if err := s.decompressRequest(&reqPackage); err != nil {
+			rspPackage.IRet = basef.TARSSERVERDECODEERR
+			rspPackage.SResultDesc = err.Error()
+		} else if _, ok := reqPackage.Status[StatusHealthKey]; ok && reqPackage.SFuncName == "tars_ping" {
+			s.replyHealth(ctx, &reqPackage, &rspPackage)
+		} else if reqPackage.SFuncName != "tars_ping" { // not tars_ping, normal business call branch
+			if timeout := s.funcTimeout(reqPackage.SFuncName); timeout > 0 {
+				s.dispatchWithTimeout(ctx, timeout, &reqPackage, &rspPackage)
 			} else {
-				// execute pre server filters
-				for i, v := range s.app.allFilters.preSfs {
-					err = v(ctx, s.dispatcher.Dispatch, s.serverImp, &reqPackage, &rspPackage, s.withContext)
-					if err != nil {
-						TLOG.Errorf("Pre filter error, No.%v, err: %v", i, err)
-					}
-				}
-				// execute business server
-				err = s.dispatcher.Dispatch(ctx, s.serverImp, &reqPackage, &rspPackage, s.withContext)
-				// execute post server filters
-				for i, v := range s.app.allFilters.postSfs {
-					err = v(ctx, s.dispatcher.Dispatch, s.serverImp, &reqPackage, &rspPackage, s.withContext)
-					if err != nil {
-						TLOG.Errorf("Post filter error, No.%v, err: %v", i, err)
-					}
-				}
-			}
-			if err != nil {
-				TLOG.Errorf("RequestID:%d, Found err: %v", reqPackage.IRequestId, err)
-				rspPackage.IRet = 1
-				rspPackage.SResultDesc = err.Error()
-				if tarsErr, ok := err.(*Error); ok {
-					rspPackage.IRet = tarsErr.Code
-				}
+				s.dispatch(ctx, &reqPackage, &rspPackage)
 			}
 		}
 	}
 
 	// return packet type
 	rspPackage.CPacketType = reqPackage.CPacketType
+	s.compressResponse(&reqPackage, &rspPackage)
 	if ok = current.SetPacketTypeFromContext(ctx, rspPackage.CPacketType); !ok {
 		TLOG.Error("SetPacketType in context fail!")
 	}
 
-	return s.rsp2Byte(&rspPackage)
+	// the pooled buffer is released by the transport after the response is written
+	os := codec.AcquireBuffer()
+	if !current.SetResponseBufferWithContext(ctx, os) {
+		os.Release()
+		return s.rsp2Byte(&rspPackage)
+	}
+	return s.writeResponse(os, &rspPackage)
 }
 
-func (s *Protocol) req2Byte(rsp *requestf.ResponsePacket) []byte {
+// dispatch runs the server filters and the business dispatcher of the request.
+func (s *Protocol) dispatch(ctx context.Context, reqPackage *requestf.RequestPacket, rspPackage *requestf.ResponsePacket) {
+	if s.withContext {
+		if ok := current.SetRequestStatus(ctx, reqPackage.Status); !ok {
+			TLOG.Error("Set request status in context fail!")
+		}
+		if ok := current.SetRequestContext(ctx, reqPackage.Context); !ok {
+			TLOG.Error("Set request context in context fail!")
+		}
+	}
+	var err error
+	if s.app.allFilters.sf != nil {
+		err = s.app.allFilters.sf(ctx, s.dispatcher.Dispatch, s.serverImp, reqPackage, rspPackage, s.withContext)
+	} else if sf := s.app.getMiddlewareServerFilter(); sf != nil {
+		err = sf(ctx, s.dispatcher.Dispatch, s.serverImp, reqPackage, rspPackage, s.withContext)
+	} else {
+		// execute pre server filters
+		for i, v := range s.app.allFilters.preSfs {
+			err = v(ctx, s.dispatcher.Dispatch, s.serverImp, reqPackage, rspPackage, s.withContext)
+			if err != nil {
+				TLOG.Errorf("Pre filter error, No.%v, err: %v", i, err)
+			}
+		}
+		// execute business server
+		err = s.dispatcher.Dispatch(ctx, s.serverImp, reqPackage, rspPackage, s.withContext)
+		// execute post server filters
+		for i, v := range s.app.allFilters.postSfs {
+			err = v(ctx, s.dispatcher.Dispatch, s.serverImp, reqPackage, rspPackage, s.withContext)
+			if err != nil {
+				TLOG.Errorf("Post filter error, No.%v, err: %v", i, err)
+			}
+		}
+	}
+	if err != nil {
+		TLOG.Errorf("RequestID:%d, Found err: %v", reqPackage.IRequestId, err)
+		rspPackage.IRet = 1
+		rspPackage.SResultDesc = err.Error()
+		if tarsErr, ok := err.(*Error); ok {
+			rspPackage.IRet = tarsErr.Code
+		}
+	}
+}
+
+// writeTupResponse writes the response of tup as a request packet.
+func (s *Protocol) writeTupResponse(os *codec.Buffer, rsp *requestf.ResponsePacket) {
 	req := requestf.RequestPacket{}
 	req.IVersion = rsp.IVersion
 	req.IRequestId = rsp.IRequestId
@@ -162,31 +219,22 @@ func (s *Protocol) req2Byte(rsp *requestf.ResponsePacket) []byte {
 	req.Context = rsp.Context
 	req.Status = rsp.Status
 	req.SBuffer = rsp.SBuffer
-
-	os := codec.NewBuffer()
 	req.WriteTo(os)
-	bs := os.ToBytes()
-	sbuf := bytes.NewBuffer(nil)
-	sbuf.Write(make([]byte, 4))
-	sbuf.Write(bs)
-	length := sbuf.Len()
-	binary.BigEndian.PutUint32(sbuf.Bytes(), uint32(length))
-	return sbuf.Bytes()
 }
 
 func (s *Protocol) rsp2Byte(rsp *requestf.ResponsePacket) []byte {
+	return s.writeResponse(codec.NewBuffer(), rsp)
+}
+
+// writeResponse writes the response package to the buffer, and fills the length in place.
+func (s *Protocol) writeResponse(os *codec.Buffer, rsp *requestf.ResponsePacket) []byte {
+	protocol.ReservePackageLength(os)
 	if rsp.IVersion == basef.TUPVERSION {
-		return s.req2Byte(rsp)
+		s.writeTupResponse(os, rsp)
+	} else {
+		rsp.WriteTo(os)
 	}
-	os := codec.NewBuffer()
-	rsp.WriteTo(os)
-	bs := os.ToBytes()
-	sbuf := bytes.NewBuffer(nil)
-	sbuf.Write(make([]byte, 4))
-	sbuf.Write(bs)
-	length := sbuf.Len()
-	binary.BigEndian.PutUint32(sbuf.Bytes(), uint32(length))
-	return sbuf.Bytes()
+	return protocol.PackageBytes(os)
 }
 
 // ParsePackage parse the []byte according to the tars protocol.
@@ -220,4 +268,8 @@ func (s *Protocol) GetCloseMsg() []byte {
 // DoClose be called when close connection
 func (s *Protocol) DoClose(ctx context.Context) {
 	TLOG.Debug("DoClose!")
+	if conn, _, ok := current.GetRawConn(ctx); ok {
+		s.closeStreams(conn)
+		s.authed.Delete(conn)
+	}
 }
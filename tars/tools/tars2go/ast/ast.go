@@ -51,16 +51,23 @@ type Arg struct {
 	Name       string
 	OriginName string //original name
 	IsOut      bool
+	IsStream   bool
 	Type       *VarType
 }
 
 // Func record function information.
 type Func struct {
-	Name       string // after the uppercase converted name
-	OriginName string // original name
-	HasRet     bool
-	RetType    *VarType
-	Args       []Arg
+	Name        string // after the uppercase converted name
+	OriginName  string // original name
+	HasRet      bool
+	RetType     *VarType
+	IsRetStream bool
+	Args        []Arg
+}
+
+// IsStream returns whether the function is a stream call.
+func (fun *Func) IsStream() bool {
+	return fun.IsRetStream || (len(fun.Args) > 0 && fun.Args[0].IsStream)
 }
 
 // Interface record interface information.
@@ -272,6 +272,9 @@ func (g *GenGo) genIFPackage(itf *ast.Interface) {
 	g.P(strconv.Quote("context"))
 	g.P(strconv.Quote("encoding/json"))
 	g.P(strconv.Quote("fmt"))
+	if hasStreamFun(itf) {
+		g.P(strconv.Quote("io"))
+	}
 	//g.P(strconv.Quote("unsafe"))
 	g.P()
 
@@ -310,6 +313,9 @@ func (g *GenGo) genIFPackage(itf *ast.Interface) {
 	g.P("	_ = codec.FromInt8")
 	//g.P("	_ = unsafe.Pointer(nil)")
 	g.P("	_ = bytes.ErrTooLarge")
+	if hasStreamFun(itf) {
+		g.P("	_ = io.EOF")
+	}
 	g.P(")")
 }
 
@@ -976,6 +982,8 @@ func (g *GenGo) genInterface(itf *ast.Interface) {
 
 	g.genIFDispatch(itf)
 
+	g.genIFStream(itf)
+
 	g.saveToSourceFile(itf.Name + ".tars.go")
 }
 
@@ -1030,6 +1038,10 @@ func (obj *`, itf.Name, `) AddServantWithContext(imp `, itf.Name, `ServantWithCo
 	}
 
 	for _, v := range itf.Funcs {
+		if v.IsStream() {
+			g.genIFProxyStreamFun(itf.Name, &v)
+			continue
+		}
 		g.genIFProxyFun(itf.Name, &v, false, false)
 		g.genIFProxyFun(itf.Name, &v, true, false)
 		g.genIFProxyFun(itf.Name, &v, true, true)
@@ -1257,6 +1269,10 @@ func (g *GenGo) genCallArgs(args []ast.Arg) {
 func (g *GenGo) genIFServer(itf *ast.Interface) {
 	g.P("type ", itf.Name, "Servant interface {")
 	for _, v := range itf.Funcs {
+		if v.IsStream() {
+			g.genIFServerStreamFun(itf.Name, &v, false)
+			continue
+		}
 		g.genIFServerFun(&v)
 	}
 	g.P("}")
@@ -1265,6 +1281,10 @@ func (g *GenGo) genIFServer(itf *ast.Interface) {
 func (g *GenGo) genIFServerWithContext(itf *ast.Interface) {
 	g.P("type ", itf.Name, "ServantWithContext interface {")
 	for _, v := range itf.Funcs {
+		if v.IsStream() {
+			g.genIFServerStreamFun(itf.Name, &v, true)
+			continue
+		}
 		g.genIFServerFunWithContext(&v)
 	}
 	g.P("}")
@@ -1303,7 +1323,7 @@ func (g *GenGo) genIFDispatch(itf *ast.Interface) {
 
 	var param bool
 	for _, v := range itf.Funcs {
-		if len(v.Args) > 0 {
+		if len(v.Args) > 0 && !v.IsStream() {
 			param = true
 			break
 		}
@@ -1317,6 +1337,10 @@ func (g *GenGo) genIFDispatch(itf *ast.Interface) {
 	g.P(`buf := codec.NewBuffer()
 	switch tarsReq.SFuncName {`)
 	for _, v := range itf.Funcs {
+		if v.IsStream() {
+			// stream methods are dispatched by DispatchStream
+			continue
+		}
 		g.genSwitchCase(itf.Name, &v)
 	}
 
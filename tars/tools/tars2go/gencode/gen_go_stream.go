@@ -0,0 +1,290 @@
+package gencode
+
+import (
+	"strconv"
+
+	"github.com/TarsCloud/TarsGo/tars/tools/tars2go/ast"
+	"github.com/TarsCloud/TarsGo/tars/tools/tars2go/token"
+)
+
+func hasStreamFun(itf *ast.Interface) bool {
+	for _, v := range itf.Funcs {
+		if v.IsStream() {
+			return true
+		}
+	}
+	return false
+}
+
+// streamMsgType returns the go type of the stream message, structs are passed by pointer.
+func (g *GenGo) streamMsgType(ty *ast.VarType) string {
+	if ty.CType == token.Struct {
+		return "*" + g.genType(ty)
+	}
+	return g.genType(ty)
+}
+
+// genStreamSend generates the method which encodes and sends a message.
+func (g *GenGo) genStreamSend(typeName, method string, ty *ast.VarType) {
+	g.P("// ", method, " sends a message to the stream.")
+	g.P("func (s *", typeName, ") ", method, "(m ", g.streamMsgType(ty), ") (err error) {")
+	g.P("buf := codec.NewBuffer()")
+	dummy := &ast.StructMember{
+		Tag:     1,
+		Require: true,
+		Type:    ty,
+		Key:     "m",
+	}
+	if ty.CType == token.Struct {
+		dummy.Key = "(*m)"
+	}
+	g.genWriteVar(dummy, "", false)
+	g.P("return s.stream.SendMsg(buf.ToBytes())")
+	g.P("}")
+}
+
+// genStreamRecv generates the method which receives and decodes a message.
+func (g *GenGo) genStreamRecv(typeName, method string, ty *ast.VarType) {
+	g.P("// ", method, " receives a message from the stream, it returns io.EOF when the peer has finished sending.")
+	g.P("func (s *", typeName, ") ", method, "() (ret ", g.genType(ty), ", err error) {")
+	g.P(`var (
+		length int32
+		have bool
+		ty byte
+	)
+	data, err := s.stream.RecvMsg()
+	if err != nil {
+		return ret, err
+	}
+	readBuf := codec.NewReader(data)`)
+	dummy := &ast.StructMember{
+		Tag:     1,
+		Require: true,
+		Type:    ty,
+		Key:     "ret",
+	}
+	g.genReadVar(dummy, "", true)
+	g.P(`_ = length
+	_ = have
+	_ = ty
+	return ret, nil
+}`)
+}
+
+// genStreamType generates the typed stream handle.
+func (g *GenGo) genStreamType(typeName, comment string) {
+	g.P("// ", typeName, " is the ", comment)
+	g.P("type ", typeName, ` struct {
+	stream model.Stream
+}`)
+	g.P(`// Context returns the context of the stream.
+func (s *`, typeName, `) Context() context.Context {
+	return s.stream.Context()
+}`)
+}
+
+func (g *GenGo) genIFStreamClient(itf *ast.Interface, fun *ast.Func) {
+	arg := fun.Args[0]
+	typeName := itf.Name + fun.Name + "Client"
+	g.genStreamType(typeName, "client stream of "+itf.Name+"."+fun.Name+".")
+	if arg.IsStream {
+		g.genStreamSend(typeName, "Send", arg.Type)
+	} else {
+		g.genStreamSend(typeName, "send", arg.Type)
+	}
+	if fun.IsRetStream {
+		g.genStreamRecv(typeName, "Recv", fun.RetType)
+	} else if fun.HasRet {
+		g.genStreamRecv(typeName, "recv", fun.RetType)
+	}
+	if !arg.IsStream {
+		return
+	}
+	if fun.IsRetStream {
+		g.P(`// CloseSend tells the server that no more message will be sent.
+func (s *`, typeName, `) CloseSend() error {
+	return s.stream.CloseSend()
+}`)
+		return
+	}
+
+	g.P("// CloseAndRecv tells the server that no more message will be sent, and receives the result.")
+	if fun.HasRet {
+		g.P("func (s *", typeName, ") CloseAndRecv() (ret ", g.genType(fun.RetType), ", err error) {")
+		g.P(`if err = s.stream.CloseSend(); err != nil {
+		return ret, err
+	}
+	if ret, err = s.recv(); err != nil {
+		return ret, err
+	}`)
+	} else {
+		g.P("func (s *", typeName, ") CloseAndRecv() (err error) {")
+		g.P(`if err = s.stream.CloseSend(); err != nil {
+		return err
+	}`)
+	}
+	g.P(`// wait for the end of the call
+	if _, err = s.stream.RecvMsg(); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("unexpected stream message")
+		}`)
+	if fun.HasRet {
+		g.P(`return ret, err
+	}
+	return ret, nil
+}`)
+	} else {
+		g.P(`return err
+	}
+	return nil
+}`)
+	}
+}
+
+func (g *GenGo) genIFStreamServer(itf *ast.Interface, fun *ast.Func) {
+	arg := fun.Args[0]
+	typeName := itf.Name + fun.Name + "Server"
+	g.genStreamType(typeName, "server stream of "+itf.Name+"."+fun.Name+".")
+	if arg.IsStream {
+		g.genStreamRecv(typeName, "Recv", arg.Type)
+	} else {
+		g.genStreamRecv(typeName, "recv", arg.Type)
+	}
+	if fun.IsRetStream {
+		g.genStreamSend(typeName, "Send", fun.RetType)
+	} else if fun.HasRet {
+		g.genStreamSend(typeName, "send", fun.RetType)
+	}
+}
+
+// genIFProxyStreamFun generates the proxy functions of the stream method, which return the client stream.
+func (g *GenGo) genIFProxyStreamFun(interfName string, fun *ast.Func) {
+	arg := fun.Args[0]
+	typeName := interfName + fun.Name + "Client"
+	g.P("// ", fun.Name, " is the proxy function for the stream method defined in the tars file")
+	g.W("func (obj *", interfName, ") ", fun.Name, "(")
+	if !arg.IsStream {
+		g.genArgs(fun.Args)
+	}
+	g.P(" opts ...map[string]string) (*", typeName, ", error) {")
+	g.W("return obj.", fun.Name, "WithContext(context.Background(), ")
+	if !arg.IsStream {
+		g.W(arg.Name, ", ")
+	}
+	g.P("opts...)")
+	g.P("}")
+
+	g.P("// ", fun.Name, "WithContext is the proxy function for the stream method defined in the tars file, with the context")
+	g.W("func (obj *", interfName, ") ", fun.Name, "WithContext(tarsCtx context.Context, ")
+	if !arg.IsStream {
+		g.genArgs(fun.Args)
+	}
+	g.P(" opts ...map[string]string) (*", typeName, ", error) {")
+	g.P(`servant, ok := obj.servant.(model.StreamServant)
+	if !ok {
+		return nil, fmt.Errorf("servant does not support stream")
+	}
+	var statusMap map[string]string
+	var contextMap map[string]string
+	if len(opts) == 1 {
+		contextMap = opts[0]
+	} else if len(opts) == 2 {
+		contextMap = opts[0]
+		statusMap = opts[1]
+	}`)
+	g.P("stream, err := servant.NewStream(tarsCtx, ", strconv.Quote(fun.OriginName), ", statusMap, contextMap)")
+	g.P(`if err != nil {
+		return nil, err
+	}`)
+	g.P("client := &", typeName, "{stream: stream}")
+	if !arg.IsStream {
+		g.P("if err = client.send(", arg.Name, "); err != nil {")
+		g.P(`return nil, err
+	}
+	if err = stream.CloseSend(); err != nil {
+		return nil, err
+	}`)
+	}
+	g.P("return client, nil")
+	g.P("}")
+}
+
+func (g *GenGo) genIFServerStreamFun(interfName string, fun *ast.Func, withContext bool) {
+	g.W(fun.Name, "(")
+	if withContext {
+		g.W("tarsCtx context.Context, ")
+	}
+	if !fun.Args[0].IsStream {
+		g.genArgs(fun.Args)
+	}
+	g.W("stream *", interfName, fun.Name, "Server) (")
+	if fun.HasRet && !fun.IsRetStream {
+		g.W("ret ", g.genType(fun.RetType), ", ")
+	}
+	g.P("err error)")
+}
+
+// genIFStream generates the stream handles and the stream dispatch of the interface.
+func (g *GenGo) genIFStream(itf *ast.Interface) {
+	if !hasStreamFun(itf) {
+		return
+	}
+	for _, v := range itf.Funcs {
+		if v.IsStream() {
+			g.genIFStreamClient(itf, &v)
+			g.genIFStreamServer(itf, &v)
+		}
+	}
+
+	g.P("// DispatchStream is used to call the server side implement for the stream method defined in the tars file. withContext shows using context or not.")
+	g.P("func (obj *", itf.Name, `) DispatchStream(tarsCtx context.Context, val interface{}, funcName string, stream model.Stream, withContext bool) (err error) {
+	switch funcName {`)
+	for _, v := range itf.Funcs {
+		if !v.IsStream() {
+			continue
+		}
+		arg := v.Args[0]
+		g.P("case ", strconv.Quote(v.OriginName), ":")
+		g.P("tarsStream := &", itf.Name, v.Name, "Server{stream: stream}")
+		callArgs := "tarsStream"
+		if !arg.IsStream {
+			g.P("var ", arg.Name, " ", g.genType(arg.Type))
+			g.P(`if `, arg.Name, `, err = tarsStream.recv(); err != nil {
+		return err
+	}`)
+			if arg.Type.CType == token.Struct {
+				callArgs = "&" + arg.Name + ", " + callArgs
+			} else {
+				callArgs = arg.Name + ", " + callArgs
+			}
+		}
+		funRet := "err"
+		if v.HasRet && !v.IsRetStream {
+			g.P("var funRet ", g.genType(v.RetType))
+			funRet = "funRet, err"
+		}
+		g.P("if !withContext {")
+		g.P("imp := val.(", itf.Name, "Servant)")
+		g.P(funRet, " = imp.", v.Name, "(", callArgs, ")")
+		g.P("} else {")
+		g.P("imp := val.(", itf.Name, "ServantWithContext)")
+		g.P(funRet, " = imp.", v.Name, "(tarsCtx, ", callArgs, ")")
+		g.P("}")
+		if v.HasRet && !v.IsRetStream {
+			g.P(`if err != nil {
+		return err
+	}`)
+			if v.RetType.CType == token.Struct {
+				g.P("return tarsStream.send(&funRet)")
+			} else {
+				g.P("return tarsStream.send(funRet)")
+			}
+		} else {
+			g.P("return err")
+		}
+	}
+	g.P(`default:
+		return fmt.Errorf("func mismatch")
+	}
+}`)
+}
@@ -0,0 +1,126 @@
+package gencode
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	gotoken "go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TarsCloud/TarsGo/tars/tools/tars2go/options"
+	"github.com/TarsCloud/TarsGo/tars/tools/tars2go/parse"
+)
+
+// genSource generates the go code of the tars source, and returns the declarations in the generated files.
+func genSource(t *testing.T, name, src string) map[string]bool {
+	dir := t.TempDir()
+	path := filepath.Join(dir, name+".tars")
+	if err := os.WriteFile(path, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	opt := &options.Options{
+		TarsPath:   "github.com/TarsCloud/TarsGo/tars",
+		Outdir:     filepath.Join(dir, "out"),
+		AddServant: true,
+	}
+	g := NewGenGo(opt, path)
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%v", r)
+			}
+		}()
+		g.tarsFile = parse.NewParse(opt, path, nil)
+		g.genAll()
+		return nil
+	}()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decls := make(map[string]bool)
+	fset := gotoken.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, filepath.Join(dir, "out", name), nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, d := range f.Decls {
+				switch d := d.(type) {
+				case *ast.FuncDecl:
+					if d.Recv != nil {
+						recv := d.Recv.List[0].Type
+						if star, ok := recv.(*ast.StarExpr); ok {
+							recv = star.X
+						}
+						decls[recv.(*ast.Ident).Name+"."+d.Name.Name] = true
+					} else {
+						decls[d.Name.Name] = true
+					}
+				case *ast.GenDecl:
+					for _, s := range d.Specs {
+						if ts, ok := s.(*ast.TypeSpec); ok {
+							decls[ts.Name.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	return decls
+}
+
+func TestGenStream(t *testing.T) {
+	decls := genSource(t, "StreamTest", `
+module StreamTest {
+	struct Msg {
+		0 require string s;
+	};
+	interface Chat {
+		stream Msg ServerStream(Msg req);
+		Msg ClientStream(stream Msg req);
+		stream Msg BidiStream(stream Msg req);
+		int Echo(int n, out int m);
+	};
+};`)
+	for _, name := range []string{
+		"Chat.ServerStream",
+		"Chat.ClientStream",
+		"Chat.BidiStream",
+		"Chat.Echo",
+		"ChatServerStreamClient.Recv",
+		"ChatClientStreamClient.Send",
+		"ChatClientStreamClient.CloseAndRecv",
+		"ChatBidiStreamClient.Send",
+		"ChatBidiStreamClient.Recv",
+		"ChatBidiStreamClient.CloseSend",
+	} {
+		if !decls[name] {
+			t.Errorf("%s is not generated", name)
+		}
+	}
+}
+
+func TestGenStreamIdentifier(t *testing.T) {
+	decls := genSource(t, "IdentTest", `
+module IdentTest {
+	struct stream {
+		0 require int stream;
+	};
+	interface Old {
+		stream get(int stream);
+		void set(stream stream, out stream old);
+	};
+};`)
+	for _, name := range []string{"Stream", "Old.Get", "Old.Set"} {
+		if !decls[name] {
+			t.Errorf("%s is not generated", name)
+		}
+	}
+	if decls["OldGetClient"] {
+		t.Error("stream client is generated for the function returning the struct named stream")
+	}
+}
@@ -19,10 +19,11 @@ import (
 type Parse struct {
 	opt *options.Options
 
-	lex      *lexer.LexState
-	tk       *token.Token
-	lastTk   *token.Token
-	tarsFile *ast.TarsFile
+	lex       *lexer.LexState
+	tk        *token.Token
+	lastTk    *token.Token
+	lookahead []*token.Token
+	tarsFile  *ast.TarsFile
 
 	// jce include chain
 	IncChain            []string
@@ -89,9 +90,41 @@ func (p *Parse) parseErr(err string) {
 
 func (p *Parse) next() {
 	p.lastTk = p.tk
+	if len(p.lookahead) > 0 {
+		p.tk = p.lookahead[0]
+		p.lookahead = p.lookahead[1:]
+		return
+	}
 	p.tk = p.lex.NextToken()
 }
 
+// peek returns the nth token after the current one without consuming it.
+func (p *Parse) peek(n int) *token.Token {
+	for len(p.lookahead) < n {
+		p.lookahead = append(p.lookahead, p.lex.NextToken())
+	}
+	return p.lookahead[n-1]
+}
+
+// streamModifier is the modifier of the stream arguments and return values of the interface functions.
+// It is not a keyword, so that stream can still be the name of the types, fields and arguments.
+const streamModifier = "stream"
+
+// isStreamModifier returns whether the current token is the stream modifier, rather than the type named stream.
+// The modifier is followed by a type and a name, such as "stream Msg msg" or "stream int",
+// while the type named stream is followed by a name only, such as "stream s". So the stream argument
+// of a struct type must be named, "stream Msg" is the argument named Msg of the type stream.
+func (p *Parse) isStreamModifier() bool {
+	if p.tk.T != token.Name || p.tk.S.S != streamModifier {
+		return false
+	}
+	next := p.peek(1)
+	if token.IsType(next.T) || next.T == token.Unsigned || next.T == token.Void {
+		return true
+	}
+	return next.T == token.Name && p.peek(2).T == token.Name
+}
+
 func (p *Parse) expect(t token.Type) {
 	p.next()
 	if p.tk.T != t {
@@ -336,6 +369,13 @@ func (p *Parse) parseInterfaceFun() *ast.Func {
 	if p.tk.T == token.BraceRight {
 		return nil
 	}
+	if p.isStreamModifier() {
+		fun.IsRetStream = true
+		p.next()
+		if p.tk.T == token.Void {
+			p.parseErr("stream of void")
+		}
+	}
 	if p.tk.T == token.Void {
 		fun.HasRet = false
 	} else if !token.IsType(p.tk.T) && p.tk.T != token.Name && p.tk.T != token.Unsigned {
@@ -355,6 +395,9 @@ func (p *Parse) parseInterfaceFun() *ast.Func {
 
 	// No parameter function, exit directly.
 	if p.tk.T == token.Ptr {
+		if fun.IsRetStream {
+			p.parseErr("stream function requires one argument")
+		}
 		p.expect(token.Semi)
 		return fun
 	}
@@ -367,6 +410,13 @@ func (p *Parse) parseInterfaceFun() *ast.Func {
 		} else {
 			arg.IsOut = false
 		}
+		if p.isStreamModifier() {
+			if arg.IsOut {
+				p.parseErr("stream argument can not be out")
+			}
+			arg.IsStream = true
+			p.next()
+		}
 
 		arg.Type = p.parseType()
 		p.next()
@@ -376,6 +426,9 @@ func (p *Parse) parseInterfaceFun() *ast.Func {
 		}
 
 		fun.Args = append(fun.Args, *arg)
+		if (fun.IsStream() || arg.IsStream) && (len(fun.Args) > 1 || arg.IsOut) {
+			p.parseErr("stream function requires one argument")
+		}
 
 		if p.tk.T == token.Comma {
 			p.next()
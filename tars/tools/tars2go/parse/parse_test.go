@@ -0,0 +1,139 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/TarsCloud/TarsGo/tars/tools/tars2go/ast"
+	"github.com/TarsCloud/TarsGo/tars/tools/tars2go/options"
+)
+
+func parseSource(src string) (f *ast.TarsFile, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	p := newParse(&options.Options{}, "Test.tars", []byte(src), nil)
+	p.parse()
+	return p.tarsFile, nil
+}
+
+func findFunc(f *ast.TarsFile, name string) *ast.Func {
+	for _, itf := range f.Module.Interface {
+		for i := range itf.Funcs {
+			if itf.Funcs[i].Name == name {
+				return &itf.Funcs[i]
+			}
+		}
+	}
+	return nil
+}
+
+func TestParseStream(t *testing.T) {
+	f, err := parseSource(`
+module Test {
+	struct Msg {
+		0 require string s;
+	};
+	interface Chat {
+		stream Msg ServerStream(Msg req);
+		Msg ClientStream(stream Msg req);
+		stream Msg BidiStream(stream Msg req);
+		stream vector<int> Ints(stream unsigned int n);
+		void Notify(stream int);
+	};
+};`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name      string
+		retStream bool
+		argStream bool
+	}{
+		{"ServerStream", true, false},
+		{"ClientStream", false, true},
+		{"BidiStream", true, true},
+		{"Ints", true, true},
+		{"Notify", false, true},
+	}
+	for _, c := range cases {
+		fun := findFunc(f, c.name)
+		if fun == nil {
+			t.Errorf("function %s not found", c.name)
+			continue
+		}
+		if fun.IsRetStream != c.retStream || len(fun.Args) != 1 || fun.Args[0].IsStream != c.argStream {
+			t.Errorf("%s: stream return %v, args %+v", c.name, fun.IsRetStream, fun.Args)
+		}
+	}
+}
+
+func TestParseStreamIdentifier(t *testing.T) {
+	f, err := parseSource(`
+module Test {
+	struct stream {
+		0 require int stream;
+	};
+	struct Holder {
+		0 require stream stream;
+		1 optional vector<stream> streams;
+	};
+	interface Old {
+		stream get(int stream);
+		void set(stream stream, out stream old);
+		int stream(stream s);
+	};
+};`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := f.Module.Struct; len(st) != 2 || st[0].Name != "stream" || st[1].Mb[0].Key != "stream" {
+		t.Errorf("structs using stream as the names: %+v", st)
+	}
+	for _, name := range []string{"get", "set", "stream"} {
+		fun := findFunc(f, name)
+		if fun == nil {
+			t.Errorf("function %s not found", name)
+			continue
+		}
+		if fun.IsStream() {
+			t.Errorf("%s is parsed as a stream function", name)
+		}
+	}
+	if fun := findFunc(f, "get"); fun != nil && (fun.RetType.TypeSt != "stream" || fun.Args[0].Name != "stream") {
+		t.Errorf("get returns %+v with args %+v", fun.RetType, fun.Args)
+	}
+	if fun := findFunc(f, "set"); fun != nil && (len(fun.Args) != 2 || fun.Args[0].Name != "stream" || fun.Args[1].Type.TypeSt != "stream") {
+		t.Errorf("set args %+v", fun.Args)
+	}
+}
+
+func TestParseStreamError(t *testing.T) {
+	cases := []struct {
+		fun string
+		err string
+	}{
+		{"stream void Foo(Msg req);", "stream of void"},
+		{"stream Msg Foo();", "stream function requires one argument"},
+		{"Msg Foo(stream Msg a, Msg b);", "stream function requires one argument"},
+		{"Msg Foo(Msg a, stream Msg b);", "stream function requires one argument"},
+		{"Msg Foo(out stream Msg a);", "stream argument can not be out"},
+	}
+	for _, c := range cases {
+		_, err := parseSource(`
+module Test {
+	struct Msg {
+		0 require string s;
+	};
+	interface Chat {
+		` + c.fun + `
+	};
+};`)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: error %v, want %q", c.fun, err, c.err)
+		}
+	}
+}
@@ -40,9 +40,45 @@ func newTarsServerConf(proto, address string, svrCfg *serverConfig, opts ...Serv
 		TCPNoDelay:     svrCfg.TCPNoDelay,
 		TCPReadBuffer:  svrCfg.TCPReadBuffer,
 		TCPWriteBuffer: svrCfg.TCPWriteBuffer,
+		MaxConns:       svrCfg.MaxConns,
+		MaxConnsPerIP:  svrCfg.MaxConnsPerIP,
+		AcceptRate:     svrCfg.AcceptRate,
+		AcceptBurst:    svrCfg.AcceptBurst,
 	}
 	for _, opt := range opts {
 		opt(tarsSvrConf)
 	}
 	return tarsSvrConf
 }
+
+// WithPriorityQueue queues the requests by priority, maxSkip requests of higher priorities can be handled
+// before a waiting request of lower priority.
+func WithPriorityQueue(maxSkip int) ServerConfOption {
+	return func(c *transport.TarsServerConf) {
+		c.Priorities = priorityNum
+		c.PriorityMaxSkip = maxSkip
+	}
+}
+
+// WithHandler sets the handler of the tcp connections, such as transport.HandlerEpoll.
+func WithHandler(handler string) ServerConfOption {
+	return func(c *transport.TarsServerConf) {
+		c.Handler = handler
+	}
+}
+
+// WithConnLimit limits the connections in total and per client ip, zero for no limit.
+func WithConnLimit(maxConns, maxConnsPerIP int) ServerConfOption {
+	return func(c *transport.TarsServerConf) {
+		c.MaxConns = maxConns
+		c.MaxConnsPerIP = maxConnsPerIP
+	}
+}
+
+// WithAcceptRate limits the connections accepted per second, zero for no limit.
+func WithAcceptRate(rate float64, burst int) ServerConfOption {
+	return func(c *transport.TarsServerConf) {
+		c.AcceptRate = rate
+		c.AcceptBurst = burst
+	}
+}
@@ -14,6 +14,10 @@ const (
 	PackageError
 )
 
+// HandlerEpoll is the server handler which reads the connections when they are readable by epoll,
+// instead of a goroutine per connection, so that the idle connections cost little memory. Linux only.
+const HandlerEpoll = "epoll"
+
 // ServerHandler is interface with listen and handler method
 type ServerHandler interface {
 	Listen() error
@@ -31,6 +35,18 @@ type ServerProtocol interface {
 	DoClose(ctx context.Context)
 }
 
+// CancelProtocol is implemented by the server protocol which supports cancelling the in-flight requests.
+type CancelProtocol interface {
+	// ParseRequest returns the request id of the package, and whether the package is a cancel frame.
+	ParseRequest(pkg []byte) (reqID int32, isCancel bool)
+}
+
+// PriorityProtocol is implemented by the server protocol which classifies the requests for the priority queues.
+type PriorityProtocol interface {
+	// Priority returns the priority of the package, from 0 to TarsServerConf.Priorities-1, the higher the more urgent.
+	Priority(pkg []byte) int
+}
+
 // ClientProtocol interface for handling tars client package.
 type ClientProtocol interface {
 	Recv(pkg []byte)
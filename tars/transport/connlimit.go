@@ -0,0 +1,197 @@
+package transport
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TarsCloud/TarsGo/tars/ratelimit"
+)
+
+// maxAcceptWait is the longest sleep while waiting for the accept token, so that the closing server is noticed.
+const maxAcceptWait = 100 * time.Millisecond
+
+// connLimiter limits the connections of the server in total and per client ip, and throttles the accepting.
+type connLimiter struct {
+	maxConns      int
+	maxConnsPerIP int
+	acceptRate    float64
+	accept        *ratelimit.TokenBucket
+
+	lock     sync.Mutex
+	numConns int
+	ipConns  map[string]int
+	rejected uint64
+}
+
+// newConnLimiter returns nil if none of the limits is configured.
+func newConnLimiter(cfg *TarsServerConf) *connLimiter {
+	if cfg.MaxConns <= 0 && cfg.MaxConnsPerIP <= 0 && cfg.AcceptRate <= 0 {
+		return nil
+	}
+	l := &connLimiter{
+		maxConns:      cfg.MaxConns,
+		maxConnsPerIP: cfg.MaxConnsPerIP,
+		acceptRate:    cfg.AcceptRate,
+		ipConns:       make(map[string]int),
+	}
+	if cfg.AcceptRate > 0 {
+		l.accept = ratelimit.NewTokenBucket(cfg.AcceptRate, cfg.AcceptBurst)
+	}
+	return l
+}
+
+// waitAccept waits for the token of the accept rate, it returns early once the server is closed.
+func (l *connLimiter) waitAccept(isClosed *int32) {
+	if l == nil || l.accept == nil {
+		return
+	}
+	wait := time.Duration(float64(time.Second) / l.acceptRate)
+	if wait > maxAcceptWait {
+		wait = maxAcceptWait
+	}
+	for !l.accept.Allow(time.Now()) {
+		if atomic.LoadInt32(isClosed) == 1 {
+			return
+		}
+		time.Sleep(wait)
+	}
+}
+
+// acquire counts the accepted connection, it returns false if the connection exceeds the limits.
+func (l *connLimiter) acquire(conn net.Conn) bool {
+	if l == nil {
+		return true
+	}
+	ip := connIP(conn)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.maxConns > 0 && l.numConns >= l.maxConns {
+		l.rejected++
+		return false
+	}
+	if l.maxConnsPerIP > 0 && ip != "" && l.ipConns[ip] >= l.maxConnsPerIP {
+		l.rejected++
+		return false
+	}
+	l.numConns++
+	if ip != "" {
+		l.ipConns[ip]++
+	}
+	return true
+}
+
+// release uncounts the connection acquired.
+func (l *connLimiter) release(conn net.Conn) {
+	if l == nil {
+		return
+	}
+	ip := connIP(conn)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.numConns--
+	if ip == "" {
+		return
+	}
+	if l.ipConns[ip]--; l.ipConns[ip] <= 0 {
+		delete(l.ipConns, ip)
+	}
+}
+
+func (l *connLimiter) numRejected() uint64 {
+	if l == nil {
+		return 0
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.rejected
+}
+
+// connIP returns the ip of the client, empty for the unix socket connections which are not limited per ip.
+func connIP(conn net.Conn) string {
+	ip, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return ""
+	}
+	return ip
+}
+
+// acceptConn checks the accepted connection against the filter and the limits, the connection
+// not passing them is closed.
+func (ts *TarsServer) acceptConn(conn net.Conn) bool {
+	if ts.config.ConnFilter != nil && !ts.config.ConnFilter(conn) {
+		conn.Close()
+		return false
+	}
+	if ts.limiter.acquire(conn) {
+		atomic.AddInt32(&ts.numConn, 1)
+		return true
+	}
+	TLOG.Warnf("%s: reject connection from %v, connections exceed the limit", ts.config.Address, conn.RemoteAddr())
+	conn.Close()
+	return false
+}
+
+// closedConn uncounts the connection accepted.
+func (ts *TarsServer) closedConn(conn net.Conn) {
+	atomic.AddInt32(&ts.numConn, -1)
+	ts.limiter.release(conn)
+}
+
+// ConnStats is the statistics of a live connection of the server.
+type ConnStats struct {
+	RemoteAddr string
+	// IdleTime is the time since the last read of the connection.
+	IdleTime time.Duration
+	// NumInvoke is the number of the requests being handled or waiting in the queue.
+	NumInvoke int32
+}
+
+// connLister is implemented by the handlers of the stream connections.
+type connLister interface {
+	connections() []ConnStats
+}
+
+// Connections returns the live connections of the server sorted by the remote address,
+// nil if the server is not listening on a stream protocol.
+func (ts *TarsServer) Connections() []ConnStats {
+	cl, ok := ts.handle.(connLister)
+	if !ok {
+		return nil
+	}
+	conns := cl.connections()
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].RemoteAddr < conns[j].RemoteAddr
+	})
+	return conns
+}
+
+// NumConn returns the number of the live connections.
+func (ts *TarsServer) NumConn() int32 {
+	return atomic.LoadInt32(&ts.numConn)
+}
+
+// RejectedConns returns the number of the connections rejected by the connection limits.
+func (ts *TarsServer) RejectedConns() uint64 {
+	return ts.limiter.numRejected()
+}
+
+func (t *tcpHandler) connections() []ConnStats {
+	now := time.Now().Unix()
+	var conns []ConnStats
+	t.conns.Range(func(key, val interface{}) bool {
+		cf := val.(*connInfo)
+		st := ConnStats{
+			RemoteAddr: cf.conn.RemoteAddr().String(),
+			NumInvoke:  atomic.LoadInt32(&cf.numInvoke),
+		}
+		if idleTime := atomic.LoadInt64(&cf.idleTime); idleTime > 0 && idleTime < now {
+			st.IdleTime = time.Duration(now-idleTime) * time.Second
+		}
+		conns = append(conns, st)
+		return true
+	})
+	return conns
+}
@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+type addrConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c *addrConn) RemoteAddr() net.Addr { return c.addr }
+
+func tcpConn(ip string, port int) net.Conn {
+	return &addrConn{addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: port}}
+}
+
+func TestConnLimiter(t *testing.T) {
+	if newConnLimiter(&TarsServerConf{}) != nil {
+		t.Fatal("limiter without limits")
+	}
+	l := newConnLimiter(&TarsServerConf{MaxConns: 3, MaxConnsPerIP: 2})
+	a1, a2, a3 := tcpConn("10.0.0.1", 1), tcpConn("10.0.0.1", 2), tcpConn("10.0.0.1", 3)
+	b1, b2 := tcpConn("10.0.0.2", 1), tcpConn("10.0.0.2", 2)
+	if !l.acquire(a1) || !l.acquire(a2) {
+		t.Fatal("connections under the limits are rejected")
+	}
+	if l.acquire(a3) {
+		t.Error("connection exceeding the per ip limit is accepted")
+	}
+	if !l.acquire(b1) {
+		t.Error("connection of another ip is rejected")
+	}
+	if l.acquire(b2) {
+		t.Error("connection exceeding the total limit is accepted")
+	}
+	l.release(a1)
+	if !l.acquire(a3) {
+		t.Error("connection is rejected after a connection of the ip is closed")
+	}
+	if got := l.numRejected(); got != 2 {
+		t.Errorf("rejected %d, want 2", got)
+	}
+	// the unix socket connections are only limited in total
+	unix := &addrConn{addr: &net.UnixAddr{Name: "@", Net: "unix"}}
+	l.release(a3)
+	if !l.acquire(unix) {
+		t.Error("unix connection is rejected")
+	}
+}
+
+func TestConnLimiterWaitAccept(t *testing.T) {
+	l := newConnLimiter(&TarsServerConf{AcceptRate: 20, AcceptBurst: 1})
+	var isClosed int32
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		l.waitAccept(&isClosed)
+	}
+	// the burst is taken at once, and the others are accepted every 50ms
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 accepts in %v", elapsed)
+	}
+	isClosed = 1
+	start = time.Now()
+	l.waitAccept(&isClosed)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waiting after the server is closed for %v", elapsed)
+	}
+}
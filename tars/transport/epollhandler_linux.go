@@ -0,0 +1,316 @@
+//go:build linux
+// +build linux
+
+package transport
+
+import (
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// pollReadBufferSize is the size of the read buffer shared by the connections of a poller.
+	pollReadBufferSize = 64 * 1024
+	// pollEvents is the max number of events returned by an epoll wait.
+	pollEvents = 128
+	// pollTimeout is the timeout of epoll wait in milliseconds, and the interval to check idle connections.
+	pollTimeout = 1000
+	// pollShutdownTimeout is the timeout of epoll wait in milliseconds when the server is shutting down.
+	pollShutdownTimeout = 100
+)
+
+// epollHandler is the tcp handler which reads the connections when epoll reports them readable.
+// The connections are shared by the pollers, and a connection holds a buffer only for its incomplete package.
+type epollHandler struct {
+	*tcpHandler
+	pollers []*poller
+	next    uint32
+}
+
+// poller is an event loop which reads the readable connections into its buffer.
+type poller struct {
+	handler *epollHandler
+	epfd    int
+	buffer  []byte
+
+	lock  sync.Mutex
+	conns map[int]*pollConn
+}
+
+type pollConn struct {
+	*connInfo
+	fd  int
+	raw syscall.RawConn
+	buf []byte // the incomplete package
+}
+
+func newEpollHandler(ts *TarsServer) ServerHandler {
+	return &epollHandler{tcpHandler: &tcpHandler{config: ts.config, server: ts}}
+}
+
+func (h *epollHandler) Listen() error {
+	if err := h.tcpHandler.Listen(); err != nil {
+		return err
+	}
+	for i := 0; i < runtime.NumCPU(); i++ {
+		epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+		if err != nil {
+			TLOG.Errorf("epoll create error: %v", err)
+			return err
+		}
+		h.pollers = append(h.pollers, &poller{
+			handler: h,
+			epfd:    epfd,
+			buffer:  make([]byte, pollReadBufferSize),
+			conns:   make(map[int]*pollConn),
+		})
+	}
+	return nil
+}
+
+func (h *epollHandler) Handle() error {
+	for _, p := range h.pollers {
+		go p.run()
+	}
+	cfg := h.config
+	for {
+		if atomic.LoadInt32(&h.server.isClosed) == 1 {
+			TLOG.Errorf("Close accept %s %d", cfg.Address, os.Getpid())
+			atomic.StoreInt32(&h.isListenClosed, 1)
+			break
+		}
+		if cfg.AcceptTimeout > 0 && h.rawListener != nil {
+			// set accept timeout
+			if err := h.rawListener.SetDeadline(time.Now().Add(cfg.AcceptTimeout)); err != nil {
+				TLOG.Errorf("SetDeadline error: %v", err)
+			}
+		}
+		h.server.limiter.waitAccept(&h.server.isClosed)
+		conn, err := h.listener.Accept()
+		if err != nil {
+			if !isNoDataError(err) {
+				TLOG.Errorf("Accept error: %v", err)
+			}
+			continue
+		}
+		if !h.server.acceptConn(conn) {
+			continue
+		}
+		if err = h.register(conn); err != nil {
+			TLOG.Errorf("register connection %v error: %v", conn.RemoteAddr(), err)
+			conn.Close()
+			h.server.closedConn(conn)
+		}
+	}
+	if h.server.pool != nil {
+		h.server.pool.release()
+	}
+	return nil
+}
+
+// CloseIdles returns whether all the connections are closed, the idle connections are closed by the pollers
+// once the server is shutting down.
+func (h *epollHandler) CloseIdles(n int64) bool {
+	h.stopAccept()
+
+	allClosed := true
+	for _, p := range h.pollers {
+		if p.len() > 0 {
+			allClosed = false
+		}
+	}
+	return allClosed
+}
+
+func (h *epollHandler) register(conn net.Conn) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return errors.New("not a syscall conn")
+	}
+	if c, ok := conn.(*net.TCPConn); ok {
+		TLOG.Debugf("TCP accept: %s, %d", conn.RemoteAddr(), os.Getpid())
+		c.SetReadBuffer(h.config.TCPReadBuffer)
+		c.SetWriteBuffer(h.config.TCPWriteBuffer)
+		c.SetNoDelay(h.config.TCPNoDelay)
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fd int
+	if err = raw.Control(func(s uintptr) { fd = int(s) }); err != nil {
+		return err
+	}
+	pc := &pollConn{connInfo: &connInfo{conn: conn, idleTime: time.Now().Unix()}, fd: fd, raw: raw}
+	h.conns.Store(conn, pc.connInfo)
+	p := h.pollers[atomic.AddUint32(&h.next, 1)%uint32(len(h.pollers))]
+	if err = p.add(pc); err != nil {
+		h.conns.Delete(conn)
+		return err
+	}
+	return nil
+}
+
+func (p *poller) add(pc *pollConn) error {
+	p.lock.Lock()
+	p.conns[pc.fd] = pc
+	p.lock.Unlock()
+	ev := &syscall.EpollEvent{Events: syscall.EPOLLIN | syscall.EPOLLRDHUP, Fd: int32(pc.fd)}
+	if err := syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_ADD, pc.fd, ev); err != nil {
+		p.lock.Lock()
+		delete(p.conns, pc.fd)
+		p.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (p *poller) len() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.conns)
+}
+
+func (p *poller) get(fd int) *pollConn {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.conns[fd]
+}
+
+func (p *poller) run() {
+	defer syscall.Close(p.epfd)
+	events := make([]syscall.EpollEvent, pollEvents)
+	lastCheck := time.Now()
+	for {
+		closed := atomic.LoadInt32(&p.handler.server.isClosed) == 1
+		if closed && p.len() == 0 {
+			return
+		}
+		timeout := pollTimeout
+		if closed {
+			timeout = pollShutdownTimeout
+		}
+		n, err := syscall.EpollWait(p.epfd, events, timeout)
+		if err != nil && err != syscall.EINTR {
+			TLOG.Errorf("epoll wait error: %v", err)
+			return
+		}
+		for i := 0; i < n; i++ {
+			if pc := p.get(int(events[i].Fd)); pc != nil {
+				p.read(pc)
+			}
+		}
+		if closed || time.Since(lastCheck) >= pollTimeout*time.Millisecond {
+			lastCheck = time.Now()
+			p.closeIdles(closed)
+		}
+	}
+}
+
+// read reads the connection until no data is available, and handles the packages read.
+func (p *poller) read(pc *pollConn) {
+	for {
+		var n int
+		var err error
+		if cerr := pc.raw.Control(func(fd uintptr) {
+			n, err = syscall.Read(int(fd), p.buffer)
+		}); cerr != nil {
+			err = cerr
+		}
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.EAGAIN {
+			return
+		}
+		if err != nil || n == 0 {
+			if err == nil {
+				TLOG.Debug("connection closed by remote:", pc.conn.RemoteAddr())
+			} else {
+				TLOG.Error("read package error:", err)
+			}
+			p.close(pc)
+			return
+		}
+		atomic.StoreInt64(&pc.idleTime, time.Now().Unix())
+		if !p.parse(pc, p.buffer[:n]) {
+			TLOG.Errorf("parse package error %s", pc.conn.RemoteAddr())
+			p.close(pc)
+			return
+		}
+		if n < len(p.buffer) {
+			return
+		}
+	}
+}
+
+// parse handles the complete packages in the data, and keeps the incomplete one in the connection.
+func (p *poller) parse(pc *pollConn, data []byte) bool {
+	if len(pc.buf) > 0 {
+		pc.buf = append(pc.buf, data...)
+		data = pc.buf
+	}
+	for len(data) > 0 {
+		pkgLen, status := p.handler.server.protocol.ParsePackage(data)
+		if status == PackageLess {
+			break
+		}
+		if status != PackageFull {
+			return false
+		}
+		pkg := make([]byte, pkgLen)
+		copy(pkg, data[:pkgLen])
+		data = data[pkgLen:]
+		p.handler.handleConn(pc.connInfo, pkg)
+	}
+	if len(data) == 0 {
+		pc.buf = nil
+	} else {
+		// the data may be in the buffer of the poller
+		pc.buf = append([]byte(nil), data...)
+	}
+	return true
+}
+
+// closeIdles closes the connections which are idle for the idle timeout, or all the idle connections
+// when the server is shutting down.
+func (p *poller) closeIdles(closed bool) {
+	idleTimeout := int64(p.handler.config.IdleTimeout / time.Second)
+	now := time.Now().Unix()
+	var idles []*pollConn
+	p.lock.Lock()
+	for _, pc := range p.conns {
+		if len(pc.buf) > 0 || atomic.LoadInt32(&pc.numInvoke) > 0 {
+			continue
+		}
+		if closed || (idleTimeout > 0 && pc.idleTime+idleTimeout < now) {
+			idles = append(idles, pc)
+		}
+	}
+	p.lock.Unlock()
+	for _, pc := range idles {
+		p.close(pc)
+	}
+}
+
+// close removes the connection from the poller, and closes it after the requests are done.
+// The fd is not closed before it is removed, so it can not be reused by another connection in the poller.
+func (p *poller) close(pc *pollConn) {
+	p.lock.Lock()
+	delete(p.conns, pc.fd)
+	p.lock.Unlock()
+	if err := syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_DEL, pc.fd, &syscall.EpollEvent{}); err != nil {
+		TLOG.Debugf("epoll remove %v error: %v", pc.conn.RemoteAddr(), err)
+	}
+	go func() {
+		p.handler.closeConn(pc.connInfo)
+		p.handler.conns.Delete(pc.conn)
+		p.handler.server.closedConn(pc.conn)
+	}()
+}
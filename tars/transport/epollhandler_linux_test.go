@@ -0,0 +1,226 @@
+//go:build linux
+// +build linux
+
+package transport
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+type echoProtocol struct{}
+
+func (p *echoProtocol) Invoke(ctx context.Context, req []byte) []byte { return req }
+func (p *echoProtocol) InvokeTimeout(pkg []byte) []byte               { return pkg }
+func (p *echoProtocol) GetCloseMsg() []byte                           { return packEcho("") }
+func (p *echoProtocol) DoClose(ctx context.Context)                   {}
+
+func (p *echoProtocol) ParsePackage(buff []byte) (int, int) {
+	if len(buff) < 4 {
+		return 0, PackageLess
+	}
+	length := int(binary.BigEndian.Uint32(buff[:4]))
+	if length < 4 {
+		return 0, PackageError
+	}
+	if len(buff) < length {
+		return 0, PackageLess
+	}
+	return length, PackageFull
+}
+
+func packEcho(payload string) []byte {
+	pkg := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(pkg[:4], uint32(len(pkg)))
+	copy(pkg[4:], payload)
+	return pkg
+}
+
+func startEchoServer(tb testing.TB, handler string) (*TarsServer, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+	svr := NewTarsServer(&echoProtocol{}, &TarsServerConf{
+		Proto:         "tcp",
+		Address:       address,
+		MaxInvoke:     int32(runtime.NumCPU()),
+		QueueCap:      10000,
+		AcceptTimeout: 100 * time.Millisecond,
+		IdleTimeout:   time.Minute,
+		// zero buffers are the minimum of the kernel
+		TCPReadBuffer:  128 * 1024,
+		TCPWriteBuffer: 128 * 1024,
+		Handler:        handler,
+	})
+	if err = svr.Listen(); err != nil {
+		tb.Fatal(err)
+	}
+	go svr.Serve()
+	return svr, address
+}
+
+func stopServer(svr *TarsServer) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	svr.Shutdown(ctx)
+}
+
+func echo(conn net.Conn, req []byte, rsp []byte) error {
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	_, err := io.ReadFull(conn, rsp)
+	return err
+}
+
+func TestEpollHandler(t *testing.T) {
+	svr, address := startEchoServer(t, HandlerEpoll)
+	defer stopServer(svr)
+	if _, ok := svr.handle.(*epollHandler); !ok {
+		t.Fatalf("handler is %T", svr.handle)
+	}
+
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// the packages split and merged by the writes are parsed
+	reqs := append(packEcho("hello"), packEcho("world")...)
+	if _, err = conn.Write(reqs[:3]); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err = conn.Write(reqs[3:]); err != nil {
+		t.Fatal(err)
+	}
+	rsp := make([]byte, len(reqs))
+	if _, err = io.ReadFull(conn, rsp); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(rsp); got != string(reqs) && got != string(append(packEcho("world"), packEcho("hello")...)) {
+		t.Errorf("response %q", got)
+	}
+
+	// a large package is read by several reads
+	large := packEcho(string(make([]byte, pollReadBufferSize*3)))
+	rsp = make([]byte, len(large))
+	if err = echo(conn, large, rsp); err != nil {
+		t.Fatal(err)
+	}
+	if string(rsp) != string(large) {
+		t.Error("large package mismatch")
+	}
+}
+
+func TestEpollHandlerShutdown(t *testing.T) {
+	svr, address := startEchoServer(t, HandlerEpoll)
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	req := packEcho("hello")
+	if err = echo(conn, req, make([]byte, len(req))); err != nil {
+		t.Fatal(err)
+	}
+	stopServer(svr)
+	if !svr.handle.CloseIdles(0) {
+		t.Error("connections are not closed after shutdown")
+	}
+}
+
+var benchHandlers = []struct {
+	name    string
+	handler string
+}{
+	{"goroutine", ""},
+	{"epoll", HandlerEpoll},
+}
+
+// BenchmarkHandlerEcho measures the requests of 1KB on the busy connections.
+func BenchmarkHandlerEcho(b *testing.B) {
+	for _, h := range benchHandlers {
+		b.Run(h.name, func(b *testing.B) {
+			svr, address := startEchoServer(b, h.handler)
+			defer func() {
+				b.StopTimer()
+				stopServer(svr)
+			}()
+			req := packEcho(string(make([]byte, 1024)))
+			b.ReportAllocs()
+			b.SetParallelism(4)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				conn, err := net.Dial("tcp", address)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				defer conn.Close()
+				rsp := make([]byte, len(req))
+				for pb.Next() {
+					if err = echo(conn, req, rsp); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkHandlerIdleConns measures the memory of the idle connections which have sent a request.
+func BenchmarkHandlerIdleConns(b *testing.B) {
+	const idleConns = 2000
+	for _, h := range benchHandlers {
+		b.Run(h.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				reportIdleConns(b, h.handler, idleConns)
+			}
+		})
+	}
+}
+
+func reportIdleConns(b *testing.B, handler string, n int) {
+	b.StopTimer()
+	svr, address := startEchoServer(b, handler)
+	defer stopServer(svr)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	b.StartTimer()
+
+	req := packEcho("hello tars")
+	rsp := make([]byte, len(req))
+	conns := make([]net.Conn, 0, n)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < n; i++ {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			b.Fatal(err)
+		}
+		conns = append(conns, conn)
+		if err = echo(conn, req, rsp); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.StopTimer()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	// the client connections are counted too, which are the same for both handlers
+	b.ReportMetric(float64(int64(after.HeapInuse+after.StackInuse)-int64(before.HeapInuse+before.StackInuse))/float64(n), "inuse-bytes/conn")
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package transport
+
+func newEpollHandler(ts *TarsServer) ServerHandler {
+	TLOG.Warnf("%s: epoll handler is only supported on linux, use the default handler", ts.config.Address)
+	return &tcpHandler{config: ts.config, server: ts}
+}
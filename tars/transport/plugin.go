@@ -0,0 +1,33 @@
+package transport
+
+import (
+	"net"
+	"sync"
+)
+
+// Transport is the pluggable stream transport, such as quic, which carries the tars packages
+// over the connections it provides. The listener should implement SetDeadline(time.Time) error
+// so that the server can stop accepting when it is shutting down.
+type Transport interface {
+	// Listen announces on the address for the server, the transport is responsible for the tls.
+	Listen(address string, conf *TarsServerConf) (net.Listener, error)
+	// Dial connects to the address for the client, each Write of the returned conn is a whole package,
+	// and the protocol can be used to split the packages received.
+	Dial(address string, protocol ClientProtocol, conf *TarsClientConf) (net.Conn, error)
+}
+
+var transports sync.Map
+
+// RegisterTransport registers the transport of the protocol name, such as "quic".
+func RegisterTransport(proto string, t Transport) {
+	transports.Store(proto, t)
+}
+
+// GetTransport returns the registered transport of the protocol name.
+func GetTransport(proto string) (Transport, bool) {
+	v, ok := transports.Load(proto)
+	if !ok {
+		return nil, false
+	}
+	return v.(Transport), true
+}
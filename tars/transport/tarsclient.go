@@ -23,18 +23,20 @@ type TarsClientConf struct {
 	WriteTimeout time.Duration
 	DialTimeout  time.Duration
 	TlsConfig    *tls.Config
+	// Connections is the number of connections to the server, default is 1.
+	Connections int
+	// Handshake is called once the connection is established, before any request is sent on it.
+	// The connection is closed if it returns an error.
+	Handshake func(conn net.Conn) error
 }
 
 // TarsClient is struct for tars client.
 type TarsClient struct {
 	address string
-	// TODO remove it
-	conn *connection
+	conns   []*connection
 
-	protocol      ClientProtocol
-	config        *TarsClientConf
-	sendQueue     chan sendMsg
-	sendFailQueue chan sendMsg
+	protocol ClientProtocol
+	config   *TarsClientConf
 }
 
 type sendMsg struct {
@@ -43,7 +45,9 @@ type sendMsg struct {
 }
 
 type connection struct {
-	client *TarsClient
+	client        *TarsClient
+	sendQueue     chan sendMsg
+	sendFailQueue chan sendMsg
 
 	conn     net.Conn
 	connLock sync.Mutex
@@ -59,25 +63,64 @@ func NewTarsClient(address string, protocol ClientProtocol, config *TarsClientCo
 	if config.QueueLen <= 0 {
 		config.QueueLen = 100
 	}
+	if config.Connections <= 0 || config.Proto == "udp" {
+		config.Connections = 1
+	}
 	client := &TarsClient{
-		config:        config,
-		address:       address,
-		protocol:      protocol,
-		sendQueue:     make(chan sendMsg, config.QueueLen),
-		sendFailQueue: make(chan sendMsg, 1),
+		config:   config,
+		address:  address,
+		protocol: protocol,
+	}
+	client.conns = make([]*connection, config.Connections)
+	for i := range client.conns {
+		client.conns[i] = &connection{
+			client:        client,
+			sendQueue:     make(chan sendMsg, config.QueueLen),
+			sendFailQueue: make(chan sendMsg, 1),
+			isClosed:      true,
+			dialTimeout:   config.DialTimeout,
+		}
 	}
-	client.conn = &connection{client: client, isClosed: true, dialTimeout: config.DialTimeout}
 	return client
 }
 
-// ReConnect established the client connection with the server.
+// ReConnect established the client connections with the server.
 func (tc *TarsClient) ReConnect() error {
-	return tc.conn.ReConnect()
+	var err error
+	for _, c := range tc.conns {
+		if e := c.ReConnect(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Pick returns the id of the connection with the least in-flight requests, which can be used by SendOn.
+func (tc *TarsClient) Pick() int32 {
+	var id int32
+	min := atomic.LoadInt32(&tc.conns[0].invokeNum)
+	for i := 1; i < len(tc.conns); i++ {
+		if n := atomic.LoadInt32(&tc.conns[i].invokeNum); n < min {
+			id, min = int32(i), n
+		}
+	}
+	return id
 }
 
 // Send sends the request to the server as []byte.
 func (tc *TarsClient) Send(req []byte) error {
-	if err := tc.ReConnect(); err != nil {
+	return tc.SendOn(tc.Pick(), req)
+}
+
+// SendOn sends the request on the connection chosen by the key,
+// so that the requests with the same key, such as the frames of a stream, use the same connection.
+func (tc *TarsClient) SendOn(key int32, req []byte) error {
+	idx := int(uint32(key) % uint32(len(tc.conns)))
+	return tc.send(tc.conns[idx], req)
+}
+
+func (tc *TarsClient) send(c *connection, req []byte) error {
+	if err := c.ReConnect(); err != nil {
 		return err
 	}
 
@@ -90,18 +133,29 @@ func (tc *TarsClient) Send(req []byte) error {
 	select {
 	case <-timerC:
 		return errors.New("tars client write timeout")
-	case tc.sendQueue <- sendMsg{req: req}:
+	case c.sendQueue <- sendMsg{req: req}:
 		return nil
 	}
 }
 
-// Close the client connection with the server.
+// Close the client connections with the server.
 func (tc *TarsClient) Close() {
-	w := tc.conn
-	if !w.isClosed && w.conn != nil {
-		w.isClosed = true
-		_ = w.conn.Close()
+	for _, w := range tc.conns {
+		w.connLock.Lock()
+		if !w.isClosed && w.conn != nil {
+			w.isClosed = true
+			_ = w.conn.Close()
+		}
+		w.connLock.Unlock()
+	}
+}
+
+func (tc *TarsClient) invokeNum() int32 {
+	var n int32
+	for _, c := range tc.conns {
+		n += atomic.LoadInt32(&c.invokeNum)
 	}
+	return n
 }
 
 // GraceClose close client gracefully
@@ -113,8 +167,9 @@ func (tc *TarsClient) GraceClose(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-tk.C:
-			TLOG.Debugf("wait grace invoke %d", tc.conn.invokeNum)
-			if atomic.LoadInt32(&tc.conn.invokeNum) <= 0 {
+			invokeNum := tc.invokeNum()
+			TLOG.Debugf("wait grace invoke %d", invokeNum)
+			if invokeNum <= 0 {
 				tc.Close()
 				return
 			}
@@ -127,7 +182,9 @@ func (c *connection) ReConnect() (err error) {
 	defer c.connLock.Unlock()
 	if c.isClosed {
 		TLOG.Debug("Connect:", c.client.address, "Proto:", c.client.config.Proto)
-		if c.client.config.Proto == "ssl" {
+		if tr, ok := GetTransport(c.client.config.Proto); ok {
+			c.conn, err = tr.Dial(c.client.address, c.client.protocol, c.client.config)
+		} else if c.client.config.Proto == "ssl" {
 			dialer := &net.Dialer{Timeout: c.dialTimeout}
 			c.conn, err = tls.DialWithDialer(dialer, "tcp", c.client.address, c.client.config.TlsConfig)
 		} else {
@@ -142,6 +199,12 @@ func (c *connection) ReConnect() (err error) {
 				_ = c.conn.(*net.TCPConn).SetKeepAlive(true)
 			}
 		}
+		if c.client.config.Handshake != nil {
+			if err = c.client.config.Handshake(c.conn); err != nil {
+				_ = c.conn.Close()
+				return err
+			}
+		}
 		c.idleTime = time.Now()
 		c.isClosed = false
 		connDone := make(chan bool, 1)
@@ -163,16 +226,19 @@ func (c *connection) send(conn net.Conn, connDone chan bool) {
 		}
 		// get sendMsg
 		select {
-		case m = <-c.client.sendFailQueue: // Send failure queue messages first
+		case m = <-c.sendFailQueue: // Send failure queue messages first
 		default:
 			select {
-			case m = <-c.client.sendQueue: // Fetch jobs
+			case m = <-c.sendQueue: // Fetch jobs
 			case <-t.C:
-				if c.isClosed {
+				c.connLock.Lock()
+				isClosed, idleTime := c.isClosed, c.idleTime
+				c.connLock.Unlock()
+				if isClosed {
 					return
 				}
 				// TODO: check one-way invoke for idle detect
-				if c.invokeNum == 0 && c.idleTime.Add(c.client.config.IdleTimeout).Before(time.Now()) {
+				if atomic.LoadInt32(&c.invokeNum) == 0 && idleTime.Add(c.client.config.IdleTimeout).Before(time.Now()) {
 					c.close(conn)
 					return
 				}
@@ -185,13 +251,15 @@ func (c *connection) send(conn net.Conn, connDone chan bool) {
 				TLOG.Errorf("set write deadline error: %v", err)
 			}
 		}
+		c.connLock.Lock()
 		c.idleTime = time.Now()
+		c.connLock.Unlock()
 		_, err := conn.Write(m.req)
 		if err != nil {
 			// TODO add retry times
 			m.retry++
 			TLOG.Errorf("send request retry: %d, error: %v", m.retry, err)
-			c.client.sendFailQueue <- m
+			c.sendFailQueue <- m
 			c.close(conn)
 			if err != net.ErrClosed {
 				return
@@ -246,8 +314,8 @@ func (c *connection) recv(conn net.Conn, connDone chan bool) {
 			}
 			if status == PackageFull {
 				atomic.AddInt32(&c.invokeNum, -1)
-				pkg := make([]byte, pkgLen)
-				copy(pkg, currBuffer[0:pkgLen])
+				// the appended data never overwrites the packages, so they refer to the buffer instead of copies
+				pkg := currBuffer[:pkgLen:pkgLen]
 				currBuffer = currBuffer[pkgLen:]
 				go c.client.protocol.Recv(pkg)
 				if len(currBuffer) > 0 {
@@ -3,9 +3,11 @@ package transport
 import (
 	"context"
 	"crypto/tls"
+	"net"
 	"sync/atomic"
 	"time"
 
+	"github.com/TarsCloud/TarsGo/tars/util/current"
 	"github.com/TarsCloud/TarsGo/tars/util/rogger"
 )
 
@@ -27,6 +29,23 @@ type TarsServerConf struct {
 	TCPWriteBuffer int
 	TCPNoDelay     bool
 	TlsConfig      *tls.Config
+	// Priorities is the number of the priority queues of the goroutine pool, the requests are classified
+	// by the PriorityProtocol. The pool has a single FIFO queue if it is not set.
+	Priorities int
+	// PriorityMaxSkip is the number of the requests of higher priorities which can be handled before
+	// a waiting request of lower priority, gpool.DefaultMaxSkip if it is not set.
+	PriorityMaxSkip int
+	// MaxConns and MaxConnsPerIP limit the connections in total and per client ip, the connections
+	// exceeding them are closed once accepted. AcceptRate limits the connections accepted per second,
+	// with the burst of AcceptBurst. They are not limited if not set.
+	MaxConns      int
+	MaxConnsPerIP int
+	AcceptRate    float64
+	AcceptBurst   int
+	// ConnFilter checks the connection once accepted, the connection not passing it is closed.
+	ConnFilter func(conn net.Conn) bool
+	// Handler is the handler of the tcp connections, HandlerEpoll or the default goroutine per connection.
+	Handler string
 }
 
 // TarsServer tars server struct.
@@ -34,7 +53,9 @@ type TarsServer struct {
 	protocol   ServerProtocol
 	config     *TarsServerConf
 	handle     ServerHandler
-	lastInvoke time.Time
+	pool       *workerPool
+	limiter    *connLimiter
+	lastInvoke int64 // unix nano when a request is dispatched or done
 	isClosed   int32
 	numInvoke  int32
 	numConn    int32
@@ -44,13 +65,23 @@ type TarsServer struct {
 func NewTarsServer(protocol ServerProtocol, config *TarsServerConf) *TarsServer {
 	ts := &TarsServer{protocol: protocol, config: config}
 	ts.isClosed = 0
-	ts.lastInvoke = time.Now()
+	ts.lastInvoke = time.Now().UnixNano()
 	return ts
 }
 
 func (ts *TarsServer) getHandler() (sh ServerHandler) {
-	if ts.config.Proto == "tcp" {
-		sh = &tcpHandler{config: ts.config, server: ts}
+	if ts.config.Proto == "tcp" || ts.config.Proto == "unix" {
+		if ts.config.Handler == HandlerEpoll && ts.config.TlsConfig == nil {
+			sh = newEpollHandler(ts)
+		} else {
+			if ts.config.Handler == HandlerEpoll {
+				TLOG.Warnf("%s: epoll handler does not support tls, use the default handler", ts.config.Address)
+			}
+			sh = &tcpHandler{config: ts.config, server: ts}
+		}
+	} else if tr, ok := GetTransport(ts.config.Proto); ok {
+		// the pluggable transports provide stream connections like tcp
+		sh = &tcpHandler{config: ts.config, server: ts, transport: tr}
 	} else if ts.config.Proto == "udp" {
 		sh = &udpHandler{config: ts.config, server: ts}
 	} else {
@@ -70,43 +101,68 @@ func (ts *TarsServer) Serve() error {
 // Listen listens on the network address
 func (ts *TarsServer) Listen() error {
 	ts.handle = ts.getHandler()
-	return ts.handle.Listen()
+	ts.limiter = newConnLimiter(ts.config)
+	if err := ts.handle.Listen(); err != nil {
+		return err
+	}
+	if ts.config.MaxInvoke > 0 {
+		ts.pool = newWorkerPool(ts)
+	}
+	return nil
 }
 
 // Shutdown try to shutdown server gracefully.
 func (ts *TarsServer) Shutdown(ctx context.Context) error {
-	// step 1: close listeners, notify client reconnect
+	ts.StopAccept()
+	return ts.Drain(ctx)
+}
+
+// StopAccept closes the listener, and notifies the clients to reconnect.
+func (ts *TarsServer) StopAccept() {
 	atomic.StoreInt32(&ts.isClosed, 1)
 	ts.handle.OnShutdown()
+}
 
-	// step 2: wait and close idle connections
+// Drain waits for the in-flight requests and closes the idle connections,
+// it returns the error of ctx if they are not done before ctx is done.
+func (ts *TarsServer) Drain(ctx context.Context) error {
 	watchInterval := time.Millisecond * 500
 	tk := time.NewTicker(watchInterval)
 	defer tk.Stop()
 	for {
 		select {
 		case <-ctx.Done():
-			return nil
+			return ctx.Err()
 		case <-tk.C:
-			if ts.handle.CloseIdles(2) {
+			if ts.handle.CloseIdles(2) && ts.NumInvoke() == 0 {
 				return nil
 			}
+			TLOG.Debugf("drain %s, num invoke %d", ts.config.Address, ts.NumInvoke())
 		}
 	}
 }
 
+// NumInvoke returns the number of the requests being handled or waiting in the queue.
+func (ts *TarsServer) NumInvoke() int32 {
+	return atomic.LoadInt32(&ts.numInvoke)
+}
+
 // GetConfig gets the tars server config.
 func (ts *TarsServer) GetConfig() *TarsServerConf {
 	return ts.config
 }
 
-// IsZombie show whether the server is hanged by the request.
+// IsZombie show whether the server is hanged by the request, which means the goroutine pool is full,
+// and no request has been dispatched or done within timeout.
 func (ts *TarsServer) IsZombie(timeout time.Duration) bool {
 	conf := ts.GetConfig()
-	return conf.MaxInvoke != 0 && ts.numInvoke == conf.MaxInvoke && ts.lastInvoke.Add(timeout).Before(time.Now())
+	lastInvoke := time.Unix(0, atomic.LoadInt64(&ts.lastInvoke))
+	return conf.MaxInvoke != 0 && ts.NumInvoke() >= conf.MaxInvoke && lastInvoke.Add(timeout).Before(time.Now())
 }
 
 func (ts *TarsServer) invoke(ctx context.Context, pkg []byte) []byte {
+	atomic.StoreInt64(&ts.lastInvoke, time.Now().UnixNano())
+	defer func() { atomic.StoreInt64(&ts.lastInvoke, time.Now().UnixNano()) }()
 	cfg := ts.config
 	var rsp []byte
 	if cfg.HandleTimeout == 0 {
@@ -124,3 +180,11 @@ func (ts *TarsServer) invoke(ctx context.Context, pkg []byte) []byte {
 	}
 	return rsp
 }
+
+// releaseResponse releases the pooled buffer of the response after it is written.
+// With the handle timeout, the invoke may still be running after the response is written, so it is not released.
+func (ts *TarsServer) releaseResponse(ctx context.Context) {
+	if ts.config.HandleTimeout == 0 {
+		current.ReleaseResponseBuffer(ctx)
+	}
+}
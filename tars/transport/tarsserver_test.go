@@ -0,0 +1,60 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+// gateProtocol handles a request each time the gate is opened.
+type gateProtocol struct {
+	gate chan struct{}
+}
+
+func (p *gateProtocol) Invoke(ctx context.Context, pkg []byte) []byte {
+	<-p.gate
+	return pkg
+}
+func (p *gateProtocol) ParsePackage(buff []byte) (int, int) { return len(buff), PackageFull }
+func (p *gateProtocol) InvokeTimeout(pkg []byte) []byte     { return pkg }
+func (p *gateProtocol) GetCloseMsg() []byte                 { return nil }
+func (p *gateProtocol) DoClose(ctx context.Context)         {}
+
+func TestIsZombie(t *testing.T) {
+	p := &gateProtocol{gate: make(chan struct{})}
+	ts := NewTarsServer(p, &TarsServerConf{Proto: "tcp", MaxInvoke: 2, QueueCap: 10})
+	ts.pool = newWorkerPool(ts)
+	defer ts.pool.release()
+	defer close(p.gate)
+	h := &tcpHandler{config: ts.config, server: ts}
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	go io.Copy(ioutil.Discard, peer)
+	cs := &connInfo{conn: conn}
+
+	// the pool is saturated, but the requests are done one by one
+	const timeout = 50 * time.Millisecond
+	for i := 0; i < 4; i++ {
+		h.handleConn(cs, []byte{1})
+	}
+	for end := time.Now().Add(4 * timeout); time.Now().Before(end); {
+		p.gate <- struct{}{}
+		h.handleConn(cs, []byte{1})
+		time.Sleep(timeout / 5)
+		if ts.NumInvoke() < ts.config.MaxInvoke {
+			t.Fatalf("pool is not saturated, num invoke %d", ts.NumInvoke())
+		}
+		if ts.IsZombie(timeout) {
+			t.Fatal("server making progress is a zombie")
+		}
+	}
+
+	// no request is done
+	time.Sleep(2 * timeout)
+	if !ts.IsZombie(timeout) {
+		t.Error("hanged server is not a zombie")
+	}
+}
@@ -7,16 +7,13 @@ import (
 	"net"
 	"os"
 	"reflect"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
 	"github.com/TarsCloud/TarsGo/tars/util/current"
-	"github.com/TarsCloud/TarsGo/tars/util/gpool"
 	"github.com/TarsCloud/TarsGo/tars/util/grace"
-	"github.com/TarsCloud/TarsGo/tars/util/gtime"
 )
 
 type tcpHandler struct {
@@ -24,46 +21,94 @@ type tcpHandler struct {
 
 	server         *TarsServer
 	listener       net.Listener
-	tcpListener    *net.TCPListener
+	rawListener    deadlineListener
 	isListenClosed int32
 
-	pool  *gpool.Pool
-	conns sync.Map
+	conns     sync.Map
+	transport Transport
+}
+
+type deadlineListener interface {
+	SetDeadline(t time.Time) error
 }
 
 type connInfo struct {
 	conn      net.Conn
 	idleTime  int64
 	numInvoke int32
+
+	reqLock  sync.Mutex
+	requests map[int32]*inflightRequest
+}
+
+// inflightRequest is the request being handled, which can be cancelled by the client.
+type inflightRequest struct {
+	cancel context.CancelFunc
+}
+
+func (c *connInfo) addRequest(id int32, cancel context.CancelFunc) *inflightRequest {
+	r := &inflightRequest{cancel: cancel}
+	c.reqLock.Lock()
+	defer c.reqLock.Unlock()
+	if c.requests == nil {
+		c.requests = make(map[int32]*inflightRequest)
+	}
+	c.requests[id] = r
+	return r
+}
+
+func (c *connInfo) removeRequest(id int32, r *inflightRequest) {
+	c.reqLock.Lock()
+	defer c.reqLock.Unlock()
+	// the request id may be reused by the frames of a stream
+	if c.requests[id] == r {
+		delete(c.requests, id)
+	}
+}
+
+func (c *connInfo) cancelRequest(id int32) {
+	c.reqLock.Lock()
+	r, ok := c.requests[id]
+	delete(c.requests, id)
+	c.reqLock.Unlock()
+	if ok {
+		TLOG.Debugf("cancel request %d from %v", id, c.conn.RemoteAddr())
+		r.cancel()
+	}
 }
 
 func (t *tcpHandler) Listen() (err error) {
 	cfg := t.config
-	t.listener, err = grace.CreateListener("tcp", cfg.Address)
+	if t.transport != nil {
+		t.listener, err = t.transport.Listen(cfg.Address, cfg)
+	} else {
+		t.listener, err = grace.CreateListener(cfg.Proto, cfg.Address)
+	}
 	if err != nil {
 		TLOG.Errorf("Listening on %s error: %v", cfg.Address, err)
 		return err
 	}
 
 	TLOG.Infof("Listening on %s", cfg.Address)
-	t.tcpListener = t.listener.(*net.TCPListener)
-	if t.config.TlsConfig != nil {
+	// *net.TCPListener, *net.UnixListener, or the listener of the transport
+	t.rawListener, _ = t.listener.(deadlineListener)
+	if t.config.TlsConfig != nil && t.transport == nil {
 		t.listener = tls.NewListener(t.listener, t.config.TlsConfig)
 	}
 
-	// init goroutine pool
-	if cfg.MaxInvoke > 0 {
-		t.pool = gpool.NewPool(int(cfg.MaxInvoke), cfg.QueueCap)
-	}
-
 	return nil
 }
 
 func (t *tcpHandler) getConnContext(connSt *connInfo) context.Context {
 	ctx := current.ContextWithTarsCurrent(context.Background())
-	ipPort := strings.Split(connSt.conn.RemoteAddr().String(), ":")
-	current.SetClientIPWithContext(ctx, ipPort[0])
-	current.SetClientPortWithContext(ctx, ipPort[1])
+	if ip, port, err := net.SplitHostPort(connSt.conn.RemoteAddr().String()); err == nil {
+		current.SetClientIPWithContext(ctx, ip)
+		current.SetClientPortWithContext(ctx, port)
+	} else {
+		// the peer of the unix socket has no ip, it is matched by the acl as "unix"
+		current.SetClientIPWithContext(ctx, "unix")
+		current.SetClientPortWithContext(ctx, "0")
+	}
 	current.SetRecvPkgTsFromContext(ctx, time.Now().UnixNano()/1e6)
 	current.SetRawConnWithContext(ctx, connSt.conn, nil)
 	return ctx
@@ -72,10 +117,32 @@ func (t *tcpHandler) getConnContext(connSt *connInfo) context.Context {
 func (t *tcpHandler) handleConn(connSt *connInfo, pkg []byte) {
 	// recvPkgTs are more accurate
 	ctx := t.getConnContext(connSt)
+	var done func()
+	if cp, ok := t.server.protocol.(CancelProtocol); ok {
+		reqID, isCancel := cp.ParseRequest(pkg)
+		if isCancel {
+			// cancel frames are not queued, so that the request waiting in the queue can be cancelled
+			connSt.cancelRequest(reqID)
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		r := connSt.addRequest(reqID, cancel)
+		done = func() {
+			connSt.removeRequest(reqID, r)
+			cancel()
+		}
+	}
 	atomic.AddInt32(&connSt.numInvoke, 1)
+	atomic.AddInt32(&t.server.numInvoke, 1)
 	handler := func() {
+		defer atomic.AddInt32(&t.server.numInvoke, -1)
 		defer atomic.AddInt32(&connSt.numInvoke, -1)
+		if done != nil {
+			defer done()
+		}
 		rsp := t.server.invoke(ctx, pkg)
+		defer t.server.releaseResponse(ctx)
 
 		cPacketType, ok := current.GetPacketTypeFromContext(ctx)
 		if !ok {
@@ -90,9 +157,8 @@ func (t *tcpHandler) handleConn(connSt *connInfo, pkg []byte) {
 		}
 	}
 
-	cfg := t.config
-	if cfg.MaxInvoke > 0 { // use goroutine pool
-		t.pool.JobQueue <- handler
+	if t.server.pool != nil { // use goroutine pool
+		t.server.pool.submit(ctx, pkg, handler)
 	} else {
 		go handler()
 	}
@@ -106,12 +172,13 @@ func (t *tcpHandler) Handle() error {
 			atomic.StoreInt32(&t.isListenClosed, 1)
 			break
 		}
-		if cfg.AcceptTimeout > 0 {
+		if cfg.AcceptTimeout > 0 && t.rawListener != nil {
 			// set accept timeout
-			if err := t.tcpListener.SetDeadline(time.Now().Add(cfg.AcceptTimeout)); err != nil {
+			if err := t.rawListener.SetDeadline(time.Now().Add(cfg.AcceptTimeout)); err != nil {
 				TLOG.Errorf("SetDeadline error: %v", err)
 			}
 		}
+		t.server.limiter.waitAccept(&t.server.isClosed)
 		conn, err := t.listener.Accept()
 		if err != nil {
 			if !isNoDataError(err) {
@@ -125,9 +192,12 @@ func (t *tcpHandler) Handle() error {
 			}
 			continue
 		}
-		atomic.AddInt32(&t.server.numConn, 1)
+		if !t.server.acceptConn(conn) {
+			continue
+		}
 		go func(conn net.Conn) {
-			key := conn.RemoteAddr().String()
+			// the remote addresses of the unix socket connections are the same, so the conn is used as the key
+			key := conn
 			switch c := conn.(type) {
 			case *net.TCPConn:
 				TLOG.Debugf("TCP accept: %s, %d", conn.RemoteAddr(), os.Getpid())
@@ -141,17 +211,20 @@ func (t *tcpHandler) Handle() error {
 			t.conns.Store(key, cf)
 			t.recv(cf)
 			t.conns.Delete(key)
+			t.server.closedConn(conn)
 		}(conn)
 	}
-	if t.pool != nil {
-		t.pool.Release()
+	if t.server.pool != nil {
+		t.server.pool.release()
 	}
 	return nil
 }
 
 func (t *tcpHandler) OnShutdown() {
 	// close listeners
-	t.tcpListener.SetDeadline(time.Now())
+	if t.rawListener != nil {
+		t.rawListener.SetDeadline(time.Now())
+	}
 	if atomic.LoadInt32(&t.isListenClosed) == 1 {
 		t.sendCloseMsg()
 		atomic.StoreInt32(&t.isListenClosed, 2)
@@ -177,23 +250,14 @@ func (t *tcpHandler) sendCloseMsg() {
 
 // CloseIdles close all idle connections(no active package within n secnods)
 func (t *tcpHandler) CloseIdles(n int64) bool {
-	if atomic.LoadInt32(&t.isListenClosed) == 0 {
-		// hack: create new connection to avoid acceptTCP hanging
-		TLOG.Debugf("Hack msg to %s", t.config.Address)
-		if conn, err := net.Dial("tcp", t.config.Address); err == nil {
-			conn.Close()
-		}
-	}
-	if atomic.LoadInt32(&t.isListenClosed) == 1 {
-		t.sendCloseMsg()
-		atomic.StoreInt32(&t.isListenClosed, 2)
-	}
+	t.stopAccept()
 
 	allClosed := true
 	t.conns.Range(func(key, val interface{}) bool {
 		conn := val.(*connInfo)
-		TLOG.Debugf("num invoke %d %v", atomic.LoadInt32(&conn.numInvoke), conn.idleTime+n > time.Now().Unix())
-		if atomic.LoadInt32(&conn.numInvoke) > 0 || conn.idleTime+n > time.Now().Unix() {
+		idleTime := atomic.LoadInt64(&conn.idleTime)
+		TLOG.Debugf("num invoke %d %v", atomic.LoadInt32(&conn.numInvoke), idleTime+n > time.Now().Unix())
+		if atomic.LoadInt32(&conn.numInvoke) > 0 || idleTime+n > time.Now().Unix() {
 			allClosed = false
 			return true
 		}
@@ -203,30 +267,48 @@ func (t *tcpHandler) CloseIdles(n int64) bool {
 	return allClosed
 }
 
-func (t *tcpHandler) recv(connSt *connInfo) {
-	conn := connSt.conn
-	defer func() {
-		watchInterval := time.Millisecond * 500
-		tk := time.NewTicker(watchInterval)
-		defer tk.Stop()
-		for range tk.C {
-			if atomic.LoadInt32(&connSt.numInvoke) == 0 {
-				break
-			}
+// stopAccept wakes up the accepting listener, and sends the close message after the listener is closed.
+func (t *tcpHandler) stopAccept() {
+	if atomic.LoadInt32(&t.isListenClosed) == 0 {
+		// hack: create new connection to avoid acceptTCP hanging
+		TLOG.Debugf("Hack msg to %s", t.config.Address)
+		if conn, err := net.Dial(t.config.Proto, t.config.Address); err == nil {
+			conn.Close()
 		}
-		TLOG.Debugf("Close connection: %v", conn.RemoteAddr())
-		conn.Close()
+	}
+	if atomic.LoadInt32(&t.isListenClosed) == 1 {
+		t.sendCloseMsg()
+		atomic.StoreInt32(&t.isListenClosed, 2)
+	}
+}
 
-		ctx := t.getConnContext(connSt)
-		t.server.protocol.DoClose(ctx)
+// closeConn closes the connection after the requests being handled are done.
+func (t *tcpHandler) closeConn(connSt *connInfo) {
+	watchInterval := time.Millisecond * 500
+	tk := time.NewTicker(watchInterval)
+	defer tk.Stop()
+	for range tk.C {
+		if atomic.LoadInt32(&connSt.numInvoke) == 0 {
+			break
+		}
+	}
+	TLOG.Debugf("Close connection: %v", connSt.conn.RemoteAddr())
+	connSt.conn.Close()
+
+	ctx := t.getConnContext(connSt)
+	t.server.protocol.DoClose(ctx)
 
-		connSt.idleTime = 0
-	}()
+	atomic.StoreInt64(&connSt.idleTime, 0)
+}
+
+func (t *tcpHandler) recv(connSt *connInfo) {
+	conn := connSt.conn
+	defer t.closeConn(connSt)
 
 	cfg := t.config
 	buffer := make([]byte, 1024*4)
 	var currBuffer []byte // need a deep copy of buffer
-	connSt.idleTime = gtime.CurrUnixTime
+	atomic.StoreInt64(&connSt.idleTime, time.Now().Unix())
 	var n int
 	var err error
 	for {
@@ -236,14 +318,15 @@ func (t *tcpHandler) recv(connSt *connInfo) {
 		} else if cfg.ReadTimeout > 0 {
 			conn.SetReadDeadline(time.Now().Add(cfg.ReadTimeout))
 		}
-		connSt.idleTime = time.Now().Unix()
+		atomic.StoreInt64(&connSt.idleTime, time.Now().Unix())
 		n, err = conn.Read(buffer)
 		if err != nil {
 			TLOG.Debugf("%s closed: %d, read %d, nil buff: %d, err: %v", t.server.config.Address, atomic.LoadInt32(&t.server.isClosed), n, len(currBuffer), err)
 			if atomic.LoadInt32(&t.server.isClosed) == 1 && currBuffer == nil {
 				return
 			}
-			if len(currBuffer) == 0 && connSt.numInvoke == 0 && (connSt.idleTime+int64(cfg.IdleTimeout)/int64(time.Second)) < time.Now().Unix() {
+			if len(currBuffer) == 0 && atomic.LoadInt32(&connSt.numInvoke) == 0 &&
+				(atomic.LoadInt64(&connSt.idleTime)+int64(cfg.IdleTimeout)/int64(time.Second)) < time.Now().Unix() {
 				return
 			}
 			if isNoDataError(err) {
@@ -263,8 +346,8 @@ func (t *tcpHandler) recv(connSt *connInfo) {
 				break
 			}
 			if status == PackageFull {
-				pkg := make([]byte, pkgLen)
-				copy(pkg, currBuffer[:pkgLen])
+				// the appended data never overwrites the packages, so they refer to the buffer instead of copies
+				pkg := currBuffer[:pkgLen:pkgLen]
 				currBuffer = currBuffer[pkgLen:]
 				t.handleConn(connSt, pkg)
 				if len(currBuffer) > 0 {
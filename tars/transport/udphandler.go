@@ -7,8 +7,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/TarsCloud/TarsGo/tars/util/gpool"
-
 	"github.com/TarsCloud/TarsGo/tars/protocol/res/basef"
 	"github.com/TarsCloud/TarsGo/tars/util/current"
 	"github.com/TarsCloud/TarsGo/tars/util/grace"
@@ -19,7 +17,6 @@ type udpHandler struct {
 	server *TarsServer
 
 	conn *net.UDPConn
-	pool *gpool.Pool
 }
 
 func (u *udpHandler) Listen() (err error) {
@@ -29,11 +26,6 @@ func (u *udpHandler) Listen() (err error) {
 		return err
 	}
 	TLOG.Info("UDP listen", u.conn.LocalAddr())
-
-	// init goroutine pool
-	if cfg.MaxInvoke > 0 {
-		u.pool = gpool.NewPool(int(cfg.MaxInvoke), cfg.QueueCap)
-	}
 	return nil
 }
 
@@ -52,6 +44,7 @@ func (u *udpHandler) handleUDPAddr(udpAddr *net.UDPAddr, pkg []byte) {
 	handler := func() {
 		defer atomic.AddInt32(&u.server.numInvoke, -1)
 		rsp := u.server.invoke(ctx, pkg) // no need to check package
+		defer u.server.releaseResponse(ctx)
 
 		cPacketType, ok := current.GetPacketTypeFromContext(ctx)
 		if !ok {
@@ -67,9 +60,8 @@ func (u *udpHandler) handleUDPAddr(udpAddr *net.UDPAddr, pkg []byte) {
 		}
 	}
 
-	cfg := u.config
-	if cfg.MaxInvoke > 0 { // use goroutine pool
-		u.pool.JobQueue <- handler
+	if u.server.pool != nil { // use goroutine pool
+		u.server.pool.submit(ctx, pkg, handler)
 	} else {
 		go handler()
 	}
@@ -0,0 +1,66 @@
+package transport
+
+import (
+	"context"
+
+	"github.com/TarsCloud/TarsGo/tars/util/current"
+	"github.com/TarsCloud/TarsGo/tars/util/gpool"
+)
+
+// workerPool is the goroutine pool of the server, which has a FIFO queue, or a queue for every priority
+// if TarsServerConf.Priorities is set and the protocol is a PriorityProtocol.
+type workerPool struct {
+	fifo     *gpool.Pool
+	priority *gpool.PriorityPool
+	classify PriorityProtocol
+}
+
+func newWorkerPool(ts *TarsServer) *workerPool {
+	cfg := ts.config
+	p := &workerPool{}
+	if pp, ok := ts.protocol.(PriorityProtocol); ok && cfg.Priorities > 1 {
+		p.priority = gpool.NewPriorityPool(int(cfg.MaxInvoke), cfg.QueueCap, cfg.Priorities, cfg.PriorityMaxSkip)
+		p.classify = pp
+		return p
+	}
+	if cfg.Priorities > 1 {
+		TLOG.Warnf("%s: the protocol does not support the priority queues, use a FIFO queue", cfg.Address)
+	}
+	p.fifo = gpool.NewPool(int(cfg.MaxInvoke), cfg.QueueCap)
+	return p
+}
+
+// submit queues the job of the package, and sets the priority and the queue length to the tars current.
+func (p *workerPool) submit(ctx context.Context, pkg []byte, job gpool.Job) {
+	if p.priority == nil {
+		current.SetQueueLenWithContext(ctx, len(p.fifo.JobQueue), cap(p.fifo.JobQueue))
+		p.fifo.JobQueue <- job
+		return
+	}
+	priority := p.classify.Priority(pkg)
+	current.SetPriorityWithContext(ctx, priority)
+	queueLen, queueCap := p.priority.Len(priority)
+	current.SetQueueLenWithContext(ctx, queueLen, queueCap)
+	p.priority.Submit(priority, job)
+}
+
+func (p *workerPool) release() {
+	if p.priority != nil {
+		p.priority.Release()
+	} else {
+		p.fifo.Release()
+	}
+}
+
+// QueueStats returns the statistics of the priority queues of the goroutine pool, from the lowest priority
+// to the highest. It returns nil if the pool has no priority queues.
+func (ts *TarsServer) QueueStats() []gpool.QueueStats {
+	if ts.pool == nil || ts.pool.priority == nil {
+		return nil
+	}
+	stats := make([]gpool.QueueStats, ts.pool.priority.Priorities())
+	for i := range stats {
+		stats[i] = ts.pool.priority.Stats(i)
+	}
+	return stats
+}
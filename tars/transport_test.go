@@ -0,0 +1,55 @@
+package tars
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/TarsCloud/TarsGo/tars/transport"
+)
+
+// testProto is the transport of the test servers, which serves the listeners created by the tests.
+const testProto = "testtcp"
+
+// testListeners are the listeners of the test servers by address.
+var testListeners sync.Map
+
+type testTransport struct{}
+
+func (testTransport) Listen(address string, _ *transport.TarsServerConf) (net.Listener, error) {
+	ln, ok := testListeners.Load(address)
+	if !ok {
+		return nil, errors.New("no test listener on " + address)
+	}
+	testListeners.Delete(address)
+	return ln.(net.Listener), nil
+}
+
+func (testTransport) Dial(address string, _ transport.ClientProtocol, conf *transport.TarsClientConf) (net.Conn, error) {
+	return net.DialTimeout("tcp", address, conf.DialTimeout)
+}
+
+func init() {
+	transport.RegisterTransport(testProto, testTransport{})
+}
+
+// startServer serves the protocol on a random port of the loopback until the test ends.
+// The server takes over the listener, so that the port is never released before it is served.
+func startServer(t *testing.T, server *Protocol) *net.TCPAddr {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().(*net.TCPAddr)
+	testListeners.Store(address.String(), ln)
+	svr := transport.NewTarsServer(server, newTarsServerConf(testProto, address.String(), server.app.svrCfg))
+	if err = svr.Listen(); err != nil {
+		ln.Close()
+		t.Fatal(err)
+	}
+	go svr.Serve()
+	t.Cleanup(func() { svr.Shutdown(context.Background()) })
+	return address
+}
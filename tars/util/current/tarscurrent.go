@@ -17,6 +17,10 @@ type Current struct {
 	clientIP    string
 	clientPort  string
 	recvPkgTs   int64
+	queueLen    int
+	queueCap    int
+	priority    int
+	hasPriority bool
 	cPacketType int8
 	reqStatus   map[string]string
 	resStatus   map[string]string
@@ -28,6 +32,13 @@ type Current struct {
 
 	rawConn net.Conn
 	udpAddr *net.UDPAddr
+	// rspBuffer is the pooled buffer of the response
+	rspBuffer Releaser
+}
+
+// Releaser is the pooled buffer which is put back to the pool by Release.
+type Releaser interface {
+	Release()
 }
 
 // NewCurrent return a Current point.
@@ -42,6 +53,33 @@ func ContextWithTarsCurrent(ctx context.Context) context.Context {
 	return ctx
 }
 
+// DetachTarsCurrent returns the context with a copy of the tars current of ctx, for the handler
+// which may keep running after the response is sent. The response buffer is not copied.
+func DetachTarsCurrent(ctx context.Context) context.Context {
+	tc, ok := currentFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	c := *tc
+	c.reqStatus = copyMap(tc.reqStatus)
+	c.resStatus = copyMap(tc.resStatus)
+	c.reqContext = copyMap(tc.reqContext)
+	c.resContext = copyMap(tc.resContext)
+	c.rspBuffer = nil
+	return context.WithValue(ctx, tcKey, &c)
+}
+
+func copyMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
 // GetClientIPFromContext gets the client ip from the context.
 func GetClientIPFromContext(ctx context.Context) (string, bool) {
 	tc, ok := currentFromContext(ctx)
@@ -174,6 +212,64 @@ func SetRecvPkgTsFromContext(ctx context.Context, recvPkgTs int64) bool {
 	return ok
 }
 
+// GetQueueLenFromContext gets the length and the capacity of the server queue when the request is received.
+func GetQueueLenFromContext(ctx context.Context) (int, int, bool) {
+	tc, ok := currentFromContext(ctx)
+	if ok {
+		return tc.queueLen, tc.queueCap, ok
+	}
+	return 0, 0, ok
+}
+
+// SetQueueLenWithContext set the length and the capacity of the server queue to the tars current.
+func SetQueueLenWithContext(ctx context.Context, queueLen, queueCap int) bool {
+	tc, ok := currentFromContext(ctx)
+	if ok {
+		tc.queueLen = queueLen
+		tc.queueCap = queueCap
+	}
+	return ok
+}
+
+// GetPriorityFromContext gets the priority of the request which is queued by, it is not ok if the request
+// is not queued by priority.
+func GetPriorityFromContext(ctx context.Context) (int, bool) {
+	tc, ok := currentFromContext(ctx)
+	if ok && tc.hasPriority {
+		return tc.priority, true
+	}
+	return 0, false
+}
+
+// SetPriorityWithContext sets the priority of the request which is queued by to the tars current.
+func SetPriorityWithContext(ctx context.Context, priority int) bool {
+	tc, ok := currentFromContext(ctx)
+	if ok {
+		tc.priority = priority
+		tc.hasPriority = true
+	}
+	return ok
+}
+
+// SetResponseBufferWithContext sets the pooled buffer of the response to the tars current,
+// which is released by the transport after the response is written.
+func SetResponseBufferWithContext(ctx context.Context, buf Releaser) bool {
+	tc, ok := currentFromContext(ctx)
+	if ok {
+		tc.rspBuffer = buf
+	}
+	return ok
+}
+
+// ReleaseResponseBuffer releases the pooled buffer of the response in the tars current.
+func ReleaseResponseBuffer(ctx context.Context) {
+	tc, ok := currentFromContext(ctx)
+	if ok && tc.rspBuffer != nil {
+		tc.rspBuffer.Release()
+		tc.rspBuffer = nil
+	}
+}
+
 // GetPacketTypeFromContext gets the PacketType from the context.
 func GetPacketTypeFromContext(ctx context.Context) (int8, bool) {
 	tc, ok := currentFromContext(ctx)
@@ -7,6 +7,10 @@ func Tars2endpoint(end endpointf.EndpointF) Endpoint {
 	proto := "tcp"
 	if end.Istcp == UDP {
 		proto = "udp"
+	} else if end.Istcp == UNIX {
+		proto = "unix"
+	} else if end.Istcp == QUIC {
+		proto = "quic"
 	}
 	e := Endpoint{
 		Host:       end.Host,
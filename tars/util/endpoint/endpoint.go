@@ -1,11 +1,18 @@
 package endpoint
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 const (
 	UDP int32 = 0
 	TCP int32 = 1
 	SSL int32 = 2
+	// UNIX is the unix domain socket, the Host is the socket path.
+	UNIX int32 = 3
+	// QUIC is the quic transport, which needs the transport registered, such as contrib/transport/quic.
+	QUIC int32 = 4
 )
 
 type AuthType int32
@@ -18,6 +25,8 @@ const (
 const (
 	ELoop WeightType = iota
 	EStaticWeight
+	// EDynamicWeight selects endpoints by the load, such as in-flight requests and response time.
+	EDynamicWeight
 )
 
 // Endpoint struct is used record a remote server instance.
@@ -40,9 +49,22 @@ type Endpoint struct {
 
 // String returns readable string for Endpoint
 func (e Endpoint) String() string {
+	if e.Istcp == UNIX {
+		return fmt.Sprintf("%s -path %s -t %d", e.Proto, e.Host, e.Timeout)
+	}
 	return fmt.Sprintf("%s -h %s -p %d -t %d", e.Proto, e.Host, e.Port, e.Timeout)
 }
 
+// Zone returns the zone of the endpoint, which is the area of the set id "name.area.group",
+// or the whole set id if it is not in that format.
+func (e Endpoint) Zone() string {
+	parts := strings.Split(e.SetId, ".")
+	if len(parts) == 3 {
+		return parts[1]
+	}
+	return e.SetId
+}
+
 func (e Endpoint) HashKey() string {
 	return e.Host
 }
@@ -58,3 +80,11 @@ func (e Endpoint) IsUdp() bool {
 func (e Endpoint) IsSSL() bool {
 	return e.Istcp == SSL
 }
+
+func (e Endpoint) IsUnix() bool {
+	return e.Istcp == UNIX
+}
+
+func (e Endpoint) IsQuic() bool {
+	return e.Istcp == QUIC
+}
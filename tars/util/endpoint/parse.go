@@ -5,12 +5,17 @@ import (
 	"strings"
 )
 
-// Parse pares string to struct Endpoint, like tcp -h 10.219.139.142 -p 19386 -t 60000
+// Parse pares string to struct Endpoint, like tcp -h 10.219.139.142 -p 19386 -t 60000,
+// or unix -path /var/run/x.sock -t 60000 for the unix domain socket.
 func Parse(endpoint string) Endpoint {
 	// tcp -h 10.219.139.142 -p 19386 -t 60000
-	proto := endpoint[0:3]
+	fields := strings.Fields(endpoint)
+	if len(fields) == 0 {
+		return Endpoint{}
+	}
+	proto := fields[0]
 	pFlag := flag.NewFlagSet(proto, flag.ContinueOnError)
-	var host, bind string
+	var host, bind, path string
 	var port, timeout, grid, qos, weight, weightType, authType int
 	pFlag.StringVar(&host, "h", "", "host")
 	pFlag.IntVar(&port, "p", 0, "port")
@@ -21,13 +26,19 @@ func Parse(endpoint string) Endpoint {
 	pFlag.IntVar(&weightType, "v", 0, "weight type") // 权重类型
 	pFlag.IntVar(&authType, "e", 0, "auth type")     // 鉴权类型: enum AUTH_TYPE { AUTH_TYPENONE = 0, AUTH_TYPELOCAL = 1};
 	pFlag.StringVar(&bind, "b", "", "bind")
-	_ = pFlag.Parse(strings.Fields(endpoint)[1:])
+	pFlag.StringVar(&path, "path", "", "unix socket path")
+	_ = pFlag.Parse(fields[1:])
 	isTcp := int32(0)
 	if proto == "tcp" {
 		isTcp = int32(1)
 	} else if proto == "ssl" {
 		proto = "tcp"
 		isTcp = int32(2)
+	} else if proto == "unix" {
+		isTcp = UNIX
+		host = path
+	} else if proto == "quic" {
+		isTcp = QUIC
 	}
 	if weightType != 0 && (weight == -1 || weight > 100) {
 		weight = 100
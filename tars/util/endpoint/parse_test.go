@@ -12,6 +12,8 @@ func TestParse(t *testing.T) {
 		"udp -h 127.0.0.1 -p 19386 -t 60000",
 		"ssl -h 127.0.0.1 -p 19386 -t 60000",
 		"ssl -h 127.0.0.1 -p 19386 -t 60000 -g 10 -q 10 -w 10 -v 1 -e 0",
+		"unix -path /var/run/tars.sock -t 60000",
+		"quic -h 127.0.0.1 -p 19386 -t 60000",
 	}
 	for _, tt := range tests {
 		e2 := Parse(tt)
@@ -22,3 +24,26 @@ func TestParse(t *testing.T) {
 	}
 	fmt.Println(AuthTypeNone, AuthTypeLocal, ELoop, EStaticWeight)
 }
+
+func TestParse_unix(t *testing.T) {
+	e := Parse("unix -path /var/run/tars.sock -t 60000")
+	if !e.IsUnix() || e.Proto != "unix" || e.Host != "/var/run/tars.sock" {
+		t.Fatalf("Parse() = %+v, want unix endpoint with the path", e)
+	}
+	if got := Tars2endpoint(Endpoint2tars(e)); got.Key != e.Key {
+		t.Errorf("Tars2endpoint() key = %q, want %q", got.Key, e.Key)
+	}
+}
+
+func TestEndpoint_Zone(t *testing.T) {
+	testCases := map[string]string{
+		"":         "",
+		"app.sz.1": "sz",
+		"sz":       "sz",
+	}
+	for setID, want := range testCases {
+		if got := (Endpoint{SetId: setID}).Zone(); got != want {
+			t.Errorf("Zone() of %q = %q, want %q", setID, got, want)
+		}
+	}
+}
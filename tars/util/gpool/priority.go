@@ -0,0 +1,182 @@
+package gpool
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxSkip is the default number of the jobs of higher priorities which can be taken
+// before a waiting job of lower priority.
+const DefaultMaxSkip = 16
+
+// PriorityPool is a goroutine pool with a job queue for every priority, the workers take the jobs
+// of the highest priority first. A waiting job of lower priority is taken after maxSkip jobs of
+// higher priorities are taken before it, so that the lower priorities are not starved.
+type PriorityPool struct {
+	queues  []chan queuedJob
+	ready   chan struct{}
+	maxSkip int
+
+	lock    sync.Mutex
+	skipped []int // the number of the jobs of higher priorities taken while the queue is waiting
+	stats   []queueCounter
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+type queuedJob struct {
+	job Job
+	at  time.Time
+}
+
+type queueCounter struct {
+	dispatched uint64
+	waitNanos  int64
+	maxWait    int64
+}
+
+// QueueStats is the statistics of the queue of a priority.
+type QueueStats struct {
+	// Len and Cap are the length and the capacity of the queue.
+	Len int
+	Cap int
+	// Dispatched is the number of the jobs taken by the workers.
+	Dispatched uint64
+	// Wait is the total time waited in the queue by the dispatched jobs, and MaxWait is the longest.
+	Wait    time.Duration
+	MaxWait time.Duration
+}
+
+// NewPriorityPool news a goroutine pool with the job queues of the priorities from 0 to priorities-1,
+// the higher the more urgent. maxSkip is DefaultMaxSkip if it is not positive.
+func NewPriorityPool(numWorkers int, jobQueueLen int, priorities int, maxSkip int) *PriorityPool {
+	if priorities < 1 {
+		priorities = 1
+	}
+	if maxSkip <= 0 {
+		maxSkip = DefaultMaxSkip
+	}
+	if jobQueueLen < 1 {
+		// the job is queued before the workers are signaled
+		jobQueueLen = 1
+	}
+	p := &PriorityPool{
+		queues:  make([]chan queuedJob, priorities),
+		ready:   make(chan struct{}, jobQueueLen*priorities),
+		maxSkip: maxSkip,
+		skipped: make([]int, priorities),
+		stats:   make([]queueCounter, priorities),
+		stop:    make(chan struct{}),
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan queuedJob, jobQueueLen)
+	}
+	p.wg.Add(numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Priorities returns the number of the priorities.
+func (p *PriorityPool) Priorities() int {
+	return len(p.queues)
+}
+
+// Submit queues the job with the priority, it blocks while the queue of the priority is full.
+// The priority out of range is taken as the nearest one.
+func (p *PriorityPool) Submit(priority int, job Job) {
+	p.queues[p.level(priority)] <- queuedJob{job: job, at: time.Now()}
+	p.ready <- struct{}{}
+}
+
+// Len returns the length and the capacity of the queue of the priority.
+func (p *PriorityPool) Len(priority int) (int, int) {
+	q := p.queues[p.level(priority)]
+	return len(q), cap(q)
+}
+
+// Stats returns the statistics of the queue of the priority.
+func (p *PriorityPool) Stats(priority int) QueueStats {
+	level := p.level(priority)
+	c := &p.stats[level]
+	return QueueStats{
+		Len:        len(p.queues[level]),
+		Cap:        cap(p.queues[level]),
+		Dispatched: atomic.LoadUint64(&c.dispatched),
+		Wait:       time.Duration(atomic.LoadInt64(&c.waitNanos)),
+		MaxWait:    time.Duration(atomic.LoadInt64(&c.maxWait)),
+	}
+}
+
+// Release stops the workers after they finish the running jobs, the queued jobs are dropped.
+func (p *PriorityPool) Release() {
+	close(p.stop)
+	p.wg.Wait()
+}
+
+func (p *PriorityPool) level(priority int) int {
+	if priority < 0 {
+		return 0
+	}
+	if priority >= len(p.queues) {
+		return len(p.queues) - 1
+	}
+	return priority
+}
+
+func (p *PriorityPool) work() {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-p.ready:
+			qj, level := p.take()
+			p.record(level, time.Since(qj.at))
+			qj.job()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// take takes a job for a ready signal, every signal is sent after a job is queued.
+func (p *PriorityPool) take() (queuedJob, int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	// the starving queue first
+	for level := range p.queues {
+		if p.skipped[level] >= p.maxSkip && len(p.queues[level]) > 0 {
+			p.skipped[level] = 0
+			return <-p.queues[level], level
+		}
+	}
+	for level := len(p.queues) - 1; level > 0; level-- {
+		select {
+		case qj := <-p.queues[level]:
+			p.skipped[level] = 0
+			for lower := 0; lower < level; lower++ {
+				if len(p.queues[lower]) > 0 {
+					p.skipped[lower]++
+				}
+			}
+			return qj, level
+		default:
+		}
+	}
+	p.skipped[0] = 0
+	return <-p.queues[0], 0
+}
+
+func (p *PriorityPool) record(level int, wait time.Duration) {
+	c := &p.stats[level]
+	atomic.AddUint64(&c.dispatched, 1)
+	atomic.AddInt64(&c.waitNanos, int64(wait))
+	for {
+		max := atomic.LoadInt64(&c.maxWait)
+		if int64(wait) <= max || atomic.CompareAndSwapInt64(&c.maxWait, max, int64(wait)) {
+			return
+		}
+	}
+}
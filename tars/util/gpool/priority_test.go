@@ -0,0 +1,60 @@
+package gpool
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestPriorityPool(t *testing.T) {
+	pool := NewPriorityPool(1, 100, 3, 2)
+	defer pool.Release()
+
+	// block the only worker until all the jobs are queued
+	gate := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit(0, func() {
+		close(started)
+		<-gate
+	})
+	<-started
+
+	var (
+		lock  sync.Mutex
+		order []int
+		wg    sync.WaitGroup
+	)
+	submit := func(priority, n int) {
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			pool.Submit(priority, func() {
+				defer wg.Done()
+				lock.Lock()
+				order = append(order, priority)
+				lock.Unlock()
+			})
+		}
+	}
+	submit(0, 2)
+	submit(1, 1)
+	submit(2, 6)
+	submit(5, 1) // taken as the highest priority
+	close(gate)
+	wg.Wait()
+
+	// every 2 jobs of higher priorities are followed by the starving jobs of lower priorities
+	want := []int{2, 2, 0, 1, 2, 2, 0, 2, 2, 5}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+	if stats := pool.Stats(2); stats.Dispatched != 7 || stats.Len != 0 || stats.Cap != 100 {
+		t.Errorf("stats of priority 2: %+v", stats)
+	}
+	if stats := pool.Stats(0); stats.Dispatched != 3 || stats.MaxWait <= 0 || stats.Wait < stats.MaxWait {
+		t.Errorf("stats of priority 0: %+v", stats)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"time"
 )
 
 var (
@@ -15,6 +16,9 @@ var (
 	allListenFds *sync.Map
 )
 
+// staleSocketTimeout is the timeout of dialing the existing unix socket file.
+const staleSocketTimeout = 100 * time.Millisecond
+
 func init() {
 	allListenFds = &sync.Map{}
 }
@@ -40,6 +44,11 @@ func CreateListener(proto string, addr string) (net.Listener, error) {
 	}
 	// not inherit, create new
 CreateTcp:
+	if proto == "unix" {
+		if err := removeStaleSocket(addr); err != nil {
+			return nil, err
+		}
+	}
 	ln, err := net.Listen(proto, addr)
 	if err == nil {
 		allListenFds.Store(key, ln)
@@ -47,6 +56,21 @@ CreateTcp:
 	return ln, err
 }
 
+// removeStaleSocket removes the socket file left by the last process,
+// the one still accepting connections is kept.
+func removeStaleSocket(addr string) error {
+	fi, err := os.Stat(addr)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+	conn, err := net.DialTimeout("unix", addr, staleSocketTimeout)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("listen unix %s: address already in use", addr)
+	}
+	return os.Remove(addr)
+}
+
 // CreateUDPConn creates a udp connection from inherited fd
 // if there is no inherited fd, create a now one.
 func CreateUDPConn(addr string) (*net.UDPConn, error) {
@@ -81,12 +105,16 @@ CreateUdp:
 	return conn, err
 }
 
-// GetAllListenFiles returns all listen files
+// GetAllListenFiles returns all listen files to be inherited by the new process.
+// The unix socket files are not removed on close any more, as they are used by the new process.
 func GetAllListenFiles() map[string]*os.File {
 	files := make(map[string]*os.File)
 	allListenFds.Range(func(k, v interface{}) bool {
 		key := k.(string)
 		val := v.(filer)
+		if uln, ok := val.(*net.UnixListener); ok {
+			uln.SetUnlinkOnClose(false)
+		}
 		if file, err := val.File(); err == nil {
 			files[key] = file
 		}
@@ -2,8 +2,11 @@ package grace
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
 )
 
@@ -27,3 +30,37 @@ func TestListener(t *testing.T) {
 	cmd.Start()
 	cmd.Wait()
 }
+
+func TestUnixListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	addr := filepath.Join(dir, "tars.sock")
+
+	ln, err := CreateListener("unix", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = CreateListener("unix", addr); err == nil {
+		t.Fatal("CreateListener() error = nil, want address in use of the live socket")
+	}
+	ln.Close()
+	if _, err = os.Stat(addr); !os.IsNotExist(err) {
+		t.Fatalf("socket file is not removed on close: %v", err)
+	}
+
+	// the socket file left by the killed process is replaced
+	stale, err := net.Listen("unix", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	ln, err = CreateListener("unix", addr)
+	if err != nil {
+		t.Fatalf("CreateListener() on the stale socket file error = %v", err)
+	}
+	ln.Close()
+}